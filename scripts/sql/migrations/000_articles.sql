@@ -0,0 +1,9 @@
+-- The ARTICLES table as it was first created. Applying every migration in order to an empty database gives the same tables as schema.sql
+CREATE TABLE IF NOT EXISTS ARTICLES (
+    ID SERIAL,
+    TITLE TEXT NOT NULL,
+    ARTICLE_DATE TIMESTAMP NOT NULL,
+    BODY TEXT NOT NULL,
+    TAGS TEXT[] NOT NULL,
+    CREATEDDATE TIMESTAMP NOT NULL DEFAULT current_timestamp
+);
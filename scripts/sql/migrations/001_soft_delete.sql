@@ -0,0 +1,4 @@
+-- Soft delete: rows are flagged with DELETED_AT and purged after the retention period. The column was first added without a
+-- timezone, the ALTER converts it in databases that already have it, reading the stored times in the session timezone
+ALTER TABLE ARTICLES ADD COLUMN IF NOT EXISTS DELETED_AT TIMESTAMPTZ NULL;
+ALTER TABLE ARTICLES ALTER COLUMN DELETED_AT TYPE TIMESTAMPTZ;
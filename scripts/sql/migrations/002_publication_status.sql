@@ -0,0 +1,7 @@
+-- Publication workflow: every existing article was live, so the column is added with a published default to backfill
+-- them and then defaults to draft for new articles. Running it again changes nothing
+ALTER TABLE ARTICLES ADD COLUMN IF NOT EXISTS STATUS TEXT NOT NULL DEFAULT 'published';
+ALTER TABLE ARTICLES ALTER COLUMN STATUS SET DEFAULT 'draft';
+ALTER TABLE ARTICLES ADD COLUMN IF NOT EXISTS PUBLISH_AT TIMESTAMPTZ NULL;
+ALTER TABLE ARTICLES ADD COLUMN IF NOT EXISTS PUBLISHED_AT TIMESTAMPTZ NULL;
+UPDATE ARTICLES SET PUBLISHED_AT = CREATEDDATE WHERE PUBLISHED_AT IS NULL AND STATUS = 'published';
@@ -0,0 +1,10 @@
+-- Excerpt, word count and reading time are worked out by the API when an article is written.
+-- Existing rows are backfilled with an approximation from the raw body, markup included
+ALTER TABLE ARTICLES ADD COLUMN IF NOT EXISTS EXCERPT TEXT NOT NULL DEFAULT '';
+ALTER TABLE ARTICLES ADD COLUMN IF NOT EXISTS WORD_COUNT INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE ARTICLES ADD COLUMN IF NOT EXISTS READING_MINUTES INTEGER NOT NULL DEFAULT 0;
+UPDATE ARTICLES SET
+    EXCERPT = left(regexp_replace(trim(BODY), '\s+', ' ', 'g'), 280),
+    WORD_COUNT = coalesce(array_length(regexp_split_to_array(nullif(trim(BODY), ''), '\s+'), 1), 0)
+WHERE WORD_COUNT = 0;
+UPDATE ARTICLES SET READING_MINUTES = ceil(WORD_COUNT / 200.0) WHERE READING_MINUTES = 0;
@@ -0,0 +1,45 @@
+-- Tags move out of the TAGS array into a TAGS table of normalized slugs and display names, joined to articles by ARTICLE_TAGS.
+-- The API case folds slugs, lower() is the closest postgres has so the rare tags they disagree on (e.g. ß) may need merging by hand
+BEGIN;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conrelid = 'articles'::regclass AND contype = 'p') THEN
+        ALTER TABLE ARTICLES ADD PRIMARY KEY (ID);
+    END IF;
+END $$;
+
+CREATE TABLE IF NOT EXISTS TAGS (
+    ID SERIAL PRIMARY KEY,
+    SLUG TEXT NOT NULL UNIQUE,
+    NAME TEXT NOT NULL,
+    CREATEDDATE TIMESTAMP NOT NULL DEFAULT current_timestamp
+);
+CREATE TABLE IF NOT EXISTS ARTICLE_TAGS (
+    ARTICLE_ID INTEGER NOT NULL REFERENCES ARTICLES (ID) ON DELETE CASCADE,
+    TAG_ID INTEGER NOT NULL REFERENCES TAGS (ID),
+    SORT_ORDER INTEGER NOT NULL,
+    PRIMARY KEY (ARTICLE_ID, TAG_ID)
+);
+CREATE INDEX IF NOT EXISTS ARTICLE_TAGS_TAG_ID_IDX ON ARTICLE_TAGS (TAG_ID);
+
+CREATE TEMPORARY TABLE ARTICLE_TAG_MIGRATION ON COMMIT DROP AS
+SELECT a.ID AS ARTICLE_ID, a.CREATEDDATE, tag.SORT_ORDER,
+    regexp_replace(btrim(normalize(tag.NAME, NFC), E' \t\r\n'), '\s+', ' ', 'g') AS NAME,
+    lower(regexp_replace(btrim(normalize(tag.NAME, NFKC), E' \t\r\n'), '\s+', '-', 'g')) AS SLUG
+FROM ARTICLES a, unnest(a.TAGS) WITH ORDINALITY AS tag(NAME, SORT_ORDER);
+
+-- each tag is named the way it was first written
+INSERT INTO TAGS (SLUG, NAME)
+SELECT DISTINCT ON (SLUG) SLUG, NAME FROM ARTICLE_TAG_MIGRATION WHERE SLUG <> '' ORDER BY SLUG, CREATEDDATE, ARTICLE_ID, SORT_ORDER
+ON CONFLICT (SLUG) DO NOTHING;
+
+INSERT INTO ARTICLE_TAGS (ARTICLE_ID, TAG_ID, SORT_ORDER)
+SELECT DISTINCT ON (m.ARTICLE_ID, t.ID) m.ARTICLE_ID, t.ID, m.SORT_ORDER
+FROM ARTICLE_TAG_MIGRATION m JOIN TAGS t ON t.SLUG = m.SLUG
+ORDER BY m.ARTICLE_ID, t.ID, m.SORT_ORDER
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE ARTICLES DROP COLUMN IF EXISTS TAGS;
+
+COMMIT;
@@ -0,0 +1,8 @@
+-- Tag taxonomy: a tag can sit below a parent tag (science > physics) and have aliases that mean the same tag (ai = artificial-intelligence)
+ALTER TABLE TAGS ADD COLUMN IF NOT EXISTS PARENT_ID INTEGER NULL REFERENCES TAGS (ID) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS TAGS_PARENT_ID_IDX ON TAGS (PARENT_ID);
+CREATE TABLE IF NOT EXISTS TAG_ALIASES (
+    SLUG TEXT PRIMARY KEY,
+    TAG_ID INTEGER NOT NULL REFERENCES TAGS (ID) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS TAG_ALIASES_TAG_ID_IDX ON TAG_ALIASES (TAG_ID);
@@ -0,0 +1,3 @@
+-- Prefix indexes for tag suggestions. text_pattern_ops lets LIKE 'prefix%' use them whatever the database collation
+CREATE INDEX IF NOT EXISTS TAGS_SLUG_PREFIX_IDX ON TAGS (SLUG text_pattern_ops);
+CREATE INDEX IF NOT EXISTS TAG_ALIASES_SLUG_PREFIX_IDX ON TAG_ALIASES (SLUG text_pattern_ops);
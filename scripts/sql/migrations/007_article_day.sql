@@ -0,0 +1,13 @@
+-- Publication days: ARTICLE_DATE only ever held days, at midnight and without a timezone. ARTICLE_DAY keeps the day each
+-- article was written for and ARTICLE_DATE becomes the moment the article is dated, the old midnights being taken as UTC
+ALTER TABLE ARTICLES ADD COLUMN IF NOT EXISTS ARTICLE_DAY DATE NULL;
+UPDATE ARTICLES SET ARTICLE_DAY = ARTICLE_DATE::date WHERE ARTICLE_DAY IS NULL;
+ALTER TABLE ARTICLES ALTER COLUMN ARTICLE_DAY SET NOT NULL;
+DO $$
+BEGIN
+    IF (SELECT data_type FROM information_schema.columns
+        WHERE table_schema = current_schema() AND table_name = 'articles' AND column_name = 'article_date') = 'timestamp without time zone' THEN
+        ALTER TABLE ARTICLES ALTER COLUMN ARTICLE_DATE TYPE TIMESTAMPTZ USING ARTICLE_DATE AT TIME ZONE 'UTC';
+    END IF;
+END $$;
+CREATE INDEX IF NOT EXISTS ARTICLES_ARTICLE_DAY_IDX ON ARTICLES (ARTICLE_DAY);
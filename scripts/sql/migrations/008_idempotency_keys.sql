@@ -0,0 +1,11 @@
+-- The responses to creates sent with an Idempotency-Key header, so retries get the first response rather than creating again.
+-- STATUS_CODE is null while the first request is still being handled
+CREATE TABLE IF NOT EXISTS IDEMPOTENCY_KEYS (
+    IDEMPOTENCY_KEY TEXT PRIMARY KEY,
+    REQUEST_HASH TEXT NOT NULL,
+    STATUS_CODE INTEGER NULL,
+    CONTENT_TYPE TEXT NOT NULL DEFAULT '',
+    BODY BYTEA NULL,
+    EXPIRES_AT TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS IDEMPOTENCY_KEYS_EXPIRES_AT_IDX ON IDEMPOTENCY_KEYS (EXPIRES_AT);
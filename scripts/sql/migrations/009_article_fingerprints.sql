@@ -0,0 +1,5 @@
+-- Each article's normalized title and body's hash and simhash, for finding duplicates. Articles created before this are
+-- left null and aren't found as duplicates
+ALTER TABLE ARTICLES ADD COLUMN IF NOT EXISTS CONTENT_HASH TEXT NULL;
+ALTER TABLE ARTICLES ADD COLUMN IF NOT EXISTS SIMHASH BIGINT NULL;
+CREATE INDEX IF NOT EXISTS ARTICLES_CONTENT_HASH_IDX ON ARTICLES (CONTENT_HASH, ARTICLE_DAY);
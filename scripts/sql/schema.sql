@@ -1,9 +1,63 @@
 -- ARTICLES
 CREATE TABLE ARTICLES (
-    ID SERIAL,
+    ID SERIAL PRIMARY KEY,
     TITLE TEXT NOT NULL,
-    ARTICLE_DATE TIMESTAMP NOT NULL,
+    ARTICLE_DATE TIMESTAMPTZ NOT NULL,
+    -- the day ARTICLE_DATE falls on in the publication timezone, which looking articles up by date matches on
+    ARTICLE_DAY DATE NOT NULL,
     BODY TEXT NOT NULL,
-    TAGS TEXT[] NOT NULL,
+    EXCERPT TEXT NOT NULL DEFAULT '',
+    WORD_COUNT INTEGER NOT NULL DEFAULT 0,
+    READING_MINUTES INTEGER NOT NULL DEFAULT 0,
+    CREATEDDATE TIMESTAMP NOT NULL DEFAULT current_timestamp,
+    STATUS TEXT NOT NULL DEFAULT 'draft',
+    PUBLISH_AT TIMESTAMPTZ NULL,
+    PUBLISHED_AT TIMESTAMPTZ NULL,
+    DELETED_AT TIMESTAMPTZ NULL,
+    -- the normalized title and body's hash and simhash, for finding duplicates. Null for articles created before they were kept
+    CONTENT_HASH TEXT NULL,
+    SIMHASH BIGINT NULL
+);
+CREATE INDEX ARTICLES_ARTICLE_DAY_IDX ON ARTICLES (ARTICLE_DAY);
+CREATE INDEX ARTICLES_CONTENT_HASH_IDX ON ARTICLES (CONTENT_HASH, ARTICLE_DAY);
+
+-- TAGS
+CREATE TABLE TAGS (
+    ID SERIAL PRIMARY KEY,
+    SLUG TEXT NOT NULL UNIQUE,
+    NAME TEXT NOT NULL,
+    PARENT_ID INTEGER NULL REFERENCES TAGS (ID) ON DELETE SET NULL,
     CREATEDDATE TIMESTAMP NOT NULL DEFAULT current_timestamp
 );
+CREATE INDEX TAGS_PARENT_ID_IDX ON TAGS (PARENT_ID);
+-- text_pattern_ops lets tag suggestions use the index for LIKE 'prefix%' whatever the database collation
+CREATE INDEX TAGS_SLUG_PREFIX_IDX ON TAGS (SLUG text_pattern_ops);
+
+-- TAG_ALIASES
+CREATE TABLE TAG_ALIASES (
+    SLUG TEXT PRIMARY KEY,
+    TAG_ID INTEGER NOT NULL REFERENCES TAGS (ID) ON DELETE CASCADE
+);
+CREATE INDEX TAG_ALIASES_TAG_ID_IDX ON TAG_ALIASES (TAG_ID);
+CREATE INDEX TAG_ALIASES_SLUG_PREFIX_IDX ON TAG_ALIASES (SLUG text_pattern_ops);
+
+-- ARTICLE_TAGS
+CREATE TABLE ARTICLE_TAGS (
+    ARTICLE_ID INTEGER NOT NULL REFERENCES ARTICLES (ID) ON DELETE CASCADE,
+    TAG_ID INTEGER NOT NULL REFERENCES TAGS (ID),
+    SORT_ORDER INTEGER NOT NULL,
+    PRIMARY KEY (ARTICLE_ID, TAG_ID)
+);
+CREATE INDEX ARTICLE_TAGS_TAG_ID_IDX ON ARTICLE_TAGS (TAG_ID);
+
+-- IDEMPOTENCY_KEYS, the responses to creates sent with an Idempotency-Key header. STATUS_CODE is null while the first
+-- request is still being handled
+CREATE TABLE IDEMPOTENCY_KEYS (
+    IDEMPOTENCY_KEY TEXT PRIMARY KEY,
+    REQUEST_HASH TEXT NOT NULL,
+    STATUS_CODE INTEGER NULL,
+    CONTENT_TYPE TEXT NOT NULL DEFAULT '',
+    BODY BYTEA NULL,
+    EXPIRES_AT TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IDEMPOTENCY_KEYS_EXPIRES_AT_IDX ON IDEMPOTENCY_KEYS (EXPIRES_AT);
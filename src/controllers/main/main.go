@@ -1,11 +1,15 @@
 package main
 
 import (
+	"net"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/bmordt/article-api/src/database"
+	"github.com/bmordt/article-api/src/middleware"
 	"github.com/bmordt/article-api/src/services"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
@@ -13,6 +17,23 @@ import (
 
 var (
 	portNum, dbName, dbUser, dbPassword, dbHost, dbPort string
+	dbBackend, sqlitePath                               string
+	dbReplicas                                          []string
+
+	adminAPIKey, publicURL        string
+	purgeRetention, purgeInterval time.Duration
+	schedulerInterval             time.Duration
+	cacheSize                     int
+	cacheTTL                      time.Duration
+	readYourWritesWindow          time.Duration
+	publicationTimezone           *time.Location
+	idempotencyTTL                time.Duration
+	rateLimit                     middleware.RateLimit
+	routeRateLimits               map[string]middleware.RateLimit
+	trustedProxies                []*net.IPNet
+	maxBodySize                   int64
+	routeMaxBodySizes             map[string]int64
+	strictDecoding                bool
 
 	logger *logrus.Entry
 )
@@ -37,25 +58,130 @@ func main() {
 	initEnvVariables()
 
 	muxrouter := mux.NewRouter()
+	muxrouter.Use(middleware.Authenticate(adminAPIKey))
+	muxrouter.Use(middleware.NewRateLimiter(rateLimit, routeRateLimits, trustedProxies).Limit)
+	muxrouter.Use(middleware.NewBodyLimiter(maxBodySize, routeMaxBodySizes).Limit)
+	muxrouter.Use(middleware.StrictDecoding(strictDecoding))
+	muxrouter.Use(middleware.ReadYourWrites(readYourWritesWindow))
 
-	dbClient := database.NewArticleDBClient(dbUser, dbName, dbPassword, dbHost, dbPort, logger)
+	dbClient := newDBClient()
+	if cacheSize > 0 {
+		dbClient = database.NewCachingDBClient(dbClient, cacheSize, cacheTTL, logger)
+	}
 	articleService := services.NewArticleService(dbClient, logger)
+	articleService.PublicURL = publicURL
+	articleService.Location = publicationTimezone
+	articleService.IdempotencyTTL = idempotencyTTL
+
+	// -- export route, it negotiates its own NDJSON and CSV formats
+	muxrouter.HandleFunc("/articles/export", articleService.ExportArticles).Methods("GET")
+
+	// -- feed routes, always RSS or Atom. They have to be matched before /tags/{tagName}/{date}
+	muxrouter.HandleFunc("/feed.rss", articleService.GetRSSFeed).Methods("GET")
+	muxrouter.HandleFunc("/feed.atom", articleService.GetAtomFeed).Methods("GET")
+	muxrouter.HandleFunc("/tags/{tagName}/feed.rss", articleService.GetRSSFeed).Methods("GET")
+	muxrouter.HandleFunc("/tags/{tagName}/feed.atom", articleService.GetAtomFeed).Methods("GET")
+
+	// -- every other route responds in JSON, XML or MessagePack depending on the Accept header
+	apiRouter := muxrouter.NewRoute().Subrouter()
+	apiRouter.Use(middleware.Negotiate)
 
 	// -- article routes
-	muxrouter.HandleFunc("/articles", articleService.CreateArticle).Methods("POST")
-	muxrouter.HandleFunc("/articles/{id}", articleService.GetArticle).Methods("GET")
-	muxrouter.HandleFunc("/tags/{tagName}/{date}", articleService.GetArticlesByTagAndDate).Methods("GET")
+	apiRouter.HandleFunc("/articles", articleService.Idempotent(articleService.CreateArticle)).Methods("POST")
+	apiRouter.HandleFunc("/articles/bulk", articleService.BulkCreateArticles).Methods("POST")
+	apiRouter.HandleFunc("/articles/{id}", articleService.GetArticle).Methods("GET")
+	apiRouter.Handle("/articles/{id}", middleware.RequireAPIKey(adminAPIKey)(http.HandlerFunc(articleService.DeleteArticle))).Methods("DELETE")
+
+	// -- publication workflow routes
+	workflowRouter := apiRouter.PathPrefix("/articles/{id}").Subrouter()
+	workflowRouter.Use(middleware.RequireAPIKey(adminAPIKey))
+	workflowRouter.HandleFunc("/submit", articleService.SubmitArticle).Methods("POST")
+	workflowRouter.HandleFunc("/publish", articleService.PublishArticle).Methods("POST")
+	workflowRouter.HandleFunc("/archive", articleService.ArchiveArticle).Methods("POST")
+	workflowRouter.HandleFunc("/unpublish", articleService.UnpublishArticle).Methods("POST")
+
+	// -- tag routes, /tags/suggest, /tags/trending and /tags/{tagName}/related have to be matched before the routes they look like
+	apiRouter.HandleFunc("/tags", articleService.GetTags).Methods("GET")
+	apiRouter.HandleFunc("/tags/suggest", articleService.SuggestTags).Methods("GET")
+	apiRouter.HandleFunc("/tags/trending", articleService.GetTrendingTags).Methods("GET")
+	apiRouter.HandleFunc("/tags/{tagName}", articleService.GetTag).Methods("GET")
+	apiRouter.HandleFunc("/tags/{tagName}/related", articleService.GetRelatedTags).Methods("GET")
+	apiRouter.HandleFunc("/tags/{tagName}/{date}", articleService.GetArticlesByTagAndDate).Methods("GET")
+
+	// -- admin routes
+	adminRouter := apiRouter.PathPrefix("/admin").Subrouter()
+	adminRouter.Use(middleware.RequireAPIKey(adminAPIKey))
+	adminRouter.HandleFunc("/articles/deleted", articleService.GetDeletedArticles).Methods("GET")
+	adminRouter.HandleFunc("/cache", articleService.GetCacheStats).Methods("GET")
+	adminRouter.HandleFunc("/articles/{id}/restore", articleService.RestoreArticle).Methods("POST")
+	adminRouter.HandleFunc("/tags/{tagName}/rename", articleService.RenameTag).Methods("POST")
+	adminRouter.HandleFunc("/tags/{tagName}/merge", articleService.MergeTag).Methods("POST")
+	adminRouter.HandleFunc("/tags/{tagName}/aliases", articleService.AddTagAlias).Methods("POST")
+	adminRouter.HandleFunc("/tags/{tagName}/aliases/{alias}", articleService.RemoveTagAlias).Methods("DELETE")
+	adminRouter.HandleFunc("/tags/{tagName}/parent", articleService.SetTagParent).Methods("PUT")
+
+	// -- background jobs
+	go articleService.PurgeDeletedArticles(purgeRetention, purgeInterval, nil)
+	go articleService.PublishScheduledArticles(schedulerInterval, nil)
 
 	//Router end
 	logger.Fatalf("%v", http.ListenAndServe(":"+portNum, muxrouter))
 }
 
+//newDBClient connects to the storage backend picked by the "DBBACKEND" env variable
+func newDBClient() database.DBClient {
+	switch dbBackend {
+	case "sqlite":
+		return database.NewSQLiteDBClient(sqlitePath, logger)
+	case "memory":
+		logger.Warnf("Using the in-memory database, everything is lost when the server stops")
+		return database.NewMemoryDBClient(logger)
+	default:
+		return database.NewArticleDBClient(dbUser, dbName, dbPassword, dbHost, dbPort, dbReplicas, logger)
+	}
+}
+
 //initEnvVariables gets required env variables
 func initEnvVariables() {
 	portNum = GetAPIPort()
 	if strings.Compare(portNum, "") == 0 {
 		logger.Fatalf("Server Port env \"APIPORT\" variable is not set: %s", portNum)
 	}
+	dbBackend = GetDBBackend()
+	switch dbBackend {
+	case "postgres":
+		initPostgresEnvVariables()
+	case "sqlite":
+		sqlitePath = GetSQLitePath()
+	case "memory":
+	default:
+		logger.Fatalf("Database backend env \"DBBACKEND\" variable must be postgres, sqlite or memory: %s", dbBackend)
+	}
+
+	//optional variables
+	adminAPIKey = GetAdminAPIKey()
+	if strings.Compare(adminAPIKey, "") == 0 {
+		logger.Warnf("Admin api key env \"ADMINAPIKEY\" variable is not set, admin routes will reject every request")
+	}
+	publicURL = GetPublicURL()
+	purgeRetention = getDurationEnv("PURGERETENTION", 30*24*time.Hour)
+	purgeInterval = getDurationEnv("PURGEINTERVAL", time.Hour)
+	schedulerInterval = getDurationEnv("SCHEDULERINTERVAL", 30*time.Second)
+	cacheSize = getIntEnv("CACHESIZE", 1000)
+	cacheTTL = getDurationEnv("CACHETTL", time.Minute)
+	readYourWritesWindow = getDurationEnv("READYOURWRITESWINDOW", 5*time.Second)
+	publicationTimezone = getLocationEnv("PUBLICATIONTIMEZONE", time.UTC)
+	idempotencyTTL = getDurationEnv("IDEMPOTENCYTTL", 24*time.Hour)
+	rateLimit = getRateLimitEnv("RATELIMIT", middleware.RateLimit{Requests: 300, Per: time.Minute})
+	routeRateLimits = getRouteRateLimitsEnv("RATELIMITROUTES")
+	trustedProxies = getTrustedProxiesEnv("TRUSTEDPROXIES")
+	maxBodySize = getByteSizeEnv("MAXBODYSIZE", 1<<20)
+	routeMaxBodySizes = getRouteByteSizesEnv("MAXBODYSIZEROUTES", "POST /articles/bulk=64MB")
+	strictDecoding = getBoolEnv("STRICTDECODING", false)
+}
+
+//initPostgresEnvVariables gets the env variables required to connect to postgres
+func initPostgresEnvVariables() {
 	dbUser = GetDBUser()
 	if strings.Compare(dbUser, "") == 0 {
 		logger.Fatalf("Database user env \"DBUSER\" variable is not set: %s", dbUser)
@@ -76,6 +202,125 @@ func initEnvVariables() {
 	if strings.Compare(dbPort, "") == 0 {
 		logger.Fatalf("Database port env \"DBPORT\" variable is not set: %s", dbPort)
 	}
+	dbReplicas = GetDBReplicas()
+}
+
+//getIntEnv parses a non negative int env variable, falling back to the default when it is not set
+func getIntEnv(name string, defaultValue int) int {
+	value := os.Getenv(name)
+	if strings.Compare(value, "") == 0 {
+		return defaultValue
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 0 {
+		logger.Fatalf("Env variable \"%s\" is not a valid number: %s", name, value)
+	}
+	return number
+}
+
+//getDurationEnv parses a duration env variable e.g. "72h", falling back to the default when it is not set
+func getDurationEnv(name string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if strings.Compare(value, "") == 0 {
+		return defaultValue
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		logger.Fatalf("Env variable \"%s\" is not a valid duration: %s", name, value)
+	}
+	return duration
+}
+
+//getLocationEnv loads a timezone env variable e.g. "Europe/London", falling back to the default when it is not set
+func getLocationEnv(name string, defaultValue *time.Location) *time.Location {
+	value := os.Getenv(name)
+	if strings.Compare(value, "") == 0 {
+		return defaultValue
+	}
+	location, err := time.LoadLocation(value)
+	if err != nil {
+		logger.Fatalf("Env variable \"%s\" is not a valid timezone: %s", name, value)
+	}
+	return location
+}
+
+//getRateLimitEnv parses a rate limit env variable e.g. "100/1m", falling back to the default when it is not set
+func getRateLimitEnv(name string, defaultValue middleware.RateLimit) middleware.RateLimit {
+	value := os.Getenv(name)
+	if strings.Compare(value, "") == 0 {
+		return defaultValue
+	}
+	limit, err := middleware.ParseRateLimit(value)
+	if err != nil {
+		logger.Fatalf("Env variable \"%s\" is not a valid rate limit: %s", name, value)
+	}
+	return limit
+}
+
+//getRouteRateLimitsEnv parses the per route rate limits env variable e.g. "POST /articles=10/1m,POST /articles/bulk=2/1m"
+func getRouteRateLimitsEnv(name string) map[string]middleware.RateLimit {
+	value := os.Getenv(name)
+	limits, err := middleware.ParseRouteRateLimits(value)
+	if err != nil {
+		logger.Fatalf("Env variable \"%s\" is not a valid list of route rate limits: %s", name, value)
+	}
+	return limits
+}
+
+//getTrustedProxiesEnv parses the comma separated addresses or CIDR ranges of the trusted proxies env variable
+func getTrustedProxiesEnv(name string) []*net.IPNet {
+	value := os.Getenv(name)
+	values := []string{}
+	for _, proxy := range strings.Split(value, ",") {
+		if strings.TrimSpace(proxy) != "" {
+			values = append(values, strings.TrimSpace(proxy))
+		}
+	}
+	proxies, err := middleware.ParseTrustedProxies(values)
+	if err != nil {
+		logger.Fatalf("Env variable \"%s\" is not a valid list of proxies: %s", name, value)
+	}
+	return proxies
+}
+
+//getByteSizeEnv parses a size env variable e.g. "512KB", falling back to the default when it is not set
+func getByteSizeEnv(name string, defaultValue int64) int64 {
+	value := os.Getenv(name)
+	if strings.Compare(value, "") == 0 {
+		return defaultValue
+	}
+	size, err := middleware.ParseByteSize(value)
+	if err != nil {
+		logger.Fatalf("Env variable \"%s\" is not a valid size: %s", name, value)
+	}
+	return size
+}
+
+//getRouteByteSizesEnv parses the per route sizes env variable e.g. "POST /articles/bulk=64MB", falling back to the default
+//when it is not set
+func getRouteByteSizesEnv(name, defaultValue string) map[string]int64 {
+	value := os.Getenv(name)
+	if strings.Compare(value, "") == 0 {
+		value = defaultValue
+	}
+	sizes, err := middleware.ParseRouteByteSizes(value)
+	if err != nil {
+		logger.Fatalf("Env variable \"%s\" is not a valid list of route sizes: %s", name, value)
+	}
+	return sizes
+}
+
+//getBoolEnv parses a bool env variable e.g. "true", falling back to the default when it is not set
+func getBoolEnv(name string, defaultValue bool) bool {
+	value := os.Getenv(name)
+	if strings.Compare(value, "") == 0 {
+		return defaultValue
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		logger.Fatalf("Env variable \"%s\" is not a valid bool: %s", name, value)
+	}
+	return parsed
 }
 
 //GetAPIPort gets the api port from env
@@ -107,3 +352,42 @@ func GetDBHost() string {
 func GetDBPort() string {
 	return os.Getenv("DBPORT")
 }
+
+//GetDBReplicas gets the comma separated connection strings of the postgres read replicas from env
+func GetDBReplicas() []string {
+	replicas := []string{}
+	for _, replica := range strings.Split(os.Getenv("DBREPLICAS"), ",") {
+		if strings.TrimSpace(replica) != "" {
+			replicas = append(replicas, strings.TrimSpace(replica))
+		}
+	}
+	return replicas
+}
+
+//GetDBBackend gets the storage backend, postgres, sqlite or memory, from env. It defaults to postgres
+func GetDBBackend() string {
+	backend := os.Getenv("DBBACKEND")
+	if strings.Compare(backend, "") == 0 {
+		return "postgres"
+	}
+	return backend
+}
+
+//GetSQLitePath gets the path of the sqlite database file from env. It defaults to article-api.db
+func GetSQLitePath() string {
+	path := os.Getenv("SQLITEPATH")
+	if strings.Compare(path, "") == 0 {
+		return "article-api.db"
+	}
+	return path
+}
+
+//GetAdminAPIKey gets the api key required by admin routes from env
+func GetAdminAPIKey() string {
+	return os.Getenv("ADMINAPIKEY")
+}
+
+//GetPublicURL gets the public address of the API e.g. https://api.example.com from env
+func GetPublicURL() string {
+	return os.Getenv("PUBLICURL")
+}
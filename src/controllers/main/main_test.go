@@ -23,4 +23,13 @@ func TestInitEnvVariables(t *testing.T) {
 	t.Run("Given all the env variables are set, no fatal log is thrown", func(t *testing.T) {
 		initEnvVariables()
 	})
+	t.Run("Given the sqlite backend without a path, the default path is used", func(t *testing.T) {
+		os.Setenv("DBBACKEND", "sqlite")
+		defer os.Unsetenv("DBBACKEND")
+
+		initEnvVariables()
+		if dbBackend != "sqlite" || sqlitePath != "article-api.db" {
+			t.Errorf("expected the sqlite backend at article-api.db, got %s at %s", dbBackend, sqlitePath)
+		}
+	})
 }
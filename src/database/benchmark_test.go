@@ -0,0 +1,392 @@
+package database
+
+import (
+	"context"
+	"io/ioutil"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bmordt/article-api/src/models"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+//benchmarkCase measures one DBClient method. Each case gets a client holding benchmarkArticles articles, ids being their ids
+type benchmarkCase struct {
+	name string
+	run  func(b *testing.B, client DBClient, ids []int)
+}
+
+//benchmarkArticles is how many articles are written before each benchmark, spread over benchmarkTags tags and benchmarkDays days
+const (
+	benchmarkArticles = 200
+	benchmarkTags     = 10
+	benchmarkDays     = 10
+)
+
+var benchmarkDate = mustParseDate("2021-01-01")
+
+func BenchmarkMemoryDBClient(b *testing.B) {
+	runBenchmarkSuite(b, func(b *testing.B) DBClient {
+		return NewMemoryDBClient(newBenchmarkLogger())
+	})
+}
+
+func BenchmarkSQLiteDBClient(b *testing.B) {
+	runBenchmarkSuite(b, func(b *testing.B) DBClient {
+		client := NewSQLiteDBClient(":memory:", newBenchmarkLogger())
+		b.Cleanup(func() { client.DB.Close() })
+		return client
+	})
+}
+
+//BenchmarkPostgresDBClient runs every benchmark with and without prepared statements, so comparing the two shows what
+//preparing the statements saves. The sub-benchmarks are named stmts=prepared and stmts=unprepared so benchstat can put them
+//side by side e.g. go test ./src/database -run XXX -bench Postgres -benchmem -count 10 > bench.txt && benchstat -col /stmts bench.txt
+func BenchmarkPostgresDBClient(b *testing.B) {
+	adminDB, connStr := openTestPostgres(b)
+	schema := readTestSchema(b)
+
+	b.Run("stmts=prepared", func(b *testing.B) {
+		runBenchmarkSuite(b, func(b *testing.B) DBClient {
+			db := newPostgresSchemaDB(b, adminDB, connStr, []string{schema})
+			stmts, err := prepareStatements(db)
+			require.NoError(b, err)
+			return &ArticleDBClient{DB: db, Logger: newBenchmarkLogger(), stmts: stmts}
+		})
+	})
+	b.Run("stmts=unprepared", func(b *testing.B) {
+		runBenchmarkSuite(b, func(b *testing.B) DBClient {
+			db := newPostgresSchemaDB(b, adminDB, connStr, []string{schema})
+			return &ArticleDBClient{DB: db, Logger: newBenchmarkLogger()}
+		})
+	})
+}
+
+//runBenchmarkSuite runs every benchmark case against a new client from newClient holding the benchmark articles
+func runBenchmarkSuite(b *testing.B, newClient func(b *testing.B) DBClient) {
+	for _, c := range benchmarkCases {
+		c := c
+		b.Run(c.name, func(b *testing.B) {
+			client := newClient(b)
+			ids := seedBenchmarkArticles(b, client)
+			b.ReportAllocs()
+			b.ResetTimer()
+			c.run(b, client, ids)
+		})
+	}
+}
+
+//seedBenchmarkArticles writes the benchmark articles, every other one published, each carrying two neighbouring tags and
+//its own content hash
+func seedBenchmarkArticles(b *testing.B, client DBClient) []int {
+	articles := make([]*models.Article, benchmarkArticles)
+	for i := range articles {
+		status := models.StatusDraft
+		if i%2 == 0 {
+			status = models.StatusPublished
+		}
+		articles[i] = newConformanceArticle("article "+strconv.Itoa(i), benchmarkArticleDate(i), status,
+			benchmarkTag(i), benchmarkTag(i+1))
+		articles[i].ContentHash, articles[i].Simhash = benchmarkContentHash(i), int64(i)
+	}
+	ids, err := client.CreateArticleRows(articles)
+	require.NoError(b, err)
+	return ids
+}
+
+func benchmarkTag(i int) string {
+	return "tag-" + strconv.Itoa(i%benchmarkTags)
+}
+
+func benchmarkContentHash(i int) string {
+	return "hash-" + strconv.Itoa(i%benchmarkArticles)
+}
+
+func benchmarkArticleDate(i int) time.Time {
+	return benchmarkDate.AddDate(0, 0, i%benchmarkDays)
+}
+
+//newBenchmarkLogger returns a logger throwing its output away, so the benchmarks measure the client rather than the logging
+func newBenchmarkLogger() *logrus.Entry {
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	return logger.WithFields(logrus.Fields{})
+}
+
+var benchmarkCases = []benchmarkCase{
+	{"CreateArticleRow", func(b *testing.B, client DBClient, ids []int) {
+		for i := 0; i < b.N; i++ {
+			_, err := client.CreateArticleRow(newConformanceArticle("created", benchmarkDate, models.StatusDraft, benchmarkTag(i)))
+			require.NoError(b, err)
+		}
+	}},
+	{"CreateArticleRows", func(b *testing.B, client DBClient, ids []int) {
+		for i := 0; i < b.N; i++ {
+			articles := make([]*models.Article, 10)
+			for a := range articles {
+				articles[a] = newConformanceArticle("created", benchmarkDate, models.StatusDraft, benchmarkTag(a))
+			}
+			_, err := client.CreateArticleRows(articles)
+			require.NoError(b, err)
+		}
+	}},
+	{"GetArticleRowByID", func(b *testing.B, client DBClient, ids []int) {
+		for i := 0; i < b.N; i++ {
+			article, err := client.GetArticleRowByID(ids[i%len(ids)])
+			require.NoError(b, err)
+			require.NotNil(b, article)
+		}
+	}},
+	{"GetArticleRowByTagAndDate", func(b *testing.B, client DBClient, ids []int) {
+		for i := 0; i < b.N; i++ {
+			_, err := client.GetArticleRowByTagAndDate(benchmarkTag(i), benchmarkArticleDate(i).Format(expectedDateFormatString), false, false)
+			require.NoError(b, err)
+		}
+	}},
+	{"GetArticleRowByTagAndDateWithDescendants", func(b *testing.B, client DBClient, ids []int) {
+		for i := 0; i < b.N; i++ {
+			_, err := client.GetArticleRowByTagAndDate(benchmarkTag(i), benchmarkArticleDate(i).Format(expectedDateFormatString), true, true)
+			require.NoError(b, err)
+		}
+	}},
+	{"ExportArticleRows", func(b *testing.B, client DBClient, ids []int) {
+		for i := 0; i < b.N; i++ {
+			err := client.ExportArticleRows(models.ArticleFilter{}, func(article *models.Article) error { return nil })
+			require.NoError(b, err)
+		}
+	}},
+	{"GetRecentArticleRows", func(b *testing.B, client DBClient, ids []int) {
+		for i := 0; i < b.N; i++ {
+			_, err := client.GetRecentArticleRows("", 20)
+			require.NoError(b, err)
+		}
+	}},
+	{"GetArticleIDByContentHash", func(b *testing.B, client DBClient, ids []int) {
+		for i := 0; i < b.N; i++ {
+			id, err := client.GetArticleIDByContentHash(benchmarkContentHash(i), benchmarkArticleDate(i%benchmarkArticles).Format(models.DayFormat))
+			require.NoError(b, err)
+			require.NotZero(b, id)
+		}
+	}},
+	{"GetArticleFingerprintRows", func(b *testing.B, client DBClient, ids []int) {
+		for i := 0; i < b.N; i++ {
+			day := benchmarkArticleDate(i)
+			_, err := client.GetArticleFingerprintRows(day.AddDate(0, 0, -7).Format(models.DayFormat), day.AddDate(0, 0, 7).Format(models.DayFormat))
+			require.NoError(b, err)
+		}
+	}},
+	{"UpdateArticleStatus", func(b *testing.B, client DBClient, ids []int) {
+		//the odd articles are drafts, each is published and moved back to draft in turn
+		for i := 0; i < b.N; i++ {
+			id := ids[(i/2*2+1)%len(ids)]
+			from, to := models.StatusDraft, models.StatusPublished
+			if i%2 == 1 {
+				from, to = to, from
+			}
+			updated, err := client.UpdateArticleStatus(id, from, to, nil)
+			require.NoError(b, err)
+			require.True(b, updated)
+		}
+	}},
+	{"PublishScheduledArticleRows", func(b *testing.B, client DBClient, ids []int) {
+		for i := 0; i < b.N; i++ {
+			_, err := client.PublishScheduledArticleRows(time.Now())
+			require.NoError(b, err)
+		}
+	}},
+	{"DeleteArticleByID", func(b *testing.B, client DBClient, ids []int) {
+		for i := 0; i < b.N; i++ {
+			deleted, err := client.DeleteArticleByID(ids[i%len(ids)])
+			require.NoError(b, err)
+			require.True(b, deleted)
+
+			b.StopTimer()
+			_, err = client.RestoreArticleByID(ids[i%len(ids)])
+			require.NoError(b, err)
+			b.StartTimer()
+		}
+	}},
+	{"GetDeletedArticleRows", func(b *testing.B, client DBClient, ids []int) {
+		b.StopTimer()
+		for _, id := range ids[:benchmarkArticles/10] {
+			_, err := client.DeleteArticleByID(id)
+			require.NoError(b, err)
+		}
+		b.StartTimer()
+
+		for i := 0; i < b.N; i++ {
+			_, err := client.GetDeletedArticleRows()
+			require.NoError(b, err)
+		}
+	}},
+	{"RestoreArticleByID", func(b *testing.B, client DBClient, ids []int) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			_, err := client.DeleteArticleByID(ids[i%len(ids)])
+			require.NoError(b, err)
+			b.StartTimer()
+
+			restored, err := client.RestoreArticleByID(ids[i%len(ids)])
+			require.NoError(b, err)
+			require.True(b, restored)
+		}
+	}},
+	{"PurgeDeletedArticleRows", func(b *testing.B, client DBClient, ids []int) {
+		//nothing was deleted before the articles were written, so every purge looks for rows and finds none
+		deletedBefore := time.Now().Add(-time.Hour)
+		for i := 0; i < b.N; i++ {
+			_, err := client.PurgeDeletedArticleRows(deletedBefore)
+			require.NoError(b, err)
+		}
+	}},
+	{"GetTagRows", func(b *testing.B, client DBClient, ids []int) {
+		for i := 0; i < b.N; i++ {
+			_, _, err := client.GetTagRows(models.TagSortUsage, 20, 0, false)
+			require.NoError(b, err)
+		}
+	}},
+	{"GetTagRowBySlug", func(b *testing.B, client DBClient, ids []int) {
+		for i := 0; i < b.N; i++ {
+			tag, err := client.GetTagRowBySlug(benchmarkTag(i), false)
+			require.NoError(b, err)
+			require.NotNil(b, tag)
+		}
+	}},
+	{"GetTagSuggestionRows", func(b *testing.B, client DBClient, ids []int) {
+		for i := 0; i < b.N; i++ {
+			_, err := client.GetTagSuggestionRows("tag-", 10, false)
+			require.NoError(b, err)
+		}
+	}},
+	{"RenameTagRow", func(b *testing.B, client DBClient, ids []int) {
+		//tag-0 is renamed back and forth
+		slugs := []string{"tag-0", "renamed"}
+		for i := 0; i < b.N; i++ {
+			renamed, err := client.RenameTagRow(slugs[i%2], slugs[(i+1)%2])
+			require.NoError(b, err)
+			require.True(b, renamed)
+		}
+	}},
+	{"MergeTagRows", func(b *testing.B, client DBClient, ids []int) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			from := "merged-" + strconv.Itoa(i)
+			_, err := client.CreateArticleRow(newConformanceArticle("merged", benchmarkDate, models.StatusDraft, from))
+			require.NoError(b, err)
+			b.StartTimer()
+
+			merged, err := client.MergeTagRows(from, "tag-0")
+			require.NoError(b, err)
+			require.True(b, merged)
+		}
+	}},
+	{"AddTagAliasRow", func(b *testing.B, client DBClient, ids []int) {
+		for i := 0; i < b.N; i++ {
+			added, err := client.AddTagAliasRow("tag-0", "alias")
+			require.NoError(b, err)
+			require.True(b, added)
+
+			b.StopTimer()
+			_, err = client.RemoveTagAliasRow("tag-0", "alias")
+			require.NoError(b, err)
+			b.StartTimer()
+		}
+	}},
+	{"RemoveTagAliasRow", func(b *testing.B, client DBClient, ids []int) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			_, err := client.AddTagAliasRow("tag-0", "alias")
+			require.NoError(b, err)
+			b.StartTimer()
+
+			removed, err := client.RemoveTagAliasRow("tag-0", "alias")
+			require.NoError(b, err)
+			require.True(b, removed)
+		}
+	}},
+	{"SetTagParentRow", func(b *testing.B, client DBClient, ids []int) {
+		//tag-1 is put below tag-0 and back at the top in turn
+		parents := []string{"tag-0", ""}
+		for i := 0; i < b.N; i++ {
+			set, err := client.SetTagParentRow("tag-1", parents[i%2])
+			require.NoError(b, err)
+			require.True(b, set)
+		}
+	}},
+	{"GetTrendingTagRows", func(b *testing.B, client DBClient, ids []int) {
+		for i := 0; i < b.N; i++ {
+			_, err := client.GetTrendingTagRows(7, 10, false)
+			require.NoError(b, err)
+		}
+	}},
+	{"GetRelatedTagRows", func(b *testing.B, client DBClient, ids []int) {
+		for i := 0; i < b.N; i++ {
+			_, err := client.GetRelatedTagRows(benchmarkTag(i), 30, 10, false)
+			require.NoError(b, err)
+		}
+	}},
+	{"ReserveIdempotencyKey", func(b *testing.B, client DBClient, ids []int) {
+		//every key is new, so each call removes the expired keys and reserves one
+		now := time.Now()
+		for i := 0; i < b.N; i++ {
+			stored, err := client.ReserveIdempotencyKey("key-"+strconv.Itoa(i), "hash", now, now.Add(time.Hour))
+			require.NoError(b, err)
+			require.Nil(b, stored)
+		}
+	}},
+	{"ReserveIdempotencyKeyReplay", func(b *testing.B, client DBClient, ids []int) {
+		now := time.Now()
+		_, err := client.ReserveIdempotencyKey("key", "hash", now, now.Add(time.Hour))
+		require.NoError(b, err)
+		require.NoError(b, client.SaveIdempotentResponse("key", &models.IdempotentResponse{StatusCode: 201, ContentType: "application/json", Body: []byte(`{"id":"1"}`)}))
+
+		for i := 0; i < b.N; i++ {
+			stored, err := client.ReserveIdempotencyKey("key", "hash", now, now.Add(time.Hour))
+			require.NoError(b, err)
+			require.NotNil(b, stored)
+		}
+	}},
+	{"SaveIdempotentResponse", func(b *testing.B, client DBClient, ids []int) {
+		now := time.Now()
+		_, err := client.ReserveIdempotencyKey("key", "hash", now, now.Add(time.Hour))
+		require.NoError(b, err)
+
+		response := &models.IdempotentResponse{StatusCode: 201, ContentType: "application/json", Body: []byte(`{"id":"1"}`)}
+		for i := 0; i < b.N; i++ {
+			require.NoError(b, client.SaveIdempotentResponse("key", response))
+		}
+	}},
+	{"ReleaseIdempotencyKey", func(b *testing.B, client DBClient, ids []int) {
+		now := time.Now()
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			_, err := client.ReserveIdempotencyKey("key", "hash", now, now.Add(time.Hour))
+			require.NoError(b, err)
+			b.StartTimer()
+
+			require.NoError(b, client.ReleaseIdempotencyKey("key"))
+		}
+	}},
+	{"WithTx", func(b *testing.B, client DBClient, ids []int) {
+		//reads an article and soft deletes it then restores it, all in one transaction
+		for i := 0; i < b.N; i++ {
+			id := ids[i%len(ids)]
+			err := client.WithTx(context.Background(), func(tx DBClient) error {
+				_, err := tx.GetArticleRowByID(id)
+				if err != nil {
+					return err
+				}
+				_, err = tx.DeleteArticleByID(id)
+				if err != nil {
+					return err
+				}
+				_, err = tx.RestoreArticleByID(id)
+				return err
+			})
+			require.NoError(b, err)
+		}
+	}},
+}
@@ -0,0 +1,461 @@
+package database
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bmordt/article-api/src/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+//Cache key prefixes. Article entries are keyed by id, everything else is a listing or tag summary that any write can change
+const (
+	articleCacheKeyPrefix    = "article:"
+	tagAndDateCacheKeyPrefix = "tagdate:"
+	tagCacheKeyPrefix        = "tag:"
+)
+
+//CacheStats are the hit and miss counters of a CachingDBClient
+type CacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Entries   int
+	Size      int
+	TTL       time.Duration
+}
+
+//CachingDBClient wraps a DBClient, keeping articles by id, articles by tag and date and tags by slug in memory.
+//Entries are dropped after the ttl, the least recently used entry is dropped when size is reached and any write through
+//the client drops the entries it can change. Writes made by other instances are only seen once the ttl has passed
+type CachingDBClient struct {
+	//hits and misses come first so they are 64 bit aligned for atomic
+	hits, misses uint64
+
+	DBClient
+	Logger *logrus.Entry
+
+	cache *lruCache
+
+	//primaryReads skips looking in the cache, for reads that must see writes just made
+	primaryReads bool
+
+	//tx is set on the client WithTx passes to its func. Its reads skip the cache and aren't cached, and what its writes
+	//would drop is only dropped once the transaction commits
+	tx *txInvalidations
+}
+
+//txInvalidations are the cache entries the writes of a transaction change, dropped when it commits
+type txInvalidations struct {
+	mu      sync.Mutex
+	matches []func(key string) bool
+}
+
+//NewCachingDBClient wraps the client with a cache of at most size entries each kept for ttl
+func NewCachingDBClient(client DBClient, size int, ttl time.Duration, logger *logrus.Entry) *CachingDBClient {
+	return &CachingDBClient{
+		DBClient: client,
+		Logger:   logger,
+		cache:    newLRUCache(size, ttl),
+	}
+}
+
+//Stats returns the cache's hit and miss counters
+func (c *CachingDBClient) Stats() CacheStats {
+	entries, evictions := c.cache.stats()
+	return CacheStats{
+		Hits:      atomic.LoadUint64(&c.hits),
+		Misses:    atomic.LoadUint64(&c.misses),
+		Evictions: evictions,
+		Entries:   entries,
+		Size:      c.cache.size,
+		TTL:       c.cache.ttl,
+	}
+}
+
+//GetArticleRowByID returns the cached article or reads it through. Missing articles are not cached
+func (c *CachingDBClient) GetArticleRowByID(findID int) (*models.Article, error) {
+	key := fmt.Sprintf("%s%d", articleCacheKeyPrefix, findID)
+	value, generation, ok := c.get(key)
+	if ok {
+		return copyArticle(value.(*models.Article)), nil
+	}
+
+	article, err := c.DBClient.GetArticleRowByID(findID)
+	if err != nil || article == nil {
+		return article, err
+	}
+	c.set(key, copyArticle(article), generation)
+	return article, nil
+}
+
+//GetArticleRowByTagAndDate returns the cached articles or reads them through
+func (c *CachingDBClient) GetArticleRowByTagAndDate(tag, date string, publishedOnly, includeDescendants bool) (*[]models.Article, error) {
+	key := fmt.Sprintf("%s%s|%s|%t|%t", tagAndDateCacheKeyPrefix, tag, date, publishedOnly, includeDescendants)
+	value, generation, ok := c.get(key)
+	if ok {
+		return copyArticles(value.(*[]models.Article)), nil
+	}
+
+	articles, err := c.DBClient.GetArticleRowByTagAndDate(tag, date, publishedOnly, includeDescendants)
+	if err != nil {
+		return nil, err
+	}
+	c.set(key, copyArticles(articles), generation)
+	return articles, nil
+}
+
+//GetTagRowBySlug returns the cached tag or reads it through. Missing tags are not cached
+func (c *CachingDBClient) GetTagRowBySlug(slug string, publishedOnly bool) (*models.Tag, error) {
+	key := fmt.Sprintf("%s%s|%t", tagCacheKeyPrefix, slug, publishedOnly)
+	value, generation, ok := c.get(key)
+	if ok {
+		return copyTag(value.(*models.Tag)), nil
+	}
+
+	tag, err := c.DBClient.GetTagRowBySlug(slug, publishedOnly)
+	if err != nil || tag == nil {
+		return tag, err
+	}
+	c.set(key, copyTag(tag), generation)
+	return tag, nil
+}
+
+//CreateArticleRow creates the article and drops the cached tags and listings of its day. A new article has no cached entry
+//by id to drop
+func (c *CachingDBClient) CreateArticleRow(article *models.Article) (int, error) {
+	id, err := c.DBClient.CreateArticleRow(article)
+	c.invalidateNewArticles([]*models.Article{article})
+	return id, err
+}
+
+//CreateArticleRows creates the articles and drops the cached tags and listings of their days
+func (c *CachingDBClient) CreateArticleRows(articles []*models.Article) ([]int, error) {
+	ids, err := c.DBClient.CreateArticleRows(articles)
+	c.invalidateNewArticles(articles)
+	return ids, err
+}
+
+//UpdateArticleStatus moves the article's status and drops it and the cached listings and tags
+func (c *CachingDBClient) UpdateArticleStatus(id int, fromStatus, toStatus string, publishAt *time.Time) (bool, error) {
+	found, err := c.DBClient.UpdateArticleStatus(id, fromStatus, toStatus, publishAt)
+	c.invalidateArticle(id)
+	return found, err
+}
+
+//PublishScheduledArticleRows publishes the scheduled articles and, if any were, drops the whole cache
+func (c *CachingDBClient) PublishScheduledArticleRows(now time.Time) (int64, error) {
+	published, err := c.DBClient.PublishScheduledArticleRows(now)
+	if published > 0 || err != nil {
+		c.invalidateAll()
+	}
+	return published, err
+}
+
+//DeleteArticleByID deletes the article and drops it and the cached listings and tags
+func (c *CachingDBClient) DeleteArticleByID(id int) (bool, error) {
+	found, err := c.DBClient.DeleteArticleByID(id)
+	c.invalidateArticle(id)
+	return found, err
+}
+
+//RestoreArticleByID restores the article and drops the cached listings and tags it could appear in
+func (c *CachingDBClient) RestoreArticleByID(id int) (bool, error) {
+	found, err := c.DBClient.RestoreArticleByID(id)
+	c.invalidateArticle(id)
+	return found, err
+}
+
+//PurgeDeletedArticleRows purges deleted articles, which are never cached, and drops the cached listings and tags
+func (c *CachingDBClient) PurgeDeletedArticleRows(deletedBefore time.Time) (int64, error) {
+	purged, err := c.DBClient.PurgeDeletedArticleRows(deletedBefore)
+	if purged > 0 || err != nil {
+		c.invalidateListings()
+	}
+	return purged, err
+}
+
+//RenameTagRow renames the tag and drops the whole cache since every article carrying it shows the new name
+func (c *CachingDBClient) RenameTagRow(slug, name string) (bool, error) {
+	found, err := c.DBClient.RenameTagRow(slug, name)
+	c.invalidateAll()
+	return found, err
+}
+
+//MergeTagRows merges the tags and drops the whole cache since every article carrying the merged tag changes
+func (c *CachingDBClient) MergeTagRows(fromSlug, intoSlug string) (bool, error) {
+	found, err := c.DBClient.MergeTagRows(fromSlug, intoSlug)
+	c.invalidateAll()
+	return found, err
+}
+
+//AddTagAliasRow adds the alias and drops the cached listings and tags that could now resolve it
+func (c *CachingDBClient) AddTagAliasRow(slug, alias string) (bool, error) {
+	found, err := c.DBClient.AddTagAliasRow(slug, alias)
+	c.invalidateListings()
+	return found, err
+}
+
+//RemoveTagAliasRow removes the alias and drops the cached listings and tags that resolved it
+func (c *CachingDBClient) RemoveTagAliasRow(slug, alias string) (bool, error) {
+	found, err := c.DBClient.RemoveTagAliasRow(slug, alias)
+	c.invalidateListings()
+	return found, err
+}
+
+//SetTagParentRow sets the tag's parent and drops the cached listings and tags that include descendants
+func (c *CachingDBClient) SetTagParentRow(slug, parentSlug string) (bool, error) {
+	found, err := c.DBClient.SetTagParentRow(slug, parentSlug)
+	c.invalidateListings()
+	return found, err
+}
+
+//WithTx runs fn in a transaction of the wrapped client. fn gets a client of the wrapped client's transaction whose reads
+//skip the cache, so they see its own writes and nothing uncommitted is cached. The entries its writes change are dropped
+//once the transaction commits, nothing is dropped when it rolls back. Inside another WithTx they are dropped when the
+//surrounding transaction commits
+func (c *CachingDBClient) WithTx(ctx context.Context, fn func(tx DBClient) error) error {
+	var committed *txInvalidations
+	err := c.DBClient.WithTx(ctx, func(tx DBClient) error {
+		//a retried transaction runs fn again, only the writes of the attempt that commits count
+		pending := &txInvalidations{}
+		if err := fn(&CachingDBClient{DBClient: tx, Logger: c.Logger, cache: c.cache, tx: pending}); err != nil {
+			return err
+		}
+		committed = pending
+		return nil
+	})
+	if err == nil && committed != nil {
+		for _, match := range committed.matches {
+			c.remove("transaction committed", match)
+		}
+	}
+	return err
+}
+
+//Primary returns a client reading from the wrapped client's primary without looking in the cache, which may hold rows
+//read from a replica that hadn't caught up yet. What it reads is still cached and its writes drop cached reads as usual
+func (c *CachingDBClient) Primary() DBClient {
+	return &CachingDBClient{
+		DBClient:     c.DBClient.Primary(),
+		Logger:       c.Logger,
+		cache:        c.cache,
+		primaryReads: true,
+		tx:           c.tx,
+	}
+}
+
+//get looks the key up in the cache, counting the hit or miss. On a miss the generation returned has to be passed to set
+//so a row read while a write invalidated the cache isn't cached
+func (c *CachingDBClient) get(key string) (interface{}, uint64, bool) {
+	if c.tx != nil {
+		return nil, 0, false
+	}
+	if c.primaryReads {
+		return nil, c.cache.currentGeneration(), false
+	}
+	value, generation, ok := c.cache.get(key)
+	if ok {
+		atomic.AddUint64(&c.hits, 1)
+	} else {
+		atomic.AddUint64(&c.misses, 1)
+	}
+	return value, generation, ok
+}
+
+//set caches a value read through. Nothing read in a transaction is cached as it may not be committed
+func (c *CachingDBClient) set(key string, value interface{}, generation uint64) {
+	if c.tx != nil {
+		return
+	}
+	c.cache.set(key, value, generation)
+}
+
+//invalidateArticle drops the article and every cached listing and tag
+func (c *CachingDBClient) invalidateArticle(id int) {
+	key := fmt.Sprintf("%s%d", articleCacheKeyPrefix, id)
+	c.remove(fmt.Sprintf("article %d changed", id), func(k string) bool {
+		return k == key || !strings.HasPrefix(k, articleCacheKeyPrefix)
+	})
+}
+
+//invalidateNewArticles drops every cached tag and the cached listings of the new articles' days. Articles without a day
+//drop every listing
+func (c *CachingDBClient) invalidateNewArticles(articles []*models.Article) {
+	days := map[string]bool{}
+	for _, article := range articles {
+		if article.Day == "" {
+			c.invalidateListings()
+			return
+		}
+		days[article.Day] = true
+	}
+	c.remove("articles created", func(k string) bool {
+		if !strings.HasPrefix(k, tagAndDateCacheKeyPrefix) {
+			return !strings.HasPrefix(k, articleCacheKeyPrefix)
+		}
+		//tagdate keys end with the date and two flags
+		fields := strings.Split(k, "|")
+		return len(fields) < 4 || days[fields[len(fields)-3]]
+	})
+}
+
+//invalidateListings drops every cached listing and tag, keeping the articles cached by id
+func (c *CachingDBClient) invalidateListings() {
+	c.remove("listings changed", func(k string) bool {
+		return !strings.HasPrefix(k, articleCacheKeyPrefix)
+	})
+}
+
+//invalidateAll empties the cache
+func (c *CachingDBClient) invalidateAll() {
+	c.remove("everything may have changed", func(string) bool { return true })
+}
+
+//remove drops the entries whose key matches, or in a transaction keeps the match to drop them once it commits
+func (c *CachingDBClient) remove(reason string, match func(key string) bool) {
+	if c.tx != nil {
+		c.tx.mu.Lock()
+		c.tx.matches = append(c.tx.matches, match)
+		c.tx.mu.Unlock()
+		return
+	}
+	removed := c.cache.removeIf(match)
+	c.Logger.Debugf("CachingDBClient :: %s, dropped %d entries", reason, removed)
+}
+
+//copyArticle copies a cached article so callers can't change the cached one
+func copyArticle(article *models.Article) *models.Article {
+	copied := *article
+	copied.Tags = append([]string(nil), article.Tags...)
+	return &copied
+}
+
+//copyArticles copies cached articles so callers can't change the cached ones
+func copyArticles(articles *[]models.Article) *[]models.Article {
+	copied := make([]models.Article, len(*articles))
+	for i := range *articles {
+		copied[i] = *copyArticle(&(*articles)[i])
+	}
+	return &copied
+}
+
+//copyTag copies a cached tag so callers can't change the cached one
+func copyTag(tag *models.Tag) *models.Tag {
+	copied := *tag
+	copied.Aliases = append([]string(nil), tag.Aliases...)
+	return &copied
+}
+
+//lruCache is a least recently used cache whose entries also expire after ttl
+type lruCache struct {
+	size int
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu         sync.Mutex
+	entries    map[string]*list.Element
+	order      *list.List
+	evictions  uint64
+	generation uint64
+}
+
+type lruEntry struct {
+	key       string
+	value     interface{}
+	expiresAt time.Time
+}
+
+func newLRUCache(size int, ttl time.Duration) *lruCache {
+	return &lruCache{
+		size:    size,
+		ttl:     ttl,
+		now:     time.Now,
+		entries: map[string]*list.Element{},
+		order:   list.New(),
+	}
+}
+
+//get returns the value for the key if it is cached and hasn't expired, marking it as most recently used,
+//along with the cache's current generation
+func (l *lruCache) get(key string) (interface{}, uint64, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	element, ok := l.entries[key]
+	if !ok {
+		return nil, l.generation, false
+	}
+	entry := element.Value.(*lruEntry)
+	if !l.now().Before(entry.expiresAt) {
+		l.order.Remove(element)
+		delete(l.entries, key)
+		return nil, l.generation, false
+	}
+	l.order.MoveToFront(element)
+	return entry.value, l.generation, true
+}
+
+//set caches the value for the key, evicting the least recently used entry when the cache is full. Nothing is cached
+//if entries have been removed since the generation was read, as the value may have been read before the change
+func (l *lruCache) set(key string, value interface{}, generation uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if generation != l.generation {
+		return
+	}
+	expiresAt := l.now().Add(l.ttl)
+	if element, ok := l.entries[key]; ok {
+		entry := element.Value.(*lruEntry)
+		entry.value = value
+		entry.expiresAt = expiresAt
+		l.order.MoveToFront(element)
+		return
+	}
+
+	l.entries[key] = l.order.PushFront(&lruEntry{key: key, value: value, expiresAt: expiresAt})
+	for l.order.Len() > l.size {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.entries, oldest.Value.(*lruEntry).key)
+		l.evictions++
+	}
+}
+
+//currentGeneration returns the generation to pass to set for a value read without looking in the cache
+func (l *lruCache) currentGeneration() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.generation
+}
+
+//removeIf drops every entry whose key matches and returns how many were dropped
+func (l *lruCache) removeIf(match func(key string) bool) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.generation++
+	removed := 0
+	for key, element := range l.entries {
+		if match(key) {
+			l.order.Remove(element)
+			delete(l.entries, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+//stats returns how many entries are cached and how many have been evicted to make room
+func (l *lruCache) stats() (int, uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len(), l.evictions
+}
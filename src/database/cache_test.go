@@ -0,0 +1,212 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bmordt/article-api/src/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newCacheTestClient(size int) (*CachingDBClient, *DBClientMock) {
+	dbMock := &DBClientMock{
+		GetArticleRowByIDFunc: func(findID int) (*models.Article, error) {
+			if findID == 404 {
+				return nil, nil
+			}
+			return &models.Article{ID: strconv.Itoa(findID), Title: "cachedTitle", Tags: []string{"health"}}, nil
+		},
+		GetArticleRowByTagAndDateFunc: func(tag, date string, publishedOnly, includeDescendants bool) (*[]models.Article, error) {
+			return &[]models.Article{models.Article{ID: "1", Tags: []string{tag}}}, nil
+		},
+		GetTagRowBySlugFunc: func(slug string, publishedOnly bool) (*models.Tag, error) {
+			return &models.Tag{Slug: slug, Name: slug}, nil
+		},
+		DeleteArticleByIDFunc: func(id int) (bool, error) {
+			return true, nil
+		},
+		CreateArticleRowFunc: func(article *models.Article) (int, error) {
+			return 2, nil
+		},
+		RenameTagRowFunc: func(slug, name string) (bool, error) {
+			return true, nil
+		},
+	}
+	return NewCachingDBClient(dbMock, size, time.Minute, newTestLogger()), dbMock
+}
+
+func TestCachingDBClient(t *testing.T) {
+	t.Run("Given an article read twice, the second read is served from the cache", func(t *testing.T) {
+		client, dbMock := newCacheTestClient(10)
+
+		client.GetArticleRowByID(1)
+		article, err := client.GetArticleRowByID(1)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "cachedTitle", article.Title)
+		assert.Equal(t, 1, len(dbMock.GetArticleRowByIDCalls()))
+		stats := client.Stats()
+		assert.Equal(t, uint64(1), stats.Hits)
+		assert.Equal(t, uint64(1), stats.Misses)
+	})
+	t.Run("Given a cached article is changed by the caller, the cached copy is unchanged", func(t *testing.T) {
+		client, _ := newCacheTestClient(10)
+
+		article, _ := client.GetArticleRowByID(1)
+		article.Title = "changed"
+		article.Tags[0] = "changed"
+
+		article, _ = client.GetArticleRowByID(1)
+		assert.Equal(t, "cachedTitle", article.Title)
+		assert.Equal(t, []string{"health"}, article.Tags)
+	})
+	t.Run("Given a missing article, it is not cached", func(t *testing.T) {
+		client, dbMock := newCacheTestClient(10)
+
+		client.GetArticleRowByID(404)
+		client.GetArticleRowByID(404)
+
+		assert.Equal(t, 2, len(dbMock.GetArticleRowByIDCalls()))
+	})
+	t.Run("Given an expired entry, it is read through again", func(t *testing.T) {
+		client, dbMock := newCacheTestClient(10)
+		now := time.Now()
+		client.cache.now = func() time.Time { return now }
+
+		client.GetArticleRowByID(1)
+		now = now.Add(2 * time.Minute)
+		client.GetArticleRowByID(1)
+
+		assert.Equal(t, 2, len(dbMock.GetArticleRowByIDCalls()))
+	})
+	t.Run("Given the cache is full, the least recently used entry is evicted", func(t *testing.T) {
+		client, dbMock := newCacheTestClient(2)
+
+		client.GetArticleRowByID(1)
+		client.GetArticleRowByID(2)
+		client.GetArticleRowByID(1)
+		client.GetArticleRowByID(3)
+		client.GetArticleRowByID(1)
+		client.GetArticleRowByID(2)
+
+		assert.Equal(t, 4, len(dbMock.GetArticleRowByIDCalls()))
+		stats := client.Stats()
+		assert.Equal(t, 2, stats.Entries)
+		assert.Equal(t, uint64(2), stats.Evictions)
+	})
+	t.Run("Given an article is deleted, it and the listings are dropped but other articles stay cached", func(t *testing.T) {
+		client, dbMock := newCacheTestClient(10)
+
+		client.GetArticleRowByID(1)
+		client.GetArticleRowByID(2)
+		client.GetArticleRowByTagAndDate("health", "2016-09-22", true, false)
+		client.DeleteArticleByID(1)
+		client.GetArticleRowByID(1)
+		client.GetArticleRowByID(2)
+		client.GetArticleRowByTagAndDate("health", "2016-09-22", true, false)
+
+		assert.Equal(t, 3, len(dbMock.GetArticleRowByIDCalls()))
+		assert.Equal(t, 2, len(dbMock.GetArticleRowByTagAndDateCalls()))
+	})
+	t.Run("Given an article is created, the listings and tags are dropped", func(t *testing.T) {
+		client, dbMock := newCacheTestClient(10)
+
+		client.GetArticleRowByID(1)
+		client.GetTagRowBySlug("health", true)
+		client.CreateArticleRow(&models.Article{})
+		client.GetArticleRowByID(1)
+		client.GetTagRowBySlug("health", true)
+
+		assert.Equal(t, 1, len(dbMock.GetArticleRowByIDCalls()))
+		assert.Equal(t, 2, len(dbMock.GetTagRowBySlugCalls()))
+	})
+	t.Run("Given an article is created, only the listings of its day are dropped", func(t *testing.T) {
+		client, dbMock := newCacheTestClient(10)
+
+		client.GetArticleRowByTagAndDate("health", "2016-09-22", true, false)
+		client.GetArticleRowByTagAndDate("health", "2016-09-23", true, false)
+		client.CreateArticleRow(&models.Article{Day: "2016-09-22"})
+		client.GetArticleRowByTagAndDate("health", "2016-09-22", true, false)
+		client.GetArticleRowByTagAndDate("health", "2016-09-23", true, false)
+
+		assert.Equal(t, 3, len(dbMock.GetArticleRowByTagAndDateCalls()))
+	})
+	t.Run("Given a tag is renamed, the whole cache is dropped", func(t *testing.T) {
+		client, dbMock := newCacheTestClient(10)
+
+		client.GetArticleRowByID(1)
+		client.RenameTagRow("health", "Wellbeing")
+		client.GetArticleRowByID(1)
+
+		assert.Equal(t, 2, len(dbMock.GetArticleRowByIDCalls()))
+	})
+	t.Run("Given a transaction, its reads are not cached and nothing is dropped when it only reads", func(t *testing.T) {
+		client, dbMock := newCacheTestClient(10)
+		dbMock.WithTxFunc = func(ctx context.Context, fn func(tx DBClient) error) error {
+			return fn(dbMock)
+		}
+
+		client.GetArticleRowByID(1)
+		err := client.WithTx(context.Background(), func(tx DBClient) error {
+			_, err := tx.GetArticleRowByID(2)
+			return err
+		})
+		assert.NoError(t, err)
+		client.GetArticleRowByID(1)
+		client.GetArticleRowByID(2)
+
+		assert.Equal(t, 3, len(dbMock.GetArticleRowByIDCalls()))
+	})
+	t.Run("Given a transaction creates an article, the listings are only dropped once it commits", func(t *testing.T) {
+		client, dbMock := newCacheTestClient(10)
+		dbMock.WithTxFunc = func(ctx context.Context, fn func(tx DBClient) error) error {
+			return fn(dbMock)
+		}
+
+		client.GetArticleRowByID(1)
+		client.GetTagRowBySlug("health", true)
+		err := client.WithTx(context.Background(), func(tx DBClient) error {
+			_, err := tx.CreateArticleRow(&models.Article{Day: "2016-09-22"})
+			client.GetTagRowBySlug("health", true)
+			return err
+		})
+		assert.NoError(t, err)
+		client.GetArticleRowByID(1)
+		client.GetTagRowBySlug("health", true)
+
+		assert.Equal(t, 1, len(dbMock.GetArticleRowByIDCalls()))
+		assert.Equal(t, 2, len(dbMock.GetTagRowBySlugCalls()))
+	})
+	t.Run("Given a transaction that rolls back, nothing is dropped", func(t *testing.T) {
+		client, dbMock := newCacheTestClient(10)
+		dbMock.WithTxFunc = func(ctx context.Context, fn func(tx DBClient) error) error {
+			return fn(dbMock)
+		}
+
+		client.GetTagRowBySlug("health", true)
+		err := client.WithTx(context.Background(), func(tx DBClient) error {
+			tx.CreateArticleRow(&models.Article{Day: "2016-09-22"})
+			return errors.New("rolled back")
+		})
+		assert.Error(t, err)
+		client.GetTagRowBySlug("health", true)
+
+		assert.Equal(t, 1, len(dbMock.GetTagRowBySlugCalls()))
+	})
+	t.Run("Given a write while a read was in flight, the read is not cached", func(t *testing.T) {
+		client, dbMock := newCacheTestClient(10)
+		dbMock.GetArticleRowByIDFunc = func(findID int) (*models.Article, error) {
+			client.DeleteArticleByID(findID)
+			return &models.Article{ID: strconv.Itoa(findID)}, nil
+		}
+
+		client.GetArticleRowByID(1)
+		client.GetArticleRowByID(1)
+
+		assert.Equal(t, 2, len(dbMock.GetArticleRowByIDCalls()))
+	})
+}
@@ -0,0 +1,768 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bmordt/article-api/src/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//conformanceCase is one behaviour every DBClient has to share. Each case gets a client with nothing in it
+type conformanceCase struct {
+	name string
+	run  func(t *testing.T, client DBClient)
+}
+
+func TestMemoryDBClientConformance(t *testing.T) {
+	runConformanceSuite(t, func(t *testing.T) DBClient {
+		return NewMemoryDBClient(newTestLogger())
+	})
+}
+
+func TestSQLiteDBClientConformance(t *testing.T) {
+	runConformanceSuite(t, func(t *testing.T) DBClient {
+		client := NewSQLiteDBClient(":memory:", newTestLogger())
+		t.Cleanup(func() { client.DB.Close() })
+		return client
+	})
+}
+
+//runConformanceSuite runs every conformance case against a new client from newClient
+func runConformanceSuite(t *testing.T, newClient func(t *testing.T) DBClient) {
+	for _, c := range conformanceCases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			c.run(t, newClient(t))
+		})
+	}
+}
+
+var conformanceDate = mustParseDate("1991-01-01")
+
+func mustParseDate(date string) time.Time {
+	parsed, err := time.Parse(expectedDateFormatString, date)
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+func newConformanceArticle(title string, date time.Time, status string, tags ...string) *models.Article {
+	article := &models.Article{
+		Title:          title,
+		Date:           date,
+		Day:            date.Format(models.DayFormat),
+		Body:           title + " body",
+		Tags:           tags,
+		Excerpt:        title + " excerpt",
+		WordCount:      2,
+		ReadingMinutes: 1,
+		Status:         status,
+	}
+	if status == models.StatusPublished {
+		publishedAt := time.Now().UTC().Truncate(time.Second)
+		article.PublishedAt = &publishedAt
+	}
+	return article
+}
+
+//createConformanceArticle creates the article, failing the test if it can't be
+func createConformanceArticle(t *testing.T, client DBClient, article *models.Article) int {
+	id, err := client.CreateArticleRow(article)
+	require.NoError(t, err)
+	return id
+}
+
+func articleTitles(articles *[]models.Article) []string {
+	titles := []string{}
+	for _, article := range *articles {
+		titles = append(titles, article.Title)
+	}
+	return titles
+}
+
+func tagSlugs(tags *[]models.Tag) []string {
+	slugs := []string{}
+	for _, tag := range *tags {
+		slugs = append(slugs, tag.Slug)
+	}
+	return slugs
+}
+
+var conformanceCases = []conformanceCase{
+	{"Given an article it is created and read back by id with its tags as first written", func(t *testing.T, client DBClient) {
+		id := createConformanceArticle(t, client, newConformanceArticle("first", conformanceDate, models.StatusDraft, "Health", "health ", "Fitness"))
+		assert.NotZero(t, id)
+
+		article, err := client.GetArticleRowByID(id)
+		require.NoError(t, err)
+		require.NotNil(t, article)
+		assert.Equal(t, strconv.Itoa(id), article.ID)
+		assert.Equal(t, "first", article.Title)
+		assert.Equal(t, "first body", article.Body)
+		assert.True(t, conformanceDate.Equal(article.Date))
+		assert.Equal(t, "1991-01-01", article.Day)
+		assert.Equal(t, []string{"Health", "Fitness"}, article.Tags)
+		assert.Equal(t, "first excerpt", article.Excerpt)
+		assert.Equal(t, 2, article.WordCount)
+		assert.Equal(t, 1, article.ReadingMinutes)
+		assert.Equal(t, models.StatusDraft, article.Status)
+		assert.Nil(t, article.PublishedAt)
+		assert.Nil(t, article.DeletedAt)
+
+		missing, err := client.GetArticleRowByID(id + 1000)
+		assert.NoError(t, err)
+		assert.Nil(t, missing)
+	}},
+	{"Given many articles they are all created in one call with ids in order", func(t *testing.T, client DBClient) {
+		articles := []*models.Article{}
+		for i := 0; i < 3; i++ {
+			articles = append(articles, newConformanceArticle("bulk"+strconv.Itoa(i), conformanceDate, models.StatusDraft, "BulkTag"))
+		}
+
+		ids, err := client.CreateArticleRows(articles)
+		require.NoError(t, err)
+		require.Equal(t, 3, len(ids))
+		for i, id := range ids {
+			article, err := client.GetArticleRowByID(id)
+			require.NoError(t, err)
+			assert.Equal(t, "bulk"+strconv.Itoa(i), article.Title)
+			assert.Equal(t, []string{"BulkTag"}, article.Tags)
+		}
+	}},
+	{"Given a tag and date the live articles carrying it are returned newest first without bodies", func(t *testing.T, client DBClient) {
+		createConformanceArticle(t, client, newConformanceArticle("published", conformanceDate, models.StatusPublished, "Health"))
+		createConformanceArticle(t, client, newConformanceArticle("draft", conformanceDate, models.StatusDraft, "HEALTH"))
+		createConformanceArticle(t, client, newConformanceArticle("other date", mustParseDate("1991-01-02"), models.StatusPublished, "Health"))
+		createConformanceArticle(t, client, newConformanceArticle("other tag", conformanceDate, models.StatusPublished, "Fitness"))
+		deletedID := createConformanceArticle(t, client, newConformanceArticle("deleted", conformanceDate, models.StatusPublished, "Health"))
+		_, err := client.DeleteArticleByID(deletedID)
+		require.NoError(t, err)
+
+		articles, err := client.GetArticleRowByTagAndDate(" health", "1991-01-01", false, false)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"published", "draft"}, articleTitles(articles))
+		for _, article := range *articles {
+			assert.Equal(t, "", article.Body)
+			assert.Equal(t, article.Title+" excerpt", article.Excerpt)
+			assert.Equal(t, []string{"Health"}, article.Tags)
+		}
+
+		articles, err = client.GetArticleRowByTagAndDate("health", "1991-01-01", true, false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"published"}, articleTitles(articles))
+
+		articles, err = client.GetArticleRowByTagAndDate("missing", "1991-01-01", false, false)
+		require.NoError(t, err)
+		assert.Equal(t, 0, len(*articles))
+
+		_, err = client.GetArticleRowByTagAndDate("health", "not-a-date", false, false)
+		assert.Error(t, err)
+	}},
+	{"Given articles dated away from midnight they are found by their publication day whatever their time or timezone", func(t *testing.T, client DBClient) {
+		newYork, err := time.LoadLocation("America/New_York")
+		require.NoError(t, err)
+		//late in the evening in New York is already the next day in UTC
+		lateDate := time.Date(1991, 1, 1, 23, 30, 0, 0, newYork)
+		late := newConformanceArticle("late", lateDate, models.StatusPublished, "Health")
+		late.Day = "1991-01-01"
+		lateID := createConformanceArticle(t, client, late)
+		createConformanceArticle(t, client, newConformanceArticle("next day", mustParseDate("1991-01-02"), models.StatusPublished, "Health"))
+
+		article, err := client.GetArticleRowByID(lateID)
+		require.NoError(t, err)
+		assert.True(t, lateDate.Equal(article.Date))
+		assert.Equal(t, "1991-01-01", article.Day)
+
+		articles, err := client.GetArticleRowByTagAndDate("health", "1991-01-01", false, false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"late"}, articleTitles(articles))
+		articles, err = client.GetArticleRowByTagAndDate("health", "1991-01-02", false, false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"next day"}, articleTitles(articles))
+
+		titles := []string{}
+		err = client.ExportArticleRows(models.ArticleFilter{To: &conformanceDate}, func(article *models.Article) error {
+			titles = append(titles, article.Title)
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"late"}, titles)
+	}},
+	{"Given published articles the most recently published are returned for feeds", func(t *testing.T, client DBClient) {
+		older := newConformanceArticle("older", conformanceDate, models.StatusPublished, "Health")
+		olderPublishedAt := older.PublishedAt.Add(-time.Hour)
+		older.PublishedAt = &olderPublishedAt
+		createConformanceArticle(t, client, older)
+		createConformanceArticle(t, client, newConformanceArticle("newer", conformanceDate, models.StatusPublished, "Health"))
+		createConformanceArticle(t, client, newConformanceArticle("other tag", conformanceDate, models.StatusPublished, "Fitness"))
+		createConformanceArticle(t, client, newConformanceArticle("draft", conformanceDate, models.StatusDraft, "Health"))
+
+		articles, err := client.GetRecentArticleRows("Health", 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"newer", "older"}, articleTitles(articles))
+		assert.Equal(t, "", (*articles)[0].Body)
+
+		articles, err = client.GetRecentArticleRows("", 10)
+		require.NoError(t, err)
+		assert.Equal(t, 3, len(*articles))
+		assert.Equal(t, "older", (*articles)[2].Title)
+
+		articles, err = client.GetRecentArticleRows("", 1)
+		require.NoError(t, err)
+		assert.Equal(t, 1, len(*articles))
+	}},
+	{"Given a filter the export streams the matching live articles oldest first with their bodies", func(t *testing.T, client DBClient) {
+		createConformanceArticle(t, client, newConformanceArticle("first", conformanceDate, models.StatusPublished, "Health"))
+		createConformanceArticle(t, client, newConformanceArticle("draft", conformanceDate, models.StatusDraft, "Health"))
+		createConformanceArticle(t, client, newConformanceArticle("later", mustParseDate("1991-02-01"), models.StatusPublished, "Health"))
+		createConformanceArticle(t, client, newConformanceArticle("other tag", conformanceDate, models.StatusPublished, "Fitness"))
+
+		exported := []*models.Article{}
+		err := client.ExportArticleRows(models.ArticleFilter{}, func(article *models.Article) error {
+			exported = append(exported, article)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 4, len(exported))
+		assert.Equal(t, "first", exported[0].Title)
+		assert.Equal(t, "first body", exported[0].Body)
+
+		to := mustParseDate("1991-01-31")
+		titles := []string{}
+		err = client.ExportArticleRows(models.ArticleFilter{Tag: "HEALTH", From: &conformanceDate, To: &to, PublishedOnly: true}, func(article *models.Article) error {
+			titles = append(titles, article.Title)
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"first"}, titles)
+
+		stop := errors.New("stop")
+		calls := 0
+		err = client.ExportArticleRows(models.ArticleFilter{}, func(article *models.Article) error {
+			calls++
+			return stop
+		})
+		assert.Equal(t, stop, err)
+		assert.Equal(t, 1, calls)
+	}},
+	{"Given a status transition the article moves status only from the expected status", func(t *testing.T, client DBClient) {
+		id := createConformanceArticle(t, client, newConformanceArticle("workflow", conformanceDate, models.StatusDraft, "Health"))
+
+		moved, err := client.UpdateArticleStatus(id, models.StatusInReview, models.StatusPublished, nil)
+		require.NoError(t, err)
+		assert.False(t, moved)
+
+		moved, err = client.UpdateArticleStatus(id, models.StatusDraft, models.StatusPublished, nil)
+		require.NoError(t, err)
+		assert.True(t, moved)
+
+		article, err := client.GetArticleRowByID(id)
+		require.NoError(t, err)
+		assert.Equal(t, models.StatusPublished, article.Status)
+		require.NotNil(t, article.PublishedAt)
+		assert.WithinDuration(t, time.Now(), *article.PublishedAt, time.Minute)
+
+		moved, err = client.UpdateArticleStatus(id+1000, models.StatusDraft, models.StatusPublished, nil)
+		require.NoError(t, err)
+		assert.False(t, moved)
+	}},
+	{"Given a scheduled article past its publish time the scheduler publishes it", func(t *testing.T, client DBClient) {
+		id := createConformanceArticle(t, client, newConformanceArticle("scheduled", conformanceDate, models.StatusDraft, "Health"))
+		publishAt := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+
+		moved, err := client.UpdateArticleStatus(id, models.StatusDraft, models.StatusScheduled, &publishAt)
+		require.NoError(t, err)
+		require.True(t, moved)
+
+		published, err := client.PublishScheduledArticleRows(time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), published)
+
+		published, err = client.PublishScheduledArticleRows(publishAt.Add(time.Second))
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), published)
+
+		article, err := client.GetArticleRowByID(id)
+		require.NoError(t, err)
+		assert.Equal(t, models.StatusPublished, article.Status)
+		require.NotNil(t, article.PublishedAt)
+		assert.True(t, publishAt.Equal(*article.PublishedAt))
+	}},
+	{"Given an article is deleted it is hidden until restored and purged once past retention", func(t *testing.T, client DBClient) {
+		id := createConformanceArticle(t, client, newConformanceArticle("deleted", conformanceDate, models.StatusPublished, "Health"))
+		keptID := createConformanceArticle(t, client, newConformanceArticle("kept", conformanceDate, models.StatusPublished, "Health"))
+
+		deleted, err := client.DeleteArticleByID(id)
+		require.NoError(t, err)
+		assert.True(t, deleted)
+
+		deleted, err = client.DeleteArticleByID(id)
+		require.NoError(t, err)
+		assert.False(t, deleted)
+
+		article, err := client.GetArticleRowByID(id)
+		require.NoError(t, err)
+		assert.Nil(t, article)
+
+		deletedArticles, err := client.GetDeletedArticleRows()
+		require.NoError(t, err)
+		require.Equal(t, []string{"deleted"}, articleTitles(deletedArticles))
+		assert.NotNil(t, (*deletedArticles)[0].DeletedAt)
+		assert.Equal(t, "", (*deletedArticles)[0].Body)
+
+		restored, err := client.RestoreArticleByID(id)
+		require.NoError(t, err)
+		assert.True(t, restored)
+
+		restored, err = client.RestoreArticleByID(keptID)
+		require.NoError(t, err)
+		assert.False(t, restored)
+
+		_, err = client.DeleteArticleByID(id)
+		require.NoError(t, err)
+
+		purged, err := client.PurgeDeletedArticleRows(time.Now().Add(-time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), purged)
+
+		purged, err = client.PurgeDeletedArticleRows(time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), purged)
+
+		deletedArticles, err = client.GetDeletedArticleRows()
+		require.NoError(t, err)
+		assert.Equal(t, 0, len(*deletedArticles))
+
+		restored, err = client.RestoreArticleByID(id)
+		require.NoError(t, err)
+		assert.False(t, restored)
+	}},
+	{"Given tags in use they are listed with their article counts", func(t *testing.T, client DBClient) {
+		createConformanceArticle(t, client, newConformanceArticle("one", conformanceDate, models.StatusPublished, "Health", "Fitness"))
+		createConformanceArticle(t, client, newConformanceArticle("two", conformanceDate, models.StatusDraft, "Health", "Drafts"))
+		createConformanceArticle(t, client, newConformanceArticle("three", conformanceDate, models.StatusDraft, "Health"))
+
+		tags, total, err := client.GetTagRows(models.TagSortUsage, 2, 0, false)
+		require.NoError(t, err)
+		assert.Equal(t, 3, total)
+		assert.Equal(t, []string{"health", "drafts"}, tagSlugs(tags))
+		assert.Equal(t, "Health", (*tags)[0].Name)
+		assert.Equal(t, 3, (*tags)[0].ArticleCount)
+		assert.NotNil(t, (*tags)[0].LastUsedAt)
+		assert.Equal(t, []string{}, (*tags)[0].Aliases)
+
+		tags, total, err = client.GetTagRows(models.TagSortName, 10, 1, true)
+		require.NoError(t, err)
+		assert.Equal(t, 2, total)
+		assert.Equal(t, []string{"health"}, tagSlugs(tags))
+		assert.Equal(t, 1, (*tags)[0].ArticleCount)
+
+		_, _, err = client.GetTagRows("unknown", 10, 0, false)
+		assert.Error(t, err)
+
+		tag, err := client.GetTagRowBySlug("drafts", true)
+		require.NoError(t, err)
+		assert.Nil(t, tag)
+
+		tag, err = client.GetTagRowBySlug("drafts", false)
+		require.NoError(t, err)
+		require.NotNil(t, tag)
+		assert.Equal(t, 1, tag.ArticleCount)
+
+		tag, err = client.GetTagRowBySlug("missing", false)
+		require.NoError(t, err)
+		assert.Nil(t, tag)
+	}},
+	{"Given a prefix the tags starting with it are suggested by usage", func(t *testing.T, client DBClient) {
+		createConformanceArticle(t, client, newConformanceArticle("one", conformanceDate, models.StatusPublished, "Heart", "Health"))
+		createConformanceArticle(t, client, newConformanceArticle("two", conformanceDate, models.StatusDraft, "Health", "Help_Desk"))
+		_, err := client.AddTagAliasRow("heart", "cardio")
+		require.NoError(t, err)
+
+		suggestions, err := client.GetTagSuggestionRows("he", 10, false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"health", "heart", "help_desk"}, tagSlugs(suggestions))
+		assert.Equal(t, "Health", (*suggestions)[0].Name)
+		assert.Equal(t, 2, (*suggestions)[0].ArticleCount)
+
+		suggestions, err = client.GetTagSuggestionRows("he", 1, true)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"health"}, tagSlugs(suggestions))
+		assert.Equal(t, 1, (*suggestions)[0].ArticleCount)
+
+		suggestions, err = client.GetTagSuggestionRows("card", 10, false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"heart"}, tagSlugs(suggestions))
+
+		suggestions, err = client.GetTagSuggestionRows("he_", 10, false)
+		require.NoError(t, err)
+		assert.Equal(t, 0, len(*suggestions))
+	}},
+	{"Given tags used recently they trend and are related by the articles they share", func(t *testing.T, client DBClient) {
+		createConformanceArticle(t, client, newConformanceArticle("one", conformanceDate, models.StatusPublished, "Health", "Fitness"))
+		createConformanceArticle(t, client, newConformanceArticle("two", conformanceDate, models.StatusPublished, "Health", "Food"))
+		createConformanceArticle(t, client, newConformanceArticle("three", conformanceDate, models.StatusDraft, "Health", "Food"))
+
+		trending, err := client.GetTrendingTagRows(7, 10, false)
+		require.NoError(t, err)
+		require.Equal(t, 3, len(*trending))
+		assert.Equal(t, "health", (*trending)[0].Slug)
+		assert.Equal(t, 3, (*trending)[0].CurrentCount)
+		assert.Equal(t, 0, (*trending)[0].PreviousCount)
+
+		trending, err = client.GetTrendingTagRows(7, 10, true)
+		require.NoError(t, err)
+		assert.Equal(t, 2, (*trending)[0].CurrentCount)
+
+		related, err := client.GetRelatedTagRows("health", 30, 10, false)
+		require.NoError(t, err)
+		require.Equal(t, 2, len(*related))
+		assert.Equal(t, "food", (*related)[0].Slug)
+		assert.Equal(t, 2, (*related)[0].Count)
+		assert.InDelta(t, 2.0/3.0, (*related)[0].Jaccard, 0.0001)
+		assert.Equal(t, "fitness", (*related)[1].Slug)
+		assert.InDelta(t, 1.0/3.0, (*related)[1].Jaccard, 0.0001)
+
+		related, err = client.GetRelatedTagRows("food", 30, 10, true)
+		require.NoError(t, err)
+		require.Equal(t, 1, len(*related))
+		assert.Equal(t, "health", (*related)[0].Slug)
+		assert.Equal(t, 0.5, (*related)[0].Jaccard)
+
+		related, err = client.GetRelatedTagRows("missing", 30, 10, false)
+		require.NoError(t, err)
+		assert.Equal(t, 0, len(*related))
+	}},
+	{"Given a tag rename every article carrying it shows the new name", func(t *testing.T, client DBClient) {
+		id := createConformanceArticle(t, client, newConformanceArticle("one", conformanceDate, models.StatusDraft, "Health", "Fitness"))
+
+		renamed, err := client.RenameTagRow("fitness", "HEALTH")
+		assert.Equal(t, ErrTagExists, err)
+		assert.False(t, renamed)
+
+		renamed, err = client.RenameTagRow("missing", "Anything")
+		require.NoError(t, err)
+		assert.False(t, renamed)
+
+		renamed, err = client.RenameTagRow("fitness", "Exercise Science")
+		require.NoError(t, err)
+		assert.True(t, renamed)
+
+		article, err := client.GetArticleRowByID(id)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Health", "Exercise Science"}, article.Tags)
+
+		tag, err := client.GetTagRowBySlug("exercise-science", false)
+		require.NoError(t, err)
+		require.NotNil(t, tag)
+		assert.Equal(t, 1, tag.ArticleCount)
+	}},
+	{"Given a tag rename to one of its aliases the alias is dropped, and to another tag's alias it is refused", func(t *testing.T, client DBClient) {
+		createConformanceArticle(t, client, newConformanceArticle("one", conformanceDate, models.StatusDraft, "Health", "Fitness"))
+		_, err := client.AddTagAliasRow("health", "wellbeing")
+		require.NoError(t, err)
+
+		renamed, err := client.RenameTagRow("fitness", "Wellbeing")
+		assert.Equal(t, ErrTagExists, err)
+		assert.False(t, renamed)
+
+		renamed, err = client.RenameTagRow("health", "Wellbeing")
+		require.NoError(t, err)
+		assert.True(t, renamed)
+
+		tag, err := client.GetTagRowBySlug("wellbeing", false)
+		require.NoError(t, err)
+		require.NotNil(t, tag)
+		assert.Equal(t, "wellbeing", tag.Slug)
+		assert.Equal(t, []string{}, tag.Aliases)
+	}},
+	{"Given a tag merge its articles move to the other tag and its slug becomes an alias", func(t *testing.T, client DBClient) {
+		bothID := createConformanceArticle(t, client, newConformanceArticle("both", conformanceDate, models.StatusDraft, "Fitness", "Health", "Food"))
+		fromID := createConformanceArticle(t, client, newConformanceArticle("from", conformanceDate, models.StatusDraft, "Food", "Fitness"))
+		_, err := client.AddTagAliasRow("fitness", "exercise")
+		require.NoError(t, err)
+
+		merged, err := client.MergeTagRows("fitness", "missing")
+		require.NoError(t, err)
+		assert.False(t, merged)
+
+		merged, err = client.MergeTagRows("fitness", "health")
+		require.NoError(t, err)
+		assert.True(t, merged)
+
+		article, err := client.GetArticleRowByID(bothID)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Health", "Food"}, article.Tags)
+
+		article, err = client.GetArticleRowByID(fromID)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Food", "Health"}, article.Tags)
+
+		tag, err := client.GetTagRowBySlug("fitness", false)
+		require.NoError(t, err)
+		require.NotNil(t, tag)
+		assert.Equal(t, "health", tag.Slug)
+		assert.Equal(t, []string{"exercise", "fitness"}, tag.Aliases)
+		assert.Equal(t, 2, tag.ArticleCount)
+
+		_, total, err := client.GetTagRows(models.TagSortName, 10, 0, false)
+		require.NoError(t, err)
+		assert.Equal(t, 2, total)
+	}},
+	{"Given a tag merge the merged tag's children move under the other tag without making a loop", func(t *testing.T, client DBClient) {
+		createConformanceArticle(t, client, newConformanceArticle("one", conformanceDate, models.StatusDraft, "Science", "Physics", "Chemistry", "Top"))
+		for slug, parent := range map[string]string{"science": "top", "physics": "science", "chemistry": "science"} {
+			_, err := client.SetTagParentRow(slug, parent)
+			require.NoError(t, err)
+		}
+
+		merged, err := client.MergeTagRows("science", "physics")
+		require.NoError(t, err)
+		assert.True(t, merged)
+
+		tag, err := client.GetTagRowBySlug("physics", false)
+		require.NoError(t, err)
+		assert.Equal(t, "top", tag.Parent)
+
+		tag, err = client.GetTagRowBySlug("chemistry", false)
+		require.NoError(t, err)
+		assert.Equal(t, "physics", tag.Parent)
+	}},
+	{"Given an alias it resolves to its tag on write and on query", func(t *testing.T, client DBClient) {
+		createConformanceArticle(t, client, newConformanceArticle("one", conformanceDate, models.StatusDraft, "Artificial Intelligence", "Health"))
+
+		added, err := client.AddTagAliasRow("artificial-intelligence", "health")
+		assert.Equal(t, ErrTagExists, err)
+		assert.False(t, added)
+
+		added, err = client.AddTagAliasRow("missing", "ai")
+		require.NoError(t, err)
+		assert.False(t, added)
+
+		added, err = client.AddTagAliasRow("artificial-intelligence", "ai")
+		require.NoError(t, err)
+		assert.True(t, added)
+
+		added, err = client.AddTagAliasRow("artificial-intelligence", "ai")
+		require.NoError(t, err)
+		assert.True(t, added)
+
+		added, err = client.AddTagAliasRow("health", "ai")
+		assert.Equal(t, ErrTagExists, err)
+		assert.False(t, added)
+
+		id := createConformanceArticle(t, client, newConformanceArticle("two", conformanceDate, models.StatusDraft, "AI", "Artificial Intelligence"))
+		article, err := client.GetArticleRowByID(id)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Artificial Intelligence"}, article.Tags)
+
+		articles, err := client.GetArticleRowByTagAndDate("ai", "1991-01-01", false, false)
+		require.NoError(t, err)
+		assert.Equal(t, 2, len(*articles))
+
+		tag, err := client.GetTagRowBySlug("ai", false)
+		require.NoError(t, err)
+		require.NotNil(t, tag)
+		assert.Equal(t, "artificial-intelligence", tag.Slug)
+		assert.Equal(t, []string{"ai"}, tag.Aliases)
+
+		removed, err := client.RemoveTagAliasRow("health", "ai")
+		require.NoError(t, err)
+		assert.False(t, removed)
+
+		removed, err = client.RemoveTagAliasRow("artificial-intelligence", "ai")
+		require.NoError(t, err)
+		assert.True(t, removed)
+
+		tag, err = client.GetTagRowBySlug("ai", false)
+		require.NoError(t, err)
+		assert.Nil(t, tag)
+	}},
+	{"Given a tag below another asking for the parent can include its articles", func(t *testing.T, client DBClient) {
+		createConformanceArticle(t, client, newConformanceArticle("science", conformanceDate, models.StatusDraft, "Science"))
+		createConformanceArticle(t, client, newConformanceArticle("physics", conformanceDate, models.StatusDraft, "Physics"))
+		createConformanceArticle(t, client, newConformanceArticle("quantum", conformanceDate, models.StatusDraft, "Quantum"))
+
+		found, err := client.SetTagParentRow("physics", "science")
+		require.NoError(t, err)
+		assert.True(t, found)
+		found, err = client.SetTagParentRow("quantum", "physics")
+		require.NoError(t, err)
+		assert.True(t, found)
+
+		found, err = client.SetTagParentRow("science", "quantum")
+		assert.Equal(t, ErrTagCycle, err)
+		assert.False(t, found)
+
+		found, err = client.SetTagParentRow("science", "science")
+		assert.Equal(t, ErrTagCycle, err)
+		assert.False(t, found)
+
+		found, err = client.SetTagParentRow("science", "missing")
+		require.NoError(t, err)
+		assert.False(t, found)
+
+		tag, err := client.GetTagRowBySlug("quantum", false)
+		require.NoError(t, err)
+		assert.Equal(t, "physics", tag.Parent)
+
+		articles, err := client.GetArticleRowByTagAndDate("science", "1991-01-01", false, true)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"science", "physics", "quantum"}, articleTitles(articles))
+
+		articles, err = client.GetArticleRowByTagAndDate("science", "1991-01-01", false, false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"science"}, articleTitles(articles))
+
+		found, err = client.SetTagParentRow("physics", "")
+		require.NoError(t, err)
+		assert.True(t, found)
+
+		tag, err = client.GetTagRowBySlug("physics", false)
+		require.NoError(t, err)
+		assert.Equal(t, "", tag.Parent)
+	}},
+	{"Given a content hash the live article with it on the day is found", func(t *testing.T, client DBClient) {
+		hashed := newConformanceArticle("hashed", conformanceDate, models.StatusDraft)
+		hashed.ContentHash, hashed.Simhash = "hash", -42
+		id := createConformanceArticle(t, client, hashed)
+		deleted := newConformanceArticle("deleted", conformanceDate, models.StatusDraft)
+		deleted.ContentHash, deleted.Simhash = "deleted hash", 7
+		deletedID := createConformanceArticle(t, client, deleted)
+		_, err := client.DeleteArticleByID(deletedID)
+		require.NoError(t, err)
+
+		found, err := client.GetArticleIDByContentHash("hash", "1991-01-01")
+		require.NoError(t, err)
+		assert.Equal(t, id, found)
+
+		found, err = client.GetArticleIDByContentHash("hash", "1991-01-02")
+		require.NoError(t, err)
+		assert.Zero(t, found, "another day is not a duplicate")
+
+		found, err = client.GetArticleIDByContentHash("deleted hash", "1991-01-01")
+		require.NoError(t, err)
+		assert.Zero(t, found, "a deleted article is not a duplicate")
+	}},
+	{"Given a range of days the fingerprints of live articles with a simhash are returned", func(t *testing.T, client DBClient) {
+		inRange := newConformanceArticle("in range", conformanceDate, models.StatusDraft)
+		inRange.ContentHash, inRange.Simhash = "in range hash", -42
+		id := createConformanceArticle(t, client, inRange)
+		outOfRange := newConformanceArticle("out of range", mustParseDate("1991-02-01"), models.StatusDraft)
+		outOfRange.ContentHash, outOfRange.Simhash = "out of range hash", 1
+		createConformanceArticle(t, client, outOfRange)
+		createConformanceArticle(t, client, newConformanceArticle("no hash", conformanceDate, models.StatusDraft))
+
+		fingerprints, err := client.GetArticleFingerprintRows("1990-12-25", "1991-01-08")
+		require.NoError(t, err)
+		assert.Equal(t, []models.ArticleFingerprint{{ID: strconv.Itoa(id), Title: "in range", Day: "1991-01-01", Simhash: -42}}, *fingerprints)
+	}},
+	{"Given an idempotency key it is held by the first request until it expires or is released", func(t *testing.T, client DBClient) {
+		now := time.Now().UTC()
+		expiresAt := now.Add(time.Hour)
+
+		stored, err := client.ReserveIdempotencyKey("key", "hash", now, expiresAt)
+		require.NoError(t, err)
+		assert.Nil(t, stored)
+
+		stored, err = client.ReserveIdempotencyKey("key", "other hash", now, expiresAt)
+		require.NoError(t, err)
+		assert.Equal(t, &models.IdempotentResponse{RequestHash: "hash"}, stored)
+
+		response := &models.IdempotentResponse{StatusCode: 201, ContentType: "application/json", Body: []byte(`{"id":"1"}`)}
+		require.NoError(t, client.SaveIdempotentResponse("key", response))
+		stored, err = client.ReserveIdempotencyKey("key", "hash", now, expiresAt)
+		require.NoError(t, err)
+		assert.Equal(t, &models.IdempotentResponse{RequestHash: "hash", StatusCode: 201, ContentType: "application/json", Body: []byte(`{"id":"1"}`)}, stored)
+
+		stored, err = client.ReserveIdempotencyKey("key", "other hash", expiresAt, expiresAt.Add(time.Hour))
+		require.NoError(t, err)
+		assert.Nil(t, stored, "an expired key is reserved again")
+
+		require.NoError(t, client.ReleaseIdempotencyKey("key"))
+		stored, err = client.ReserveIdempotencyKey("key", "hash", now, expiresAt)
+		require.NoError(t, err)
+		assert.Nil(t, stored, "a released key is reserved again")
+	}},
+	{"Given a transaction its writes are seen inside it and only kept once it commits", func(t *testing.T, client DBClient) {
+		var id int
+		err := client.WithTx(context.Background(), func(tx DBClient) error {
+			id = createConformanceArticle(t, tx, newConformanceArticle("committed", conformanceDate, models.StatusDraft, "Health"))
+			article, err := tx.GetArticleRowByID(id)
+			require.NoError(t, err)
+			require.NotNil(t, article)
+			assert.Equal(t, []string{"Health"}, article.Tags)
+
+			moved, err := tx.UpdateArticleStatus(id, models.StatusDraft, models.StatusPublished, nil)
+			require.NoError(t, err)
+			assert.True(t, moved)
+			return nil
+		})
+		require.NoError(t, err)
+
+		article, err := client.GetArticleRowByID(id)
+		require.NoError(t, err)
+		require.NotNil(t, article)
+		assert.Equal(t, models.StatusPublished, article.Status)
+
+		failed := errors.New("failed")
+		err = client.WithTx(context.Background(), func(tx DBClient) error {
+			createConformanceArticle(t, tx, newConformanceArticle("rolled back", conformanceDate, models.StatusDraft, "Fitness"))
+			_, err := tx.RenameTagRow("health", "Wellbeing")
+			require.NoError(t, err)
+			return failed
+		})
+		assert.Equal(t, failed, err)
+
+		articles, err := client.GetArticleRowByTagAndDate("health", "1991-01-01", false, false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"committed"}, articleTitles(articles))
+
+		tag, err := client.GetTagRowBySlug("fitness", false)
+		require.NoError(t, err)
+		assert.Nil(t, tag)
+	}},
+	{"Given a transaction inside another only its own writes are undone when it fails", func(t *testing.T, client DBClient) {
+		failed := errors.New("failed")
+		err := client.WithTx(context.Background(), func(tx DBClient) error {
+			createConformanceArticle(t, tx, newConformanceArticle("outer", conformanceDate, models.StatusDraft, "Health"))
+
+			err := tx.WithTx(context.Background(), func(inner DBClient) error {
+				createConformanceArticle(t, inner, newConformanceArticle("inner", conformanceDate, models.StatusDraft, "Health"))
+				return failed
+			})
+			assert.Equal(t, failed, err)
+
+			return tx.WithTx(context.Background(), func(inner DBClient) error {
+				_, err := inner.AddTagAliasRow("health", "wellbeing")
+				return err
+			})
+		})
+		require.NoError(t, err)
+
+		articles, err := client.GetArticleRowByTagAndDate("wellbeing", "1991-01-01", false, false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"outer"}, articleTitles(articles))
+	}},
+	{"Given a cancelled context the transaction is not committed", func(t *testing.T, client DBClient) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := client.WithTx(ctx, func(tx DBClient) error {
+			_, err := tx.CreateArticleRow(newConformanceArticle("cancelled", conformanceDate, models.StatusDraft, "Health"))
+			return err
+		})
+		assert.Error(t, err)
+
+		articles, err := client.GetArticleRowByTagAndDate("health", "1991-01-01", false, false)
+		require.NoError(t, err)
+		assert.Equal(t, 0, len(*articles))
+	}},
+}
@@ -4,6 +4,7 @@
 package database
 
 import (
+	"context"
 	"github.com/bmordt/article-api/src/models"
 	"sync"
 	"time"
@@ -19,18 +20,93 @@ var _ DBClient = &DBClientMock{}
 //
 // 		// make and configure a mocked DBClient
 // 		mockedDBClient := &DBClientMock{
-// 			CreateArticleRowFunc: func(title string, body string, date time.Time, tags []string) (int, error) {
+// 			AddTagAliasRowFunc: func(slug string, alias string) (bool, error) {
+// 				panic("mock out the AddTagAliasRow method")
+// 			},
+// 			CreateArticleRowFunc: func(article *models.Article) (int, error) {
 // 				panic("mock out the CreateArticleRow method")
 // 			},
-// 			DeleteArticleByIDFunc: func(id int) error {
+// 			CreateArticleRowsFunc: func(articles []*models.Article) ([]int, error) {
+// 				panic("mock out the CreateArticleRows method")
+// 			},
+// 			DeleteArticleByIDFunc: func(id int) (bool, error) {
 // 				panic("mock out the DeleteArticleByID method")
 // 			},
+// 			ExportArticleRowsFunc: func(filter models.ArticleFilter, fn func(article *models.Article) error) error {
+// 				panic("mock out the ExportArticleRows method")
+// 			},
+// 			GetArticleFingerprintRowsFunc: func(fromDay string, toDay string) (*[]models.ArticleFingerprint, error) {
+// 				panic("mock out the GetArticleFingerprintRows method")
+// 			},
+// 			GetArticleIDByContentHashFunc: func(contentHash string, day string) (int, error) {
+// 				panic("mock out the GetArticleIDByContentHash method")
+// 			},
 // 			GetArticleRowByIDFunc: func(findID int) (*models.Article, error) {
 // 				panic("mock out the GetArticleRowByID method")
 // 			},
-// 			GetArticleRowByTagAndDateFunc: func(tag string, date string) (*[]models.Article, error) {
+// 			GetArticleRowByTagAndDateFunc: func(tag string, date string, publishedOnly bool, includeDescendants bool) (*[]models.Article, error) {
 // 				panic("mock out the GetArticleRowByTagAndDate method")
 // 			},
+// 			GetDeletedArticleRowsFunc: func() (*[]models.Article, error) {
+// 				panic("mock out the GetDeletedArticleRows method")
+// 			},
+// 			GetRecentArticleRowsFunc: func(tag string, limit int) (*[]models.Article, error) {
+// 				panic("mock out the GetRecentArticleRows method")
+// 			},
+// 			GetRelatedTagRowsFunc: func(slug string, days int, limit int, publishedOnly bool) (*[]models.RelatedTag, error) {
+// 				panic("mock out the GetRelatedTagRows method")
+// 			},
+// 			GetTagRowBySlugFunc: func(slug string, publishedOnly bool) (*models.Tag, error) {
+// 				panic("mock out the GetTagRowBySlug method")
+// 			},
+// 			GetTagRowsFunc: func(sortBy string, limit int, offset int, publishedOnly bool) (*[]models.Tag, int, error) {
+// 				panic("mock out the GetTagRows method")
+// 			},
+// 			GetTagSuggestionRowsFunc: func(prefix string, limit int, publishedOnly bool) (*[]models.Tag, error) {
+// 				panic("mock out the GetTagSuggestionRows method")
+// 			},
+// 			GetTrendingTagRowsFunc: func(days int, limit int, publishedOnly bool) (*[]models.TrendingTag, error) {
+// 				panic("mock out the GetTrendingTagRows method")
+// 			},
+// 			MergeTagRowsFunc: func(fromSlug string, intoSlug string) (bool, error) {
+// 				panic("mock out the MergeTagRows method")
+// 			},
+// 			PrimaryFunc: func() DBClient {
+// 				panic("mock out the Primary method")
+// 			},
+// 			PublishScheduledArticleRowsFunc: func(now time.Time) (int64, error) {
+// 				panic("mock out the PublishScheduledArticleRows method")
+// 			},
+// 			PurgeDeletedArticleRowsFunc: func(deletedBefore time.Time) (int64, error) {
+// 				panic("mock out the PurgeDeletedArticleRows method")
+// 			},
+// 			ReleaseIdempotencyKeyFunc: func(key string) error {
+// 				panic("mock out the ReleaseIdempotencyKey method")
+// 			},
+// 			RemoveTagAliasRowFunc: func(slug string, alias string) (bool, error) {
+// 				panic("mock out the RemoveTagAliasRow method")
+// 			},
+// 			RenameTagRowFunc: func(slug string, name string) (bool, error) {
+// 				panic("mock out the RenameTagRow method")
+// 			},
+// 			ReserveIdempotencyKeyFunc: func(key string, requestHash string, now time.Time, expiresAt time.Time) (*models.IdempotentResponse, error) {
+// 				panic("mock out the ReserveIdempotencyKey method")
+// 			},
+// 			RestoreArticleByIDFunc: func(id int) (bool, error) {
+// 				panic("mock out the RestoreArticleByID method")
+// 			},
+// 			SaveIdempotentResponseFunc: func(key string, response *models.IdempotentResponse) error {
+// 				panic("mock out the SaveIdempotentResponse method")
+// 			},
+// 			SetTagParentRowFunc: func(slug string, parentSlug string) (bool, error) {
+// 				panic("mock out the SetTagParentRow method")
+// 			},
+// 			UpdateArticleStatusFunc: func(id int, fromStatus string, toStatus string, publishAt *time.Time) (bool, error) {
+// 				panic("mock out the UpdateArticleStatus method")
+// 			},
+// 			WithTxFunc: func(ctx context.Context, fn func(tx DBClient) error) error {
+// 				panic("mock out the WithTx method")
+// 			},
 // 		}
 //
 // 		// use mockedDBClient in code that requires DBClient
@@ -38,36 +114,138 @@ var _ DBClient = &DBClientMock{}
 //
 // 	}
 type DBClientMock struct {
+	// AddTagAliasRowFunc mocks the AddTagAliasRow method.
+	AddTagAliasRowFunc func(slug string, alias string) (bool, error)
+
 	// CreateArticleRowFunc mocks the CreateArticleRow method.
-	CreateArticleRowFunc func(title string, body string, date time.Time, tags []string) (int, error)
+	CreateArticleRowFunc func(article *models.Article) (int, error)
+
+	// CreateArticleRowsFunc mocks the CreateArticleRows method.
+	CreateArticleRowsFunc func(articles []*models.Article) ([]int, error)
 
 	// DeleteArticleByIDFunc mocks the DeleteArticleByID method.
-	DeleteArticleByIDFunc func(id int) error
+	DeleteArticleByIDFunc func(id int) (bool, error)
+
+	// ExportArticleRowsFunc mocks the ExportArticleRows method.
+	ExportArticleRowsFunc func(filter models.ArticleFilter, fn func(article *models.Article) error) error
+
+	// GetArticleFingerprintRowsFunc mocks the GetArticleFingerprintRows method.
+	GetArticleFingerprintRowsFunc func(fromDay string, toDay string) (*[]models.ArticleFingerprint, error)
+
+	// GetArticleIDByContentHashFunc mocks the GetArticleIDByContentHash method.
+	GetArticleIDByContentHashFunc func(contentHash string, day string) (int, error)
 
 	// GetArticleRowByIDFunc mocks the GetArticleRowByID method.
 	GetArticleRowByIDFunc func(findID int) (*models.Article, error)
 
 	// GetArticleRowByTagAndDateFunc mocks the GetArticleRowByTagAndDate method.
-	GetArticleRowByTagAndDateFunc func(tag string, date string) (*[]models.Article, error)
+	GetArticleRowByTagAndDateFunc func(tag string, date string, publishedOnly bool, includeDescendants bool) (*[]models.Article, error)
+
+	// GetDeletedArticleRowsFunc mocks the GetDeletedArticleRows method.
+	GetDeletedArticleRowsFunc func() (*[]models.Article, error)
+
+	// GetRecentArticleRowsFunc mocks the GetRecentArticleRows method.
+	GetRecentArticleRowsFunc func(tag string, limit int) (*[]models.Article, error)
+
+	// GetRelatedTagRowsFunc mocks the GetRelatedTagRows method.
+	GetRelatedTagRowsFunc func(slug string, days int, limit int, publishedOnly bool) (*[]models.RelatedTag, error)
+
+	// GetTagRowBySlugFunc mocks the GetTagRowBySlug method.
+	GetTagRowBySlugFunc func(slug string, publishedOnly bool) (*models.Tag, error)
+
+	// GetTagRowsFunc mocks the GetTagRows method.
+	GetTagRowsFunc func(sortBy string, limit int, offset int, publishedOnly bool) (*[]models.Tag, int, error)
+
+	// GetTagSuggestionRowsFunc mocks the GetTagSuggestionRows method.
+	GetTagSuggestionRowsFunc func(prefix string, limit int, publishedOnly bool) (*[]models.Tag, error)
+
+	// GetTrendingTagRowsFunc mocks the GetTrendingTagRows method.
+	GetTrendingTagRowsFunc func(days int, limit int, publishedOnly bool) (*[]models.TrendingTag, error)
+
+	// MergeTagRowsFunc mocks the MergeTagRows method.
+	MergeTagRowsFunc func(fromSlug string, intoSlug string) (bool, error)
+
+	// PrimaryFunc mocks the Primary method.
+	PrimaryFunc func() DBClient
+
+	// PublishScheduledArticleRowsFunc mocks the PublishScheduledArticleRows method.
+	PublishScheduledArticleRowsFunc func(now time.Time) (int64, error)
+
+	// PurgeDeletedArticleRowsFunc mocks the PurgeDeletedArticleRows method.
+	PurgeDeletedArticleRowsFunc func(deletedBefore time.Time) (int64, error)
+
+	// ReleaseIdempotencyKeyFunc mocks the ReleaseIdempotencyKey method.
+	ReleaseIdempotencyKeyFunc func(key string) error
+
+	// RemoveTagAliasRowFunc mocks the RemoveTagAliasRow method.
+	RemoveTagAliasRowFunc func(slug string, alias string) (bool, error)
+
+	// RenameTagRowFunc mocks the RenameTagRow method.
+	RenameTagRowFunc func(slug string, name string) (bool, error)
+
+	// ReserveIdempotencyKeyFunc mocks the ReserveIdempotencyKey method.
+	ReserveIdempotencyKeyFunc func(key string, requestHash string, now time.Time, expiresAt time.Time) (*models.IdempotentResponse, error)
+
+	// RestoreArticleByIDFunc mocks the RestoreArticleByID method.
+	RestoreArticleByIDFunc func(id int) (bool, error)
+
+	// SaveIdempotentResponseFunc mocks the SaveIdempotentResponse method.
+	SaveIdempotentResponseFunc func(key string, response *models.IdempotentResponse) error
+
+	// SetTagParentRowFunc mocks the SetTagParentRow method.
+	SetTagParentRowFunc func(slug string, parentSlug string) (bool, error)
+
+	// UpdateArticleStatusFunc mocks the UpdateArticleStatus method.
+	UpdateArticleStatusFunc func(id int, fromStatus string, toStatus string, publishAt *time.Time) (bool, error)
+
+	// WithTxFunc mocks the WithTx method.
+	WithTxFunc func(ctx context.Context, fn func(tx DBClient) error) error
 
 	// calls tracks calls to the methods.
 	calls struct {
+		// AddTagAliasRow holds details about calls to the AddTagAliasRow method.
+		AddTagAliasRow []struct {
+			// Slug is the slug argument value.
+			Slug string
+			// Alias is the alias argument value.
+			Alias string
+		}
 		// CreateArticleRow holds details about calls to the CreateArticleRow method.
 		CreateArticleRow []struct {
-			// Title is the title argument value.
-			Title string
-			// Body is the body argument value.
-			Body string
-			// Date is the date argument value.
-			Date time.Time
-			// Tags is the tags argument value.
-			Tags []string
+			// Article is the article argument value.
+			Article *models.Article
+		}
+		// CreateArticleRows holds details about calls to the CreateArticleRows method.
+		CreateArticleRows []struct {
+			// Articles is the articles argument value.
+			Articles []*models.Article
 		}
 		// DeleteArticleByID holds details about calls to the DeleteArticleByID method.
 		DeleteArticleByID []struct {
 			// ID is the id argument value.
 			ID int
 		}
+		// ExportArticleRows holds details about calls to the ExportArticleRows method.
+		ExportArticleRows []struct {
+			// Filter is the filter argument value.
+			Filter models.ArticleFilter
+			// Fn is the fn argument value.
+			Fn func(article *models.Article) error
+		}
+		// GetArticleFingerprintRows holds details about calls to the GetArticleFingerprintRows method.
+		GetArticleFingerprintRows []struct {
+			// FromDay is the fromDay argument value.
+			FromDay string
+			// ToDay is the toDay argument value.
+			ToDay string
+		}
+		// GetArticleIDByContentHash holds details about calls to the GetArticleIDByContentHash method.
+		GetArticleIDByContentHash []struct {
+			// ContentHash is the contentHash argument value.
+			ContentHash string
+			// Day is the day argument value.
+			Day string
+		}
 		// GetArticleRowByID holds details about calls to the GetArticleRowByID method.
 		GetArticleRowByID []struct {
 			// FindID is the findID argument value.
@@ -79,50 +257,246 @@ type DBClientMock struct {
 			Tag string
 			// Date is the date argument value.
 			Date string
+			// PublishedOnly is the publishedOnly argument value.
+			PublishedOnly bool
+			// IncludeDescendants is the includeDescendants argument value.
+			IncludeDescendants bool
+		}
+		// GetDeletedArticleRows holds details about calls to the GetDeletedArticleRows method.
+		GetDeletedArticleRows []struct {
+		}
+		// GetRecentArticleRows holds details about calls to the GetRecentArticleRows method.
+		GetRecentArticleRows []struct {
+			// Tag is the tag argument value.
+			Tag string
+			// Limit is the limit argument value.
+			Limit int
+		}
+		// GetRelatedTagRows holds details about calls to the GetRelatedTagRows method.
+		GetRelatedTagRows []struct {
+			// Slug is the slug argument value.
+			Slug string
+			// Days is the days argument value.
+			Days int
+			// Limit is the limit argument value.
+			Limit int
+			// PublishedOnly is the publishedOnly argument value.
+			PublishedOnly bool
+		}
+		// GetTagRowBySlug holds details about calls to the GetTagRowBySlug method.
+		GetTagRowBySlug []struct {
+			// Slug is the slug argument value.
+			Slug string
+			// PublishedOnly is the publishedOnly argument value.
+			PublishedOnly bool
+		}
+		// GetTagRows holds details about calls to the GetTagRows method.
+		GetTagRows []struct {
+			// SortBy is the sortBy argument value.
+			SortBy string
+			// Limit is the limit argument value.
+			Limit int
+			// Offset is the offset argument value.
+			Offset int
+			// PublishedOnly is the publishedOnly argument value.
+			PublishedOnly bool
+		}
+		// GetTagSuggestionRows holds details about calls to the GetTagSuggestionRows method.
+		GetTagSuggestionRows []struct {
+			// Prefix is the prefix argument value.
+			Prefix string
+			// Limit is the limit argument value.
+			Limit int
+			// PublishedOnly is the publishedOnly argument value.
+			PublishedOnly bool
+		}
+		// GetTrendingTagRows holds details about calls to the GetTrendingTagRows method.
+		GetTrendingTagRows []struct {
+			// Days is the days argument value.
+			Days int
+			// Limit is the limit argument value.
+			Limit int
+			// PublishedOnly is the publishedOnly argument value.
+			PublishedOnly bool
+		}
+		// MergeTagRows holds details about calls to the MergeTagRows method.
+		MergeTagRows []struct {
+			// FromSlug is the fromSlug argument value.
+			FromSlug string
+			// IntoSlug is the intoSlug argument value.
+			IntoSlug string
+		}
+		// Primary holds details about calls to the Primary method.
+		Primary []struct {
+		}
+		// PublishScheduledArticleRows holds details about calls to the PublishScheduledArticleRows method.
+		PublishScheduledArticleRows []struct {
+			// Now is the now argument value.
+			Now time.Time
+		}
+		// PurgeDeletedArticleRows holds details about calls to the PurgeDeletedArticleRows method.
+		PurgeDeletedArticleRows []struct {
+			// DeletedBefore is the deletedBefore argument value.
+			DeletedBefore time.Time
+		}
+		// ReleaseIdempotencyKey holds details about calls to the ReleaseIdempotencyKey method.
+		ReleaseIdempotencyKey []struct {
+			// Key is the key argument value.
+			Key string
+		}
+		// RemoveTagAliasRow holds details about calls to the RemoveTagAliasRow method.
+		RemoveTagAliasRow []struct {
+			// Slug is the slug argument value.
+			Slug string
+			// Alias is the alias argument value.
+			Alias string
+		}
+		// RenameTagRow holds details about calls to the RenameTagRow method.
+		RenameTagRow []struct {
+			// Slug is the slug argument value.
+			Slug string
+			// Name is the name argument value.
+			Name string
+		}
+		// ReserveIdempotencyKey holds details about calls to the ReserveIdempotencyKey method.
+		ReserveIdempotencyKey []struct {
+			// Key is the key argument value.
+			Key string
+			// RequestHash is the requestHash argument value.
+			RequestHash string
+			// Now is the now argument value.
+			Now time.Time
+			// ExpiresAt is the expiresAt argument value.
+			ExpiresAt time.Time
+		}
+		// RestoreArticleByID holds details about calls to the RestoreArticleByID method.
+		RestoreArticleByID []struct {
+			// ID is the id argument value.
+			ID int
+		}
+		// SaveIdempotentResponse holds details about calls to the SaveIdempotentResponse method.
+		SaveIdempotentResponse []struct {
+			// Key is the key argument value.
+			Key string
+			// Response is the response argument value.
+			Response *models.IdempotentResponse
+		}
+		// SetTagParentRow holds details about calls to the SetTagParentRow method.
+		SetTagParentRow []struct {
+			// Slug is the slug argument value.
+			Slug string
+			// ParentSlug is the parentSlug argument value.
+			ParentSlug string
+		}
+		// UpdateArticleStatus holds details about calls to the UpdateArticleStatus method.
+		UpdateArticleStatus []struct {
+			// ID is the id argument value.
+			ID int
+			// FromStatus is the fromStatus argument value.
+			FromStatus string
+			// ToStatus is the toStatus argument value.
+			ToStatus string
+			// PublishAt is the publishAt argument value.
+			PublishAt *time.Time
+		}
+		// WithTx holds details about calls to the WithTx method.
+		WithTx []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Fn is the fn argument value.
+			Fn func(tx DBClient) error
 		}
 	}
-	lockCreateArticleRow          sync.RWMutex
-	lockDeleteArticleByID         sync.RWMutex
-	lockGetArticleRowByID         sync.RWMutex
-	lockGetArticleRowByTagAndDate sync.RWMutex
+	lockAddTagAliasRow              sync.RWMutex
+	lockCreateArticleRow            sync.RWMutex
+	lockCreateArticleRows           sync.RWMutex
+	lockDeleteArticleByID           sync.RWMutex
+	lockExportArticleRows           sync.RWMutex
+	lockGetArticleFingerprintRows   sync.RWMutex
+	lockGetArticleIDByContentHash   sync.RWMutex
+	lockGetArticleRowByID           sync.RWMutex
+	lockGetArticleRowByTagAndDate   sync.RWMutex
+	lockGetDeletedArticleRows       sync.RWMutex
+	lockGetRecentArticleRows        sync.RWMutex
+	lockGetRelatedTagRows           sync.RWMutex
+	lockGetTagRowBySlug             sync.RWMutex
+	lockGetTagRows                  sync.RWMutex
+	lockGetTagSuggestionRows        sync.RWMutex
+	lockGetTrendingTagRows          sync.RWMutex
+	lockMergeTagRows                sync.RWMutex
+	lockPrimary                     sync.RWMutex
+	lockPublishScheduledArticleRows sync.RWMutex
+	lockPurgeDeletedArticleRows     sync.RWMutex
+	lockReleaseIdempotencyKey       sync.RWMutex
+	lockRemoveTagAliasRow           sync.RWMutex
+	lockRenameTagRow                sync.RWMutex
+	lockReserveIdempotencyKey       sync.RWMutex
+	lockRestoreArticleByID          sync.RWMutex
+	lockSaveIdempotentResponse      sync.RWMutex
+	lockSetTagParentRow             sync.RWMutex
+	lockUpdateArticleStatus         sync.RWMutex
+	lockWithTx                      sync.RWMutex
+}
+
+// AddTagAliasRow calls AddTagAliasRowFunc.
+func (mock *DBClientMock) AddTagAliasRow(slug string, alias string) (bool, error) {
+	if mock.AddTagAliasRowFunc == nil {
+		panic("DBClientMock.AddTagAliasRowFunc: method is nil but DBClient.AddTagAliasRow was just called")
+	}
+	callInfo := struct {
+		Slug  string
+		Alias string
+	}{
+		Slug:  slug,
+		Alias: alias,
+	}
+	mock.lockAddTagAliasRow.Lock()
+	mock.calls.AddTagAliasRow = append(mock.calls.AddTagAliasRow, callInfo)
+	mock.lockAddTagAliasRow.Unlock()
+	return mock.AddTagAliasRowFunc(slug, alias)
+}
+
+// AddTagAliasRowCalls gets all the calls that were made to AddTagAliasRow.
+// Check the length with:
+//     len(mockedDBClient.AddTagAliasRowCalls())
+func (mock *DBClientMock) AddTagAliasRowCalls() []struct {
+	Slug  string
+	Alias string
+} {
+	var calls []struct {
+		Slug  string
+		Alias string
+	}
+	mock.lockAddTagAliasRow.RLock()
+	calls = mock.calls.AddTagAliasRow
+	mock.lockAddTagAliasRow.RUnlock()
+	return calls
 }
 
 // CreateArticleRow calls CreateArticleRowFunc.
-func (mock *DBClientMock) CreateArticleRow(title string, body string, date time.Time, tags []string) (int, error) {
+func (mock *DBClientMock) CreateArticleRow(article *models.Article) (int, error) {
 	if mock.CreateArticleRowFunc == nil {
 		panic("DBClientMock.CreateArticleRowFunc: method is nil but DBClient.CreateArticleRow was just called")
 	}
 	callInfo := struct {
-		Title string
-		Body  string
-		Date  time.Time
-		Tags  []string
+		Article *models.Article
 	}{
-		Title: title,
-		Body:  body,
-		Date:  date,
-		Tags:  tags,
+		Article: article,
 	}
 	mock.lockCreateArticleRow.Lock()
 	mock.calls.CreateArticleRow = append(mock.calls.CreateArticleRow, callInfo)
 	mock.lockCreateArticleRow.Unlock()
-	return mock.CreateArticleRowFunc(title, body, date, tags)
+	return mock.CreateArticleRowFunc(article)
 }
 
 // CreateArticleRowCalls gets all the calls that were made to CreateArticleRow.
 // Check the length with:
 //     len(mockedDBClient.CreateArticleRowCalls())
 func (mock *DBClientMock) CreateArticleRowCalls() []struct {
-	Title string
-	Body  string
-	Date  time.Time
-	Tags  []string
+	Article *models.Article
 } {
 	var calls []struct {
-		Title string
-		Body  string
-		Date  time.Time
-		Tags  []string
+		Article *models.Article
 	}
 	mock.lockCreateArticleRow.RLock()
 	calls = mock.calls.CreateArticleRow
@@ -130,8 +504,39 @@ func (mock *DBClientMock) CreateArticleRowCalls() []struct {
 	return calls
 }
 
+// CreateArticleRows calls CreateArticleRowsFunc.
+func (mock *DBClientMock) CreateArticleRows(articles []*models.Article) ([]int, error) {
+	if mock.CreateArticleRowsFunc == nil {
+		panic("DBClientMock.CreateArticleRowsFunc: method is nil but DBClient.CreateArticleRows was just called")
+	}
+	callInfo := struct {
+		Articles []*models.Article
+	}{
+		Articles: articles,
+	}
+	mock.lockCreateArticleRows.Lock()
+	mock.calls.CreateArticleRows = append(mock.calls.CreateArticleRows, callInfo)
+	mock.lockCreateArticleRows.Unlock()
+	return mock.CreateArticleRowsFunc(articles)
+}
+
+// CreateArticleRowsCalls gets all the calls that were made to CreateArticleRows.
+// Check the length with:
+//     len(mockedDBClient.CreateArticleRowsCalls())
+func (mock *DBClientMock) CreateArticleRowsCalls() []struct {
+	Articles []*models.Article
+} {
+	var calls []struct {
+		Articles []*models.Article
+	}
+	mock.lockCreateArticleRows.RLock()
+	calls = mock.calls.CreateArticleRows
+	mock.lockCreateArticleRows.RUnlock()
+	return calls
+}
+
 // DeleteArticleByID calls DeleteArticleByIDFunc.
-func (mock *DBClientMock) DeleteArticleByID(id int) error {
+func (mock *DBClientMock) DeleteArticleByID(id int) (bool, error) {
 	if mock.DeleteArticleByIDFunc == nil {
 		panic("DBClientMock.DeleteArticleByIDFunc: method is nil but DBClient.DeleteArticleByID was just called")
 	}
@@ -161,6 +566,111 @@ func (mock *DBClientMock) DeleteArticleByIDCalls() []struct {
 	return calls
 }
 
+// ExportArticleRows calls ExportArticleRowsFunc.
+func (mock *DBClientMock) ExportArticleRows(filter models.ArticleFilter, fn func(article *models.Article) error) error {
+	if mock.ExportArticleRowsFunc == nil {
+		panic("DBClientMock.ExportArticleRowsFunc: method is nil but DBClient.ExportArticleRows was just called")
+	}
+	callInfo := struct {
+		Filter models.ArticleFilter
+		Fn     func(article *models.Article) error
+	}{
+		Filter: filter,
+		Fn:     fn,
+	}
+	mock.lockExportArticleRows.Lock()
+	mock.calls.ExportArticleRows = append(mock.calls.ExportArticleRows, callInfo)
+	mock.lockExportArticleRows.Unlock()
+	return mock.ExportArticleRowsFunc(filter, fn)
+}
+
+// ExportArticleRowsCalls gets all the calls that were made to ExportArticleRows.
+// Check the length with:
+//     len(mockedDBClient.ExportArticleRowsCalls())
+func (mock *DBClientMock) ExportArticleRowsCalls() []struct {
+	Filter models.ArticleFilter
+	Fn     func(article *models.Article) error
+} {
+	var calls []struct {
+		Filter models.ArticleFilter
+		Fn     func(article *models.Article) error
+	}
+	mock.lockExportArticleRows.RLock()
+	calls = mock.calls.ExportArticleRows
+	mock.lockExportArticleRows.RUnlock()
+	return calls
+}
+
+// GetArticleFingerprintRows calls GetArticleFingerprintRowsFunc.
+func (mock *DBClientMock) GetArticleFingerprintRows(fromDay string, toDay string) (*[]models.ArticleFingerprint, error) {
+	if mock.GetArticleFingerprintRowsFunc == nil {
+		panic("DBClientMock.GetArticleFingerprintRowsFunc: method is nil but DBClient.GetArticleFingerprintRows was just called")
+	}
+	callInfo := struct {
+		FromDay string
+		ToDay   string
+	}{
+		FromDay: fromDay,
+		ToDay:   toDay,
+	}
+	mock.lockGetArticleFingerprintRows.Lock()
+	mock.calls.GetArticleFingerprintRows = append(mock.calls.GetArticleFingerprintRows, callInfo)
+	mock.lockGetArticleFingerprintRows.Unlock()
+	return mock.GetArticleFingerprintRowsFunc(fromDay, toDay)
+}
+
+// GetArticleFingerprintRowsCalls gets all the calls that were made to GetArticleFingerprintRows.
+// Check the length with:
+//     len(mockedDBClient.GetArticleFingerprintRowsCalls())
+func (mock *DBClientMock) GetArticleFingerprintRowsCalls() []struct {
+	FromDay string
+	ToDay   string
+} {
+	var calls []struct {
+		FromDay string
+		ToDay   string
+	}
+	mock.lockGetArticleFingerprintRows.RLock()
+	calls = mock.calls.GetArticleFingerprintRows
+	mock.lockGetArticleFingerprintRows.RUnlock()
+	return calls
+}
+
+// GetArticleIDByContentHash calls GetArticleIDByContentHashFunc.
+func (mock *DBClientMock) GetArticleIDByContentHash(contentHash string, day string) (int, error) {
+	if mock.GetArticleIDByContentHashFunc == nil {
+		panic("DBClientMock.GetArticleIDByContentHashFunc: method is nil but DBClient.GetArticleIDByContentHash was just called")
+	}
+	callInfo := struct {
+		ContentHash string
+		Day         string
+	}{
+		ContentHash: contentHash,
+		Day:         day,
+	}
+	mock.lockGetArticleIDByContentHash.Lock()
+	mock.calls.GetArticleIDByContentHash = append(mock.calls.GetArticleIDByContentHash, callInfo)
+	mock.lockGetArticleIDByContentHash.Unlock()
+	return mock.GetArticleIDByContentHashFunc(contentHash, day)
+}
+
+// GetArticleIDByContentHashCalls gets all the calls that were made to GetArticleIDByContentHash.
+// Check the length with:
+//     len(mockedDBClient.GetArticleIDByContentHashCalls())
+func (mock *DBClientMock) GetArticleIDByContentHashCalls() []struct {
+	ContentHash string
+	Day         string
+} {
+	var calls []struct {
+		ContentHash string
+		Day         string
+	}
+	mock.lockGetArticleIDByContentHash.RLock()
+	calls = mock.calls.GetArticleIDByContentHash
+	mock.lockGetArticleIDByContentHash.RUnlock()
+	return calls
+}
+
 // GetArticleRowByID calls GetArticleRowByIDFunc.
 func (mock *DBClientMock) GetArticleRowByID(findID int) (*models.Article, error) {
 	if mock.GetArticleRowByIDFunc == nil {
@@ -193,36 +703,750 @@ func (mock *DBClientMock) GetArticleRowByIDCalls() []struct {
 }
 
 // GetArticleRowByTagAndDate calls GetArticleRowByTagAndDateFunc.
-func (mock *DBClientMock) GetArticleRowByTagAndDate(tag string, date string) (*[]models.Article, error) {
+func (mock *DBClientMock) GetArticleRowByTagAndDate(tag string, date string, publishedOnly bool, includeDescendants bool) (*[]models.Article, error) {
 	if mock.GetArticleRowByTagAndDateFunc == nil {
 		panic("DBClientMock.GetArticleRowByTagAndDateFunc: method is nil but DBClient.GetArticleRowByTagAndDate was just called")
 	}
 	callInfo := struct {
-		Tag  string
-		Date string
+		Tag                string
+		Date               string
+		PublishedOnly      bool
+		IncludeDescendants bool
 	}{
-		Tag:  tag,
-		Date: date,
+		Tag:                tag,
+		Date:               date,
+		PublishedOnly:      publishedOnly,
+		IncludeDescendants: includeDescendants,
 	}
 	mock.lockGetArticleRowByTagAndDate.Lock()
 	mock.calls.GetArticleRowByTagAndDate = append(mock.calls.GetArticleRowByTagAndDate, callInfo)
 	mock.lockGetArticleRowByTagAndDate.Unlock()
-	return mock.GetArticleRowByTagAndDateFunc(tag, date)
+	return mock.GetArticleRowByTagAndDateFunc(tag, date, publishedOnly, includeDescendants)
 }
 
 // GetArticleRowByTagAndDateCalls gets all the calls that were made to GetArticleRowByTagAndDate.
 // Check the length with:
 //     len(mockedDBClient.GetArticleRowByTagAndDateCalls())
 func (mock *DBClientMock) GetArticleRowByTagAndDateCalls() []struct {
-	Tag  string
-	Date string
+	Tag                string
+	Date               string
+	PublishedOnly      bool
+	IncludeDescendants bool
 } {
 	var calls []struct {
-		Tag  string
-		Date string
+		Tag                string
+		Date               string
+		PublishedOnly      bool
+		IncludeDescendants bool
 	}
 	mock.lockGetArticleRowByTagAndDate.RLock()
 	calls = mock.calls.GetArticleRowByTagAndDate
 	mock.lockGetArticleRowByTagAndDate.RUnlock()
 	return calls
 }
+
+// GetDeletedArticleRows calls GetDeletedArticleRowsFunc.
+func (mock *DBClientMock) GetDeletedArticleRows() (*[]models.Article, error) {
+	if mock.GetDeletedArticleRowsFunc == nil {
+		panic("DBClientMock.GetDeletedArticleRowsFunc: method is nil but DBClient.GetDeletedArticleRows was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockGetDeletedArticleRows.Lock()
+	mock.calls.GetDeletedArticleRows = append(mock.calls.GetDeletedArticleRows, callInfo)
+	mock.lockGetDeletedArticleRows.Unlock()
+	return mock.GetDeletedArticleRowsFunc()
+}
+
+// GetDeletedArticleRowsCalls gets all the calls that were made to GetDeletedArticleRows.
+// Check the length with:
+//     len(mockedDBClient.GetDeletedArticleRowsCalls())
+func (mock *DBClientMock) GetDeletedArticleRowsCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockGetDeletedArticleRows.RLock()
+	calls = mock.calls.GetDeletedArticleRows
+	mock.lockGetDeletedArticleRows.RUnlock()
+	return calls
+}
+
+// GetRecentArticleRows calls GetRecentArticleRowsFunc.
+func (mock *DBClientMock) GetRecentArticleRows(tag string, limit int) (*[]models.Article, error) {
+	if mock.GetRecentArticleRowsFunc == nil {
+		panic("DBClientMock.GetRecentArticleRowsFunc: method is nil but DBClient.GetRecentArticleRows was just called")
+	}
+	callInfo := struct {
+		Tag   string
+		Limit int
+	}{
+		Tag:   tag,
+		Limit: limit,
+	}
+	mock.lockGetRecentArticleRows.Lock()
+	mock.calls.GetRecentArticleRows = append(mock.calls.GetRecentArticleRows, callInfo)
+	mock.lockGetRecentArticleRows.Unlock()
+	return mock.GetRecentArticleRowsFunc(tag, limit)
+}
+
+// GetRecentArticleRowsCalls gets all the calls that were made to GetRecentArticleRows.
+// Check the length with:
+//     len(mockedDBClient.GetRecentArticleRowsCalls())
+func (mock *DBClientMock) GetRecentArticleRowsCalls() []struct {
+	Tag   string
+	Limit int
+} {
+	var calls []struct {
+		Tag   string
+		Limit int
+	}
+	mock.lockGetRecentArticleRows.RLock()
+	calls = mock.calls.GetRecentArticleRows
+	mock.lockGetRecentArticleRows.RUnlock()
+	return calls
+}
+
+// GetRelatedTagRows calls GetRelatedTagRowsFunc.
+func (mock *DBClientMock) GetRelatedTagRows(slug string, days int, limit int, publishedOnly bool) (*[]models.RelatedTag, error) {
+	if mock.GetRelatedTagRowsFunc == nil {
+		panic("DBClientMock.GetRelatedTagRowsFunc: method is nil but DBClient.GetRelatedTagRows was just called")
+	}
+	callInfo := struct {
+		Slug          string
+		Days          int
+		Limit         int
+		PublishedOnly bool
+	}{
+		Slug:          slug,
+		Days:          days,
+		Limit:         limit,
+		PublishedOnly: publishedOnly,
+	}
+	mock.lockGetRelatedTagRows.Lock()
+	mock.calls.GetRelatedTagRows = append(mock.calls.GetRelatedTagRows, callInfo)
+	mock.lockGetRelatedTagRows.Unlock()
+	return mock.GetRelatedTagRowsFunc(slug, days, limit, publishedOnly)
+}
+
+// GetRelatedTagRowsCalls gets all the calls that were made to GetRelatedTagRows.
+// Check the length with:
+//     len(mockedDBClient.GetRelatedTagRowsCalls())
+func (mock *DBClientMock) GetRelatedTagRowsCalls() []struct {
+	Slug          string
+	Days          int
+	Limit         int
+	PublishedOnly bool
+} {
+	var calls []struct {
+		Slug          string
+		Days          int
+		Limit         int
+		PublishedOnly bool
+	}
+	mock.lockGetRelatedTagRows.RLock()
+	calls = mock.calls.GetRelatedTagRows
+	mock.lockGetRelatedTagRows.RUnlock()
+	return calls
+}
+
+// GetTagRowBySlug calls GetTagRowBySlugFunc.
+func (mock *DBClientMock) GetTagRowBySlug(slug string, publishedOnly bool) (*models.Tag, error) {
+	if mock.GetTagRowBySlugFunc == nil {
+		panic("DBClientMock.GetTagRowBySlugFunc: method is nil but DBClient.GetTagRowBySlug was just called")
+	}
+	callInfo := struct {
+		Slug          string
+		PublishedOnly bool
+	}{
+		Slug:          slug,
+		PublishedOnly: publishedOnly,
+	}
+	mock.lockGetTagRowBySlug.Lock()
+	mock.calls.GetTagRowBySlug = append(mock.calls.GetTagRowBySlug, callInfo)
+	mock.lockGetTagRowBySlug.Unlock()
+	return mock.GetTagRowBySlugFunc(slug, publishedOnly)
+}
+
+// GetTagRowBySlugCalls gets all the calls that were made to GetTagRowBySlug.
+// Check the length with:
+//     len(mockedDBClient.GetTagRowBySlugCalls())
+func (mock *DBClientMock) GetTagRowBySlugCalls() []struct {
+	Slug          string
+	PublishedOnly bool
+} {
+	var calls []struct {
+		Slug          string
+		PublishedOnly bool
+	}
+	mock.lockGetTagRowBySlug.RLock()
+	calls = mock.calls.GetTagRowBySlug
+	mock.lockGetTagRowBySlug.RUnlock()
+	return calls
+}
+
+// GetTagRows calls GetTagRowsFunc.
+func (mock *DBClientMock) GetTagRows(sortBy string, limit int, offset int, publishedOnly bool) (*[]models.Tag, int, error) {
+	if mock.GetTagRowsFunc == nil {
+		panic("DBClientMock.GetTagRowsFunc: method is nil but DBClient.GetTagRows was just called")
+	}
+	callInfo := struct {
+		SortBy        string
+		Limit         int
+		Offset        int
+		PublishedOnly bool
+	}{
+		SortBy:        sortBy,
+		Limit:         limit,
+		Offset:        offset,
+		PublishedOnly: publishedOnly,
+	}
+	mock.lockGetTagRows.Lock()
+	mock.calls.GetTagRows = append(mock.calls.GetTagRows, callInfo)
+	mock.lockGetTagRows.Unlock()
+	return mock.GetTagRowsFunc(sortBy, limit, offset, publishedOnly)
+}
+
+// GetTagRowsCalls gets all the calls that were made to GetTagRows.
+// Check the length with:
+//     len(mockedDBClient.GetTagRowsCalls())
+func (mock *DBClientMock) GetTagRowsCalls() []struct {
+	SortBy        string
+	Limit         int
+	Offset        int
+	PublishedOnly bool
+} {
+	var calls []struct {
+		SortBy        string
+		Limit         int
+		Offset        int
+		PublishedOnly bool
+	}
+	mock.lockGetTagRows.RLock()
+	calls = mock.calls.GetTagRows
+	mock.lockGetTagRows.RUnlock()
+	return calls
+}
+
+// GetTagSuggestionRows calls GetTagSuggestionRowsFunc.
+func (mock *DBClientMock) GetTagSuggestionRows(prefix string, limit int, publishedOnly bool) (*[]models.Tag, error) {
+	if mock.GetTagSuggestionRowsFunc == nil {
+		panic("DBClientMock.GetTagSuggestionRowsFunc: method is nil but DBClient.GetTagSuggestionRows was just called")
+	}
+	callInfo := struct {
+		Prefix        string
+		Limit         int
+		PublishedOnly bool
+	}{
+		Prefix:        prefix,
+		Limit:         limit,
+		PublishedOnly: publishedOnly,
+	}
+	mock.lockGetTagSuggestionRows.Lock()
+	mock.calls.GetTagSuggestionRows = append(mock.calls.GetTagSuggestionRows, callInfo)
+	mock.lockGetTagSuggestionRows.Unlock()
+	return mock.GetTagSuggestionRowsFunc(prefix, limit, publishedOnly)
+}
+
+// GetTagSuggestionRowsCalls gets all the calls that were made to GetTagSuggestionRows.
+// Check the length with:
+//     len(mockedDBClient.GetTagSuggestionRowsCalls())
+func (mock *DBClientMock) GetTagSuggestionRowsCalls() []struct {
+	Prefix        string
+	Limit         int
+	PublishedOnly bool
+} {
+	var calls []struct {
+		Prefix        string
+		Limit         int
+		PublishedOnly bool
+	}
+	mock.lockGetTagSuggestionRows.RLock()
+	calls = mock.calls.GetTagSuggestionRows
+	mock.lockGetTagSuggestionRows.RUnlock()
+	return calls
+}
+
+// GetTrendingTagRows calls GetTrendingTagRowsFunc.
+func (mock *DBClientMock) GetTrendingTagRows(days int, limit int, publishedOnly bool) (*[]models.TrendingTag, error) {
+	if mock.GetTrendingTagRowsFunc == nil {
+		panic("DBClientMock.GetTrendingTagRowsFunc: method is nil but DBClient.GetTrendingTagRows was just called")
+	}
+	callInfo := struct {
+		Days          int
+		Limit         int
+		PublishedOnly bool
+	}{
+		Days:          days,
+		Limit:         limit,
+		PublishedOnly: publishedOnly,
+	}
+	mock.lockGetTrendingTagRows.Lock()
+	mock.calls.GetTrendingTagRows = append(mock.calls.GetTrendingTagRows, callInfo)
+	mock.lockGetTrendingTagRows.Unlock()
+	return mock.GetTrendingTagRowsFunc(days, limit, publishedOnly)
+}
+
+// GetTrendingTagRowsCalls gets all the calls that were made to GetTrendingTagRows.
+// Check the length with:
+//     len(mockedDBClient.GetTrendingTagRowsCalls())
+func (mock *DBClientMock) GetTrendingTagRowsCalls() []struct {
+	Days          int
+	Limit         int
+	PublishedOnly bool
+} {
+	var calls []struct {
+		Days          int
+		Limit         int
+		PublishedOnly bool
+	}
+	mock.lockGetTrendingTagRows.RLock()
+	calls = mock.calls.GetTrendingTagRows
+	mock.lockGetTrendingTagRows.RUnlock()
+	return calls
+}
+
+// MergeTagRows calls MergeTagRowsFunc.
+func (mock *DBClientMock) MergeTagRows(fromSlug string, intoSlug string) (bool, error) {
+	if mock.MergeTagRowsFunc == nil {
+		panic("DBClientMock.MergeTagRowsFunc: method is nil but DBClient.MergeTagRows was just called")
+	}
+	callInfo := struct {
+		FromSlug string
+		IntoSlug string
+	}{
+		FromSlug: fromSlug,
+		IntoSlug: intoSlug,
+	}
+	mock.lockMergeTagRows.Lock()
+	mock.calls.MergeTagRows = append(mock.calls.MergeTagRows, callInfo)
+	mock.lockMergeTagRows.Unlock()
+	return mock.MergeTagRowsFunc(fromSlug, intoSlug)
+}
+
+// MergeTagRowsCalls gets all the calls that were made to MergeTagRows.
+// Check the length with:
+//     len(mockedDBClient.MergeTagRowsCalls())
+func (mock *DBClientMock) MergeTagRowsCalls() []struct {
+	FromSlug string
+	IntoSlug string
+} {
+	var calls []struct {
+		FromSlug string
+		IntoSlug string
+	}
+	mock.lockMergeTagRows.RLock()
+	calls = mock.calls.MergeTagRows
+	mock.lockMergeTagRows.RUnlock()
+	return calls
+}
+
+// Primary calls PrimaryFunc.
+func (mock *DBClientMock) Primary() DBClient {
+	if mock.PrimaryFunc == nil {
+		panic("DBClientMock.PrimaryFunc: method is nil but DBClient.Primary was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockPrimary.Lock()
+	mock.calls.Primary = append(mock.calls.Primary, callInfo)
+	mock.lockPrimary.Unlock()
+	return mock.PrimaryFunc()
+}
+
+// PrimaryCalls gets all the calls that were made to Primary.
+// Check the length with:
+//     len(mockedDBClient.PrimaryCalls())
+func (mock *DBClientMock) PrimaryCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockPrimary.RLock()
+	calls = mock.calls.Primary
+	mock.lockPrimary.RUnlock()
+	return calls
+}
+
+// PublishScheduledArticleRows calls PublishScheduledArticleRowsFunc.
+func (mock *DBClientMock) PublishScheduledArticleRows(now time.Time) (int64, error) {
+	if mock.PublishScheduledArticleRowsFunc == nil {
+		panic("DBClientMock.PublishScheduledArticleRowsFunc: method is nil but DBClient.PublishScheduledArticleRows was just called")
+	}
+	callInfo := struct {
+		Now time.Time
+	}{
+		Now: now,
+	}
+	mock.lockPublishScheduledArticleRows.Lock()
+	mock.calls.PublishScheduledArticleRows = append(mock.calls.PublishScheduledArticleRows, callInfo)
+	mock.lockPublishScheduledArticleRows.Unlock()
+	return mock.PublishScheduledArticleRowsFunc(now)
+}
+
+// PublishScheduledArticleRowsCalls gets all the calls that were made to PublishScheduledArticleRows.
+// Check the length with:
+//     len(mockedDBClient.PublishScheduledArticleRowsCalls())
+func (mock *DBClientMock) PublishScheduledArticleRowsCalls() []struct {
+	Now time.Time
+} {
+	var calls []struct {
+		Now time.Time
+	}
+	mock.lockPublishScheduledArticleRows.RLock()
+	calls = mock.calls.PublishScheduledArticleRows
+	mock.lockPublishScheduledArticleRows.RUnlock()
+	return calls
+}
+
+// PurgeDeletedArticleRows calls PurgeDeletedArticleRowsFunc.
+func (mock *DBClientMock) PurgeDeletedArticleRows(deletedBefore time.Time) (int64, error) {
+	if mock.PurgeDeletedArticleRowsFunc == nil {
+		panic("DBClientMock.PurgeDeletedArticleRowsFunc: method is nil but DBClient.PurgeDeletedArticleRows was just called")
+	}
+	callInfo := struct {
+		DeletedBefore time.Time
+	}{
+		DeletedBefore: deletedBefore,
+	}
+	mock.lockPurgeDeletedArticleRows.Lock()
+	mock.calls.PurgeDeletedArticleRows = append(mock.calls.PurgeDeletedArticleRows, callInfo)
+	mock.lockPurgeDeletedArticleRows.Unlock()
+	return mock.PurgeDeletedArticleRowsFunc(deletedBefore)
+}
+
+// PurgeDeletedArticleRowsCalls gets all the calls that were made to PurgeDeletedArticleRows.
+// Check the length with:
+//     len(mockedDBClient.PurgeDeletedArticleRowsCalls())
+func (mock *DBClientMock) PurgeDeletedArticleRowsCalls() []struct {
+	DeletedBefore time.Time
+} {
+	var calls []struct {
+		DeletedBefore time.Time
+	}
+	mock.lockPurgeDeletedArticleRows.RLock()
+	calls = mock.calls.PurgeDeletedArticleRows
+	mock.lockPurgeDeletedArticleRows.RUnlock()
+	return calls
+}
+
+// ReleaseIdempotencyKey calls ReleaseIdempotencyKeyFunc.
+func (mock *DBClientMock) ReleaseIdempotencyKey(key string) error {
+	if mock.ReleaseIdempotencyKeyFunc == nil {
+		panic("DBClientMock.ReleaseIdempotencyKeyFunc: method is nil but DBClient.ReleaseIdempotencyKey was just called")
+	}
+	callInfo := struct {
+		Key string
+	}{
+		Key: key,
+	}
+	mock.lockReleaseIdempotencyKey.Lock()
+	mock.calls.ReleaseIdempotencyKey = append(mock.calls.ReleaseIdempotencyKey, callInfo)
+	mock.lockReleaseIdempotencyKey.Unlock()
+	return mock.ReleaseIdempotencyKeyFunc(key)
+}
+
+// ReleaseIdempotencyKeyCalls gets all the calls that were made to ReleaseIdempotencyKey.
+// Check the length with:
+//     len(mockedDBClient.ReleaseIdempotencyKeyCalls())
+func (mock *DBClientMock) ReleaseIdempotencyKeyCalls() []struct {
+	Key string
+} {
+	var calls []struct {
+		Key string
+	}
+	mock.lockReleaseIdempotencyKey.RLock()
+	calls = mock.calls.ReleaseIdempotencyKey
+	mock.lockReleaseIdempotencyKey.RUnlock()
+	return calls
+}
+
+// RemoveTagAliasRow calls RemoveTagAliasRowFunc.
+func (mock *DBClientMock) RemoveTagAliasRow(slug string, alias string) (bool, error) {
+	if mock.RemoveTagAliasRowFunc == nil {
+		panic("DBClientMock.RemoveTagAliasRowFunc: method is nil but DBClient.RemoveTagAliasRow was just called")
+	}
+	callInfo := struct {
+		Slug  string
+		Alias string
+	}{
+		Slug:  slug,
+		Alias: alias,
+	}
+	mock.lockRemoveTagAliasRow.Lock()
+	mock.calls.RemoveTagAliasRow = append(mock.calls.RemoveTagAliasRow, callInfo)
+	mock.lockRemoveTagAliasRow.Unlock()
+	return mock.RemoveTagAliasRowFunc(slug, alias)
+}
+
+// RemoveTagAliasRowCalls gets all the calls that were made to RemoveTagAliasRow.
+// Check the length with:
+//     len(mockedDBClient.RemoveTagAliasRowCalls())
+func (mock *DBClientMock) RemoveTagAliasRowCalls() []struct {
+	Slug  string
+	Alias string
+} {
+	var calls []struct {
+		Slug  string
+		Alias string
+	}
+	mock.lockRemoveTagAliasRow.RLock()
+	calls = mock.calls.RemoveTagAliasRow
+	mock.lockRemoveTagAliasRow.RUnlock()
+	return calls
+}
+
+// RenameTagRow calls RenameTagRowFunc.
+func (mock *DBClientMock) RenameTagRow(slug string, name string) (bool, error) {
+	if mock.RenameTagRowFunc == nil {
+		panic("DBClientMock.RenameTagRowFunc: method is nil but DBClient.RenameTagRow was just called")
+	}
+	callInfo := struct {
+		Slug string
+		Name string
+	}{
+		Slug: slug,
+		Name: name,
+	}
+	mock.lockRenameTagRow.Lock()
+	mock.calls.RenameTagRow = append(mock.calls.RenameTagRow, callInfo)
+	mock.lockRenameTagRow.Unlock()
+	return mock.RenameTagRowFunc(slug, name)
+}
+
+// RenameTagRowCalls gets all the calls that were made to RenameTagRow.
+// Check the length with:
+//     len(mockedDBClient.RenameTagRowCalls())
+func (mock *DBClientMock) RenameTagRowCalls() []struct {
+	Slug string
+	Name string
+} {
+	var calls []struct {
+		Slug string
+		Name string
+	}
+	mock.lockRenameTagRow.RLock()
+	calls = mock.calls.RenameTagRow
+	mock.lockRenameTagRow.RUnlock()
+	return calls
+}
+
+// ReserveIdempotencyKey calls ReserveIdempotencyKeyFunc.
+func (mock *DBClientMock) ReserveIdempotencyKey(key string, requestHash string, now time.Time, expiresAt time.Time) (*models.IdempotentResponse, error) {
+	if mock.ReserveIdempotencyKeyFunc == nil {
+		panic("DBClientMock.ReserveIdempotencyKeyFunc: method is nil but DBClient.ReserveIdempotencyKey was just called")
+	}
+	callInfo := struct {
+		Key         string
+		RequestHash string
+		Now         time.Time
+		ExpiresAt   time.Time
+	}{
+		Key:         key,
+		RequestHash: requestHash,
+		Now:         now,
+		ExpiresAt:   expiresAt,
+	}
+	mock.lockReserveIdempotencyKey.Lock()
+	mock.calls.ReserveIdempotencyKey = append(mock.calls.ReserveIdempotencyKey, callInfo)
+	mock.lockReserveIdempotencyKey.Unlock()
+	return mock.ReserveIdempotencyKeyFunc(key, requestHash, now, expiresAt)
+}
+
+// ReserveIdempotencyKeyCalls gets all the calls that were made to ReserveIdempotencyKey.
+// Check the length with:
+//     len(mockedDBClient.ReserveIdempotencyKeyCalls())
+func (mock *DBClientMock) ReserveIdempotencyKeyCalls() []struct {
+	Key         string
+	RequestHash string
+	Now         time.Time
+	ExpiresAt   time.Time
+} {
+	var calls []struct {
+		Key         string
+		RequestHash string
+		Now         time.Time
+		ExpiresAt   time.Time
+	}
+	mock.lockReserveIdempotencyKey.RLock()
+	calls = mock.calls.ReserveIdempotencyKey
+	mock.lockReserveIdempotencyKey.RUnlock()
+	return calls
+}
+
+// RestoreArticleByID calls RestoreArticleByIDFunc.
+func (mock *DBClientMock) RestoreArticleByID(id int) (bool, error) {
+	if mock.RestoreArticleByIDFunc == nil {
+		panic("DBClientMock.RestoreArticleByIDFunc: method is nil but DBClient.RestoreArticleByID was just called")
+	}
+	callInfo := struct {
+		ID int
+	}{
+		ID: id,
+	}
+	mock.lockRestoreArticleByID.Lock()
+	mock.calls.RestoreArticleByID = append(mock.calls.RestoreArticleByID, callInfo)
+	mock.lockRestoreArticleByID.Unlock()
+	return mock.RestoreArticleByIDFunc(id)
+}
+
+// RestoreArticleByIDCalls gets all the calls that were made to RestoreArticleByID.
+// Check the length with:
+//     len(mockedDBClient.RestoreArticleByIDCalls())
+func (mock *DBClientMock) RestoreArticleByIDCalls() []struct {
+	ID int
+} {
+	var calls []struct {
+		ID int
+	}
+	mock.lockRestoreArticleByID.RLock()
+	calls = mock.calls.RestoreArticleByID
+	mock.lockRestoreArticleByID.RUnlock()
+	return calls
+}
+
+// SaveIdempotentResponse calls SaveIdempotentResponseFunc.
+func (mock *DBClientMock) SaveIdempotentResponse(key string, response *models.IdempotentResponse) error {
+	if mock.SaveIdempotentResponseFunc == nil {
+		panic("DBClientMock.SaveIdempotentResponseFunc: method is nil but DBClient.SaveIdempotentResponse was just called")
+	}
+	callInfo := struct {
+		Key      string
+		Response *models.IdempotentResponse
+	}{
+		Key:      key,
+		Response: response,
+	}
+	mock.lockSaveIdempotentResponse.Lock()
+	mock.calls.SaveIdempotentResponse = append(mock.calls.SaveIdempotentResponse, callInfo)
+	mock.lockSaveIdempotentResponse.Unlock()
+	return mock.SaveIdempotentResponseFunc(key, response)
+}
+
+// SaveIdempotentResponseCalls gets all the calls that were made to SaveIdempotentResponse.
+// Check the length with:
+//     len(mockedDBClient.SaveIdempotentResponseCalls())
+func (mock *DBClientMock) SaveIdempotentResponseCalls() []struct {
+	Key      string
+	Response *models.IdempotentResponse
+} {
+	var calls []struct {
+		Key      string
+		Response *models.IdempotentResponse
+	}
+	mock.lockSaveIdempotentResponse.RLock()
+	calls = mock.calls.SaveIdempotentResponse
+	mock.lockSaveIdempotentResponse.RUnlock()
+	return calls
+}
+
+// SetTagParentRow calls SetTagParentRowFunc.
+func (mock *DBClientMock) SetTagParentRow(slug string, parentSlug string) (bool, error) {
+	if mock.SetTagParentRowFunc == nil {
+		panic("DBClientMock.SetTagParentRowFunc: method is nil but DBClient.SetTagParentRow was just called")
+	}
+	callInfo := struct {
+		Slug       string
+		ParentSlug string
+	}{
+		Slug:       slug,
+		ParentSlug: parentSlug,
+	}
+	mock.lockSetTagParentRow.Lock()
+	mock.calls.SetTagParentRow = append(mock.calls.SetTagParentRow, callInfo)
+	mock.lockSetTagParentRow.Unlock()
+	return mock.SetTagParentRowFunc(slug, parentSlug)
+}
+
+// SetTagParentRowCalls gets all the calls that were made to SetTagParentRow.
+// Check the length with:
+//     len(mockedDBClient.SetTagParentRowCalls())
+func (mock *DBClientMock) SetTagParentRowCalls() []struct {
+	Slug       string
+	ParentSlug string
+} {
+	var calls []struct {
+		Slug       string
+		ParentSlug string
+	}
+	mock.lockSetTagParentRow.RLock()
+	calls = mock.calls.SetTagParentRow
+	mock.lockSetTagParentRow.RUnlock()
+	return calls
+}
+
+// UpdateArticleStatus calls UpdateArticleStatusFunc.
+func (mock *DBClientMock) UpdateArticleStatus(id int, fromStatus string, toStatus string, publishAt *time.Time) (bool, error) {
+	if mock.UpdateArticleStatusFunc == nil {
+		panic("DBClientMock.UpdateArticleStatusFunc: method is nil but DBClient.UpdateArticleStatus was just called")
+	}
+	callInfo := struct {
+		ID         int
+		FromStatus string
+		ToStatus   string
+		PublishAt  *time.Time
+	}{
+		ID:         id,
+		FromStatus: fromStatus,
+		ToStatus:   toStatus,
+		PublishAt:  publishAt,
+	}
+	mock.lockUpdateArticleStatus.Lock()
+	mock.calls.UpdateArticleStatus = append(mock.calls.UpdateArticleStatus, callInfo)
+	mock.lockUpdateArticleStatus.Unlock()
+	return mock.UpdateArticleStatusFunc(id, fromStatus, toStatus, publishAt)
+}
+
+// UpdateArticleStatusCalls gets all the calls that were made to UpdateArticleStatus.
+// Check the length with:
+//     len(mockedDBClient.UpdateArticleStatusCalls())
+func (mock *DBClientMock) UpdateArticleStatusCalls() []struct {
+	ID         int
+	FromStatus string
+	ToStatus   string
+	PublishAt  *time.Time
+} {
+	var calls []struct {
+		ID         int
+		FromStatus string
+		ToStatus   string
+		PublishAt  *time.Time
+	}
+	mock.lockUpdateArticleStatus.RLock()
+	calls = mock.calls.UpdateArticleStatus
+	mock.lockUpdateArticleStatus.RUnlock()
+	return calls
+}
+
+// WithTx calls WithTxFunc.
+func (mock *DBClientMock) WithTx(ctx context.Context, fn func(tx DBClient) error) error {
+	if mock.WithTxFunc == nil {
+		panic("DBClientMock.WithTxFunc: method is nil but DBClient.WithTx was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Fn  func(tx DBClient) error
+	}{
+		Ctx: ctx,
+		Fn:  fn,
+	}
+	mock.lockWithTx.Lock()
+	mock.calls.WithTx = append(mock.calls.WithTx, callInfo)
+	mock.lockWithTx.Unlock()
+	return mock.WithTxFunc(ctx, fn)
+}
+
+// WithTxCalls gets all the calls that were made to WithTx.
+// Check the length with:
+//     len(mockedDBClient.WithTxCalls())
+func (mock *DBClientMock) WithTxCalls() []struct {
+	Ctx context.Context
+	Fn  func(tx DBClient) error
+} {
+	var calls []struct {
+		Ctx context.Context
+		Fn  func(tx DBClient) error
+	}
+	mock.lockWithTx.RLock()
+	calls = mock.calls.WithTx
+	mock.lockWithTx.RUnlock()
+	return calls
+}
@@ -0,0 +1,938 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bmordt/article-api/src/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+//MemoryDBClient keeps articles and tags in memory, behaving as ArticleDBClient does against postgres.
+//Nothing survives a restart, it is meant for local development and tests
+type MemoryDBClient struct {
+	Logger *logrus.Entry
+
+	now func() time.Time
+
+	mu            sync.RWMutex
+	articles      map[int]*memoryArticle
+	tags          map[int]*memoryTag
+	tagsBySlug    map[string]int
+	aliases       map[string]int
+	nextArticleID int
+	nextTagID     int
+
+	idempotencyKeys map[string]*memoryIdempotencyKey
+}
+
+type memoryArticle struct {
+	article   models.Article
+	id        int
+	tagIDs    []int
+	createdAt time.Time
+
+	contentHash string
+	simhash     *int64
+}
+
+type memoryIdempotencyKey struct {
+	response  models.IdempotentResponse
+	expiresAt time.Time
+}
+
+type memoryTag struct {
+	id        int
+	slug      string
+	name      string
+	parentID  int
+	createdAt time.Time
+}
+
+//NewMemoryDBClient returns an empty in-memory DB client
+func NewMemoryDBClient(logger *logrus.Entry) *MemoryDBClient {
+	return &MemoryDBClient{
+		Logger:     logger,
+		now:        time.Now,
+		articles:   map[int]*memoryArticle{},
+		tags:       map[int]*memoryTag{},
+		tagsBySlug: map[string]int{},
+		aliases:    map[string]int{},
+
+		idempotencyKeys: map[string]*memoryIdempotencyKey{},
+	}
+}
+
+//WithTx runs fn against a copy of everything stored, which replaces what is stored when fn returns nil and is dropped
+//otherwise. Every other call waits until fn returns, so fn must only use the client it is passed
+func (m *MemoryDBClient) WithTx(ctx context.Context, fn func(tx DBClient) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tx := m.copyData()
+	err := fn(tx)
+	if err != nil {
+		return err
+	}
+	//a transaction whose context is done by the time it would commit is rolled back, as database/sql does
+	err = ctx.Err()
+	if err != nil {
+		return err
+	}
+
+	m.articles, m.tags, m.tagsBySlug, m.aliases = tx.articles, tx.tags, tx.tagsBySlug, tx.aliases
+	m.idempotencyKeys = tx.idempotencyKeys
+	m.nextArticleID, m.nextTagID = tx.nextArticleID, tx.nextTagID
+	return nil
+}
+
+//copyData returns a client with a copy of everything stored, for WithTx. The caller must hold the lock
+func (m *MemoryDBClient) copyData() *MemoryDBClient {
+	copied := NewMemoryDBClient(m.Logger)
+	copied.now = m.now
+	copied.nextArticleID, copied.nextTagID = m.nextArticleID, m.nextTagID
+	for id, stored := range m.articles {
+		article := *stored
+		article.article.Tags = append([]string(nil), stored.article.Tags...)
+		article.tagIDs = append([]int(nil), stored.tagIDs...)
+		copied.articles[id] = &article
+	}
+	for id, stored := range m.tags {
+		tag := *stored
+		copied.tags[id] = &tag
+	}
+	for slug, id := range m.tagsBySlug {
+		copied.tagsBySlug[slug] = id
+	}
+	for alias, id := range m.aliases {
+		copied.aliases[alias] = id
+	}
+	for key, stored := range m.idempotencyKeys {
+		idempotencyKey := *stored
+		copied.idempotencyKeys[key] = &idempotencyKey
+	}
+	return copied
+}
+
+//Primary returns the client itself, it has no replicas to read from
+func (m *MemoryDBClient) Primary() DBClient {
+	return m
+}
+
+//CreateArticleRow stores a new article along with its tags
+func (m *MemoryDBClient) CreateArticleRow(article *models.Article) (int, error) {
+	ids, err := m.CreateArticleRows([]*models.Article{article})
+	if err != nil {
+		return 0, err
+	}
+	return ids[0], nil
+}
+
+//CreateArticleRows stores the articles, returning their new ids in the same order as the articles
+func (m *MemoryDBClient) CreateArticleRows(articles []*models.Article) ([]int, error) {
+	m.Logger.Infof("CreateArticleRows :: inserting %d articles", len(articles))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now().UTC()
+	ids := make([]int, len(articles))
+	for i, article := range articles {
+		m.nextArticleID++
+		ids[i] = m.nextArticleID
+
+		stored := &memoryArticle{
+			article: models.Article{
+				ID:             strconv.Itoa(ids[i]),
+				Title:          article.Title,
+				Date:           article.Date,
+				Day:            article.Day,
+				Body:           article.Body,
+				Excerpt:        article.Excerpt,
+				WordCount:      article.WordCount,
+				ReadingMinutes: article.ReadingMinutes,
+				Status:         article.Status,
+				PublishedAt:    copyTime(article.PublishedAt),
+			},
+			id:          ids[i],
+			createdAt:   now,
+			contentHash: article.ContentHash,
+		}
+		if article.ContentHash != "" {
+			simhash := article.Simhash
+			stored.simhash = &simhash
+		}
+		m.articles[ids[i]] = stored
+	}
+
+	aliases := map[string]string{}
+	for _, slug := range writtenTagSlugs(articles) {
+		if id, ok := m.aliases[slug]; ok {
+			aliases[slug] = m.tags[id].slug
+		}
+	}
+	newTags, links := planArticleTags(ids, articles, aliases)
+	for _, tag := range newTags {
+		if _, ok := m.tagsBySlug[tag.Slug]; ok {
+			continue
+		}
+		m.nextTagID++
+		m.tags[m.nextTagID] = &memoryTag{id: m.nextTagID, slug: tag.Slug, name: tag.Name, createdAt: now}
+		m.tagsBySlug[tag.Slug] = m.nextTagID
+	}
+	for _, link := range links {
+		stored := m.articles[link.ArticleID]
+		stored.tagIDs = append(stored.tagIDs, m.tagsBySlug[link.Slug])
+	}
+	return ids, nil
+}
+
+//GetArticleRowByID returns the live article with the id, whatever its publication status, or nil if there is none
+func (m *MemoryDBClient) GetArticleRowByID(findID int) (*models.Article, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stored, ok := m.articles[findID]
+	if !ok || stored.article.DeletedAt != nil {
+		return nil, nil
+	}
+	return m.articleModel(stored, true), nil
+}
+
+//GetArticleRowByTagAndDate returns the live articles carrying the tag published on the date, newest first, without their bodies.
+//publishedOnly limits the result to published articles and includeDescendants also returns articles carrying tags below the tag
+func (m *MemoryDBClient) GetArticleRowByTagAndDate(tag, date string, publishedOnly, includeDescendants bool) (*[]models.Article, error) {
+	articleDay, err := time.Parse(models.DayFormat, date)
+	if err != nil {
+		return nil, err
+	}
+	day := articleDay.Format(models.DayFormat)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tagIDs := m.matchingTagIDs(models.TagSlug(tag), includeDescendants)
+	found := m.filterArticles(func(stored *memoryArticle) bool {
+		return m.visible(stored, publishedOnly) && stored.article.Day == day && carriesAny(stored, tagIDs)
+	})
+	sort.Slice(found, func(i, j int) bool { return newerThan(found[i], found[j]) })
+	return m.articleModels(found, false), nil
+}
+
+//GetRecentArticleRows returns the most recently published articles, newest first, without their bodies. An empty tag returns articles with any tag
+func (m *MemoryDBClient) GetRecentArticleRows(tag string, limit int) (*[]models.Article, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tagIDs := m.matchingTagIDs(models.TagSlug(tag), false)
+	found := m.filterArticles(func(stored *memoryArticle) bool {
+		return m.visible(stored, true) && (tag == "" || carriesAny(stored, tagIDs))
+	})
+	sort.Slice(found, func(i, j int) bool {
+		a, b := found[i].article.PublishedAt, found[j].article.PublishedAt
+		switch {
+		case a != nil && b != nil && !a.Equal(*b):
+			return a.After(*b)
+		case (a == nil) != (b == nil):
+			//articles never published go last
+			return a != nil
+		default:
+			return newerThan(found[i], found[j])
+		}
+	})
+	if len(found) > limit {
+		found = found[:limit]
+	}
+	return m.articleModels(found, false), nil
+}
+
+//ExportArticleRows calls fn for every live article matching the filter, oldest first. The matching articles are copied
+//before fn is called so fn can take as long as it needs. An error from fn stops the export and is returned
+func (m *MemoryDBClient) ExportArticleRows(filter models.ArticleFilter, fn func(article *models.Article) error) error {
+	m.mu.RLock()
+	tagIDs := m.matchingTagIDs(models.TagSlug(filter.Tag), false)
+	found := m.filterArticles(func(stored *memoryArticle) bool {
+		return m.visible(stored, filter.PublishedOnly) &&
+			(filter.Tag == "" || carriesAny(stored, tagIDs)) &&
+			(filter.From == nil || stored.article.Day >= filter.From.Format(models.DayFormat)) &&
+			(filter.To == nil || stored.article.Day <= filter.To.Format(models.DayFormat))
+	})
+	sort.Slice(found, func(i, j int) bool { return found[i].id < found[j].id })
+	exported := m.articleModels(found, true)
+	m.mu.RUnlock()
+
+	for i := range *exported {
+		err := fn(&(*exported)[i])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//UpdateArticleStatus moves an article from one status to another. Returns false if the article was not in fromStatus
+func (m *MemoryDBClient) UpdateArticleStatus(id int, fromStatus, toStatus string, publishAt *time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.articles[id]
+	if !ok || stored.article.DeletedAt != nil || stored.article.Status != fromStatus {
+		return false, nil
+	}
+	stored.article.Status = toStatus
+	stored.article.PublishAt = copyTime(publishAt)
+	if toStatus == models.StatusPublished {
+		now := m.now().UTC()
+		stored.article.PublishedAt = &now
+	}
+	return true, nil
+}
+
+//PublishScheduledArticleRows publishes every scheduled article whose publish time has passed
+func (m *MemoryDBClient) PublishScheduledArticleRows(now time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var published int64
+	for _, stored := range m.articles {
+		if stored.article.Status != models.StatusScheduled || stored.article.DeletedAt != nil ||
+			stored.article.PublishAt == nil || stored.article.PublishAt.After(now) {
+			continue
+		}
+		stored.article.Status = models.StatusPublished
+		stored.article.PublishedAt = copyTime(stored.article.PublishAt)
+		published++
+	}
+	m.Logger.Infof("PublishScheduledArticleRows :: published %d scheduled rows", published)
+	return published, nil
+}
+
+//DeleteArticleByID soft deletes an article by id. Returns false if there was no live article with that id
+func (m *MemoryDBClient) DeleteArticleByID(id int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.articles[id]
+	if !ok || stored.article.DeletedAt != nil {
+		return false, nil
+	}
+	now := m.now().UTC()
+	stored.article.DeletedAt = &now
+	return true, nil
+}
+
+//GetDeletedArticleRows returns every soft deleted article that has not been purged yet, most recently deleted first, without their bodies
+func (m *MemoryDBClient) GetDeletedArticleRows() (*[]models.Article, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	found := m.filterArticles(func(stored *memoryArticle) bool {
+		return stored.article.DeletedAt != nil
+	})
+	sort.Slice(found, func(i, j int) bool {
+		a, b := found[i].article.DeletedAt, found[j].article.DeletedAt
+		if a.Equal(*b) {
+			return found[i].id > found[j].id
+		}
+		return a.After(*b)
+	})
+	return m.articleModels(found, false), nil
+}
+
+//RestoreArticleByID clears the deleted flag on an article. Returns false if there was no deleted article with that id
+func (m *MemoryDBClient) RestoreArticleByID(id int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.articles[id]
+	if !ok || stored.article.DeletedAt == nil {
+		return false, nil
+	}
+	stored.article.DeletedAt = nil
+	return true, nil
+}
+
+//PurgeDeletedArticleRows permanently removes articles that were soft deleted before the given time
+func (m *MemoryDBClient) PurgeDeletedArticleRows(deletedBefore time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var purged int64
+	for id, stored := range m.articles {
+		if stored.article.DeletedAt != nil && stored.article.DeletedAt.Before(deletedBefore) {
+			delete(m.articles, id)
+			purged++
+		}
+	}
+	m.Logger.Infof("PurgeDeletedArticleRows :: purged %d rows deleted before %v", purged, deletedBefore)
+	return purged, nil
+}
+
+//GetTagRows returns a page of tags with their article counts in the given sort order, along with the total number of tags.
+//publishedOnly only counts published articles and leaves out tags that have none
+func (m *MemoryDBClient) GetTagRows(sortBy string, limit, offset int, publishedOnly bool) (*[]models.Tag, int, error) {
+	if _, ok := tagSortOrders[sortBy]; !ok {
+		return nil, 0, fmt.Errorf("unknown tag sort order %s", sortBy)
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tags := m.tagModels(publishedOnly)
+	sort.Slice(tags, func(i, j int) bool {
+		if sortBy == models.TagSortUsage && tags[i].ArticleCount != tags[j].ArticleCount {
+			return tags[i].ArticleCount > tags[j].ArticleCount
+		}
+		return tags[i].Slug < tags[j].Slug
+	})
+
+	total := len(tags)
+	page := []models.Tag{}
+	if offset < total {
+		page = tags[offset:]
+	}
+	if len(page) > limit {
+		page = page[:limit]
+	}
+	return &page, total, nil
+}
+
+//GetTagRowBySlug returns the tag with the slug or alias and its article counts, or nil if there is no such tag.
+//publishedOnly only counts published articles and treats a tag without any as not found
+func (m *MemoryDBClient) GetTagRowBySlug(slug string, publishedOnly bool) (*models.Tag, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	id, ok := m.tagID(slug)
+	if !ok {
+		return nil, nil
+	}
+	tag := m.tagModel(m.tags[id], publishedOnly)
+	if publishedOnly && tag.ArticleCount == 0 {
+		return nil, nil
+	}
+	return tag, nil
+}
+
+//GetTagSuggestionRows returns the tags whose slug or one of whose aliases starts with the prefix, by most used first.
+//publishedOnly only counts published articles and leaves out tags that have none
+func (m *MemoryDBClient) GetTagSuggestionRows(prefix string, limit int, publishedOnly bool) (*[]models.Tag, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	matching := map[int]bool{}
+	for slug, id := range m.tagsBySlug {
+		if strings.HasPrefix(slug, prefix) {
+			matching[id] = true
+		}
+	}
+	for alias, id := range m.aliases {
+		if strings.HasPrefix(alias, prefix) {
+			matching[id] = true
+		}
+	}
+
+	tags := []models.Tag{}
+	for id := range matching {
+		tag := m.tagModel(m.tags[id], publishedOnly)
+		if publishedOnly && tag.ArticleCount == 0 {
+			continue
+		}
+		tags = append(tags, models.Tag{Slug: tag.Slug, Name: tag.Name, ArticleCount: tag.ArticleCount})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].ArticleCount != tags[j].ArticleCount {
+			return tags[i].ArticleCount > tags[j].ArticleCount
+		}
+		return tags[i].Slug < tags[j].Slug
+	})
+	if len(tags) > limit {
+		tags = tags[:limit]
+	}
+	return &tags, nil
+}
+
+//RenameTagRow renames the tag with the slug, changing its slug to match the new name. Returns false if there is no tag
+//with the slug, and ErrTagExists if the new name belongs to another tag or is another tag's alias
+func (m *MemoryDBClient) RenameTagRow(slug, name string) (bool, error) {
+	renamed := models.NewTag(name)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id, ok := m.tagsBySlug[slug]
+	if !ok {
+		return false, nil
+	}
+	if aliasOf, ok := m.aliases[renamed.Slug]; ok && aliasOf != id {
+		return false, ErrTagExists
+	}
+	if other, ok := m.tagsBySlug[renamed.Slug]; ok && other != id {
+		return false, ErrTagExists
+	}
+
+	delete(m.aliases, renamed.Slug)
+	delete(m.tagsBySlug, slug)
+	m.tagsBySlug[renamed.Slug] = id
+	m.tags[id].slug = renamed.Slug
+	m.tags[id].name = renamed.Name
+	return true, nil
+}
+
+//MergeTagRows moves every article carrying the fromSlug tag onto the intoSlug tag and removes the fromSlug tag. Articles
+//already carrying both keep the intoSlug tag where it was. The fromSlug tag's slug and aliases become aliases of the
+//intoSlug tag and its children move under it. Returns false if either tag doesn't exist
+func (m *MemoryDBClient) MergeTagRows(fromSlug, intoSlug string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fromID, fromOK := m.tagsBySlug[fromSlug]
+	intoID, intoOK := m.tagsBySlug[intoSlug]
+	if !fromOK || !intoOK {
+		return false, nil
+	}
+
+	for _, stored := range m.articles {
+		if !carriesAny(stored, map[int]bool{fromID: true}) {
+			continue
+		}
+		tagIDs := []int{}
+		for _, id := range stored.tagIDs {
+			switch {
+			case id == fromID && !carriesAny(stored, map[int]bool{intoID: true}):
+				tagIDs = append(tagIDs, intoID)
+			case id != fromID:
+				tagIDs = append(tagIDs, id)
+			}
+		}
+		stored.tagIDs = tagIDs
+	}
+
+	for alias, id := range m.aliases {
+		if id == fromID {
+			m.aliases[alias] = intoID
+		}
+	}
+	m.aliases[fromSlug] = intoID
+
+	//when the intoSlug tag is below the fromSlug tag it takes the fromSlug tag's place first, so moving the children can't make a loop
+	if m.tagTree(fromID)[intoID] {
+		m.tags[intoID].parentID = m.tags[fromID].parentID
+	}
+	for _, tag := range m.tags {
+		if tag.parentID == fromID && tag.id != intoID {
+			tag.parentID = intoID
+		}
+	}
+
+	delete(m.tags, fromID)
+	delete(m.tagsBySlug, fromSlug)
+	return true, nil
+}
+
+//AddTagAliasRow makes alias another slug for the tag with the slug. Returns false if there is no tag with the slug,
+//and ErrTagExists if the alias is a tag or another tag's alias
+func (m *MemoryDBClient) AddTagAliasRow(slug, alias string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id, ok := m.tagsBySlug[slug]
+	if !ok {
+		return false, nil
+	}
+	if _, ok := m.tagsBySlug[alias]; ok {
+		return false, ErrTagExists
+	}
+	if aliasOf, ok := m.aliases[alias]; ok {
+		if aliasOf == id {
+			return true, nil
+		}
+		return false, ErrTagExists
+	}
+	m.aliases[alias] = id
+	return true, nil
+}
+
+//RemoveTagAliasRow removes an alias from the tag with the slug. Returns false if the tag has no such alias
+func (m *MemoryDBClient) RemoveTagAliasRow(slug, alias string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id, ok := m.tagsBySlug[slug]
+	if !ok || m.aliases[alias] != id {
+		return false, nil
+	}
+	delete(m.aliases, alias)
+	return true, nil
+}
+
+//SetTagParentRow puts the tag with the slug below the tag with parentSlug, or at the top when parentSlug is empty.
+//Returns false if either tag doesn't exist, and ErrTagCycle if the parent is the tag or below it
+func (m *MemoryDBClient) SetTagParentRow(slug, parentSlug string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id, ok := m.tagsBySlug[slug]
+	if !ok {
+		return false, nil
+	}
+	parentID := 0
+	if parentSlug != "" {
+		parentID, ok = m.tagsBySlug[parentSlug]
+		if !ok {
+			return false, nil
+		}
+		if m.tagTree(id)[parentID] {
+			return false, ErrTagCycle
+		}
+	}
+	m.tags[id].parentID = parentID
+	return true, nil
+}
+
+//GetTrendingTagRows compares each tag's use on articles created in the last days with the days before that and returns
+//the tags whose use went up, by most gained first. publishedOnly only counts published articles
+func (m *MemoryDBClient) GetTrendingTagRows(days, limit int, publishedOnly bool) (*[]models.TrendingTag, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := m.now()
+	currentFrom := now.AddDate(0, 0, -days)
+	previousFrom := now.AddDate(0, 0, -days*2)
+
+	counts := map[int]*models.TrendingTag{}
+	for _, stored := range m.articles {
+		if !m.visible(stored, publishedOnly) || stored.createdAt.Before(previousFrom) {
+			continue
+		}
+		for _, id := range stored.tagIDs {
+			count, ok := counts[id]
+			if !ok {
+				count = &models.TrendingTag{Slug: m.tags[id].slug, Name: m.tags[id].name}
+				counts[id] = count
+			}
+			if stored.createdAt.Before(currentFrom) {
+				count.PreviousCount++
+			} else {
+				count.CurrentCount++
+			}
+		}
+	}
+
+	tags := []models.TrendingTag{}
+	for _, count := range counts {
+		if count.CurrentCount > count.PreviousCount {
+			tags = append(tags, *count)
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		gainI, gainJ := tags[i].CurrentCount-tags[i].PreviousCount, tags[j].CurrentCount-tags[j].PreviousCount
+		if gainI != gainJ {
+			return gainI > gainJ
+		}
+		if tags[i].CurrentCount != tags[j].CurrentCount {
+			return tags[i].CurrentCount > tags[j].CurrentCount
+		}
+		return tags[i].Slug < tags[j].Slug
+	})
+	if len(tags) > limit {
+		tags = tags[:limit]
+	}
+	return &tags, nil
+}
+
+//GetRelatedTagRows returns the tags appearing on the same articles as the tag with the slug or alias, counting articles created
+//in the last days. They are ordered by how many articles carry both tags then by their Jaccard index.
+//publishedOnly only counts published articles
+func (m *MemoryDBClient) GetRelatedTagRows(slug string, days, limit int, publishedOnly bool) (*[]models.RelatedTag, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tags := []models.RelatedTag{}
+	targetID, ok := m.tagID(slug)
+	if !ok {
+		return &tags, nil
+	}
+
+	from := m.now().AddDate(0, 0, -days)
+	tagCounts, coCounts := map[int]int{}, map[int]int{}
+	targetCount := 0
+	for _, stored := range m.articles {
+		if !m.visible(stored, publishedOnly) || stored.createdAt.Before(from) {
+			continue
+		}
+		hasTarget := carriesAny(stored, map[int]bool{targetID: true})
+		if hasTarget {
+			targetCount++
+		}
+		for _, id := range stored.tagIDs {
+			tagCounts[id]++
+			if hasTarget && id != targetID {
+				coCounts[id]++
+			}
+		}
+	}
+
+	for id, count := range coCounts {
+		tags = append(tags, models.RelatedTag{
+			Slug:    m.tags[id].slug,
+			Name:    m.tags[id].name,
+			Count:   count,
+			Jaccard: float64(count) / float64(tagCounts[id]+targetCount-count),
+		})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		if tags[i].Jaccard != tags[j].Jaccard {
+			return tags[i].Jaccard > tags[j].Jaccard
+		}
+		return tags[i].Slug < tags[j].Slug
+	})
+	if len(tags) > limit {
+		tags = tags[:limit]
+	}
+	return &tags, nil
+}
+
+//GetArticleIDByContentHash returns the id of a live article published on the day whose title and body have the content
+//hash, or 0 when there is none
+func (m *MemoryDBClient) GetArticleIDByContentHash(contentHash, day string) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	found := m.filterArticles(func(stored *memoryArticle) bool {
+		return stored.article.DeletedAt == nil && stored.contentHash == contentHash && stored.article.Day == day
+	})
+	id := 0
+	for _, stored := range found {
+		if id == 0 || stored.id < id {
+			id = stored.id
+		}
+	}
+	return id, nil
+}
+
+//GetArticleFingerprintRows returns the simhash of every live article published between the days, both included.
+//Articles created before simhashes were kept are left out
+func (m *MemoryDBClient) GetArticleFingerprintRows(fromDay, toDay string) (*[]models.ArticleFingerprint, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	found := m.filterArticles(func(stored *memoryArticle) bool {
+		return stored.article.DeletedAt == nil && stored.simhash != nil && stored.article.Day >= fromDay && stored.article.Day <= toDay
+	})
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].id < found[j].id
+	})
+	fingerprints := make([]models.ArticleFingerprint, len(found))
+	for i, stored := range found {
+		fingerprints[i] = models.ArticleFingerprint{
+			ID:      stored.article.ID,
+			Title:   stored.article.Title,
+			Day:     stored.article.Day,
+			Simhash: *stored.simhash,
+		}
+	}
+	return &fingerprints, nil
+}
+
+//ReserveIdempotencyKey reserves the key for the request with the hash until expiresAt, returning nil when it did. When an
+//unexpired request already holds the key what is stored for it is returned instead. Expired keys are removed as of now
+func (m *MemoryDBClient) ReserveIdempotencyKey(key, requestHash string, now, expiresAt time.Time) (*models.IdempotentResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for storedKey, stored := range m.idempotencyKeys {
+		if !stored.expiresAt.After(now) {
+			delete(m.idempotencyKeys, storedKey)
+		}
+	}
+	if stored, ok := m.idempotencyKeys[key]; ok {
+		response := stored.response
+		response.Body = append([]byte(nil), stored.response.Body...)
+		return &response, nil
+	}
+	m.idempotencyKeys[key] = &memoryIdempotencyKey{
+		response:  models.IdempotentResponse{RequestHash: requestHash},
+		expiresAt: expiresAt,
+	}
+	return nil, nil
+}
+
+//SaveIdempotentResponse stores the response to the request holding the key
+func (m *MemoryDBClient) SaveIdempotentResponse(key string, response *models.IdempotentResponse) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.idempotencyKeys[key]
+	if !ok {
+		return nil
+	}
+	stored.response.StatusCode = response.StatusCode
+	stored.response.ContentType = response.ContentType
+	stored.response.Body = append([]byte(nil), response.Body...)
+	return nil
+}
+
+//ReleaseIdempotencyKey removes the key, so the next request sent with it is handled as a new one
+func (m *MemoryDBClient) ReleaseIdempotencyKey(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.idempotencyKeys, key)
+	return nil
+}
+
+//tagID returns the id of the tag whose slug, or one of whose aliases, is the slug
+func (m *MemoryDBClient) tagID(slug string) (int, bool) {
+	if id, ok := m.tagsBySlug[slug]; ok {
+		return id, true
+	}
+	id, ok := m.aliases[slug]
+	return id, ok
+}
+
+//matchingTagIDs returns the id of the tag with the slug or alias, along with every tag below it when includeDescendants is set
+func (m *MemoryDBClient) matchingTagIDs(slug string, includeDescendants bool) map[int]bool {
+	id, ok := m.tagID(slug)
+	if !ok {
+		return map[int]bool{}
+	}
+	if includeDescendants {
+		return m.tagTree(id)
+	}
+	return map[int]bool{id: true}
+}
+
+//tagTree returns the id along with the ids of every tag below it
+func (m *MemoryDBClient) tagTree(id int) map[int]bool {
+	tree := map[int]bool{id: true}
+	for grew := true; grew; {
+		grew = false
+		for _, tag := range m.tags {
+			if tree[tag.parentID] && !tree[tag.id] {
+				tree[tag.id] = true
+				grew = true
+			}
+		}
+	}
+	return tree
+}
+
+//visible reports whether the article is live and, when publishedOnly is set, published
+func (m *MemoryDBClient) visible(stored *memoryArticle, publishedOnly bool) bool {
+	return stored.article.DeletedAt == nil && (!publishedOnly || stored.article.Status == models.StatusPublished)
+}
+
+//filterArticles returns the stored articles that match
+func (m *MemoryDBClient) filterArticles(match func(stored *memoryArticle) bool) []*memoryArticle {
+	found := []*memoryArticle{}
+	for _, stored := range m.articles {
+		if match(stored) {
+			found = append(found, stored)
+		}
+	}
+	return found
+}
+
+//articleModel copies a stored article with its tag names, leaving the body out unless withBody is set
+func (m *MemoryDBClient) articleModel(stored *memoryArticle, withBody bool) *models.Article {
+	article := stored.article
+	if !withBody {
+		article.Body = ""
+	}
+	article.Tags = make([]string, len(stored.tagIDs))
+	for i, id := range stored.tagIDs {
+		article.Tags[i] = m.tags[id].name
+	}
+	article.PublishAt = copyTime(article.PublishAt)
+	article.PublishedAt = copyTime(article.PublishedAt)
+	article.DeletedAt = copyTime(article.DeletedAt)
+	return &article
+}
+
+func (m *MemoryDBClient) articleModels(stored []*memoryArticle, withBody bool) *[]models.Article {
+	articles := make([]models.Article, len(stored))
+	for i := range stored {
+		articles[i] = *m.articleModel(stored[i], withBody)
+	}
+	return &articles
+}
+
+//tagModel returns the tag with its parent, aliases and the number of live articles carrying it
+func (m *MemoryDBClient) tagModel(stored *memoryTag, publishedOnly bool) *models.Tag {
+	tag := &models.Tag{
+		ID:        stored.id,
+		Slug:      stored.slug,
+		Name:      stored.name,
+		CreatedAt: stored.createdAt,
+		Aliases:   []string{},
+	}
+	if parent, ok := m.tags[stored.parentID]; ok {
+		tag.Parent = parent.slug
+	}
+	for alias, id := range m.aliases {
+		if id == stored.id {
+			tag.Aliases = append(tag.Aliases, alias)
+		}
+	}
+	sort.Strings(tag.Aliases)
+
+	for _, article := range m.articles {
+		if !m.visible(article, publishedOnly) || !carriesAny(article, map[int]bool{stored.id: true}) {
+			continue
+		}
+		tag.ArticleCount++
+		if tag.LastUsedAt == nil || article.createdAt.After(*tag.LastUsedAt) {
+			tag.LastUsedAt = copyTime(&article.createdAt)
+		}
+	}
+	return tag
+}
+
+//tagModels returns every tag, leaving out those without a published article when publishedOnly is set
+func (m *MemoryDBClient) tagModels(publishedOnly bool) []models.Tag {
+	tags := []models.Tag{}
+	for _, stored := range m.tags {
+		tag := m.tagModel(stored, publishedOnly)
+		if publishedOnly && tag.ArticleCount == 0 {
+			continue
+		}
+		tags = append(tags, *tag)
+	}
+	return tags
+}
+
+//carriesAny reports whether the article carries any of the tags
+func carriesAny(stored *memoryArticle, tagIDs map[int]bool) bool {
+	for _, id := range stored.tagIDs {
+		if tagIDs[id] {
+			return true
+		}
+	}
+	return false
+}
+
+//newerThan orders articles by when they were created, newest first
+func newerThan(a, b *memoryArticle) bool {
+	if a.createdAt.Equal(b.createdAt) {
+		return a.id > b.id
+	}
+	return a.createdAt.After(b.createdAt)
+}
+
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	copied := *t
+	return &copied
+}
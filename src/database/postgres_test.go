@@ -1,13 +1,18 @@
 package database
 
 import (
+	"database/sql"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sync/atomic"
 	"testing"
-	"time"
 
+	"github.com/lib/pq"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var (
@@ -20,71 +25,94 @@ var (
 	expectedDateFormatString = "2006-01-02"
 )
 
-//These tests are assuming being run on a clean DB. i.e. first test returns id 1
-func TestPostgresDBClient(t *testing.T) {
+//TestPostgresDBClientConformance runs the conformance suite against postgres, once on tables made by schema.sql and once on
+//tables made by applying the migrations in order. Every case gets a schema of its own which is dropped when the case finishes
+func TestPostgresDBClientConformance(t *testing.T) {
+	adminDB, connStr := openTestPostgres(t)
+	schema := readTestSchema(t)
+	migrationFiles, err := filepath.Glob("../../scripts/sql/migrations/*.sql")
+	require.NoError(t, err)
+	require.NotEmpty(t, migrationFiles)
+	migrations := []string{}
+	for _, migrationFile := range migrationFiles {
+		migration, err := ioutil.ReadFile(migrationFile)
+		require.NoError(t, err)
+		migrations = append(migrations, string(migration))
+	}
+
+	t.Run("Given tables made by schema.sql", func(t *testing.T) {
+		runConformanceSuite(t, newPostgresSchemaClient(adminDB, connStr, []string{schema}))
+	})
+	t.Run("Given tables made by the migrations", func(t *testing.T) {
+		runConformanceSuite(t, newPostgresSchemaClient(adminDB, connStr, migrations))
+	})
+}
+
+//openTestPostgres connects to the test postgres, skipping the test or benchmark when it can't be reached. It returns the
+//connection and the connection string new connections use
+func openTestPostgres(t testing.TB) (*sql.DB, string) {
 	initDBEnvVars()
-	testLogger := newTestLogger()
-
-	idsToDelete := []int{}
-	t.Run("CreateArticleRow", func(t *testing.T) {
-		var testID int
-		testTitle := "testTitle"
-		testBody := "testBody"
-		testDate, _ := time.Parse(expectedDateFormatString, "1991-01-01")
-		testTags := []string{"TestTag1", "TestTag2", "TestTag3"}
-
-		dbClient := NewArticleDBClient(DBUSER, DBNAME, DBPASSWORD, DBHOST, DBPORT, testLogger)
-		t.Run("Given valid input a row gets created and the row ID returned without errors", func(t *testing.T) {
-
-			resultRow, err := dbClient.CreateArticleRow(testTitle, testBody, testDate, testTags)
-
-			t.Run("No error occured", func(t *testing.T) {
-				assert.NoError(t, err)
-			})
-
-			t.Run("The data returned is correct", func(t *testing.T) {
-				assert.Equal(t, 1, resultRow)
-			})
-
-			testID = resultRow
-			idsToDelete = append(idsToDelete, testID)
-		})
-		t.Run("Given valid id an article can be returned without errors", func(t *testing.T) {
-			resultArticle, err := dbClient.GetArticleRowByID(testID)
-
-			t.Run("No error occured", func(t *testing.T) {
-				assert.NoError(t, err)
-			})
-
-			t.Run("The data returned is correct", func(t *testing.T) {
-				assert.Equal(t, testTitle, resultArticle.Title)
-				assert.Equal(t, testBody, resultArticle.Body)
-				assert.Equal(t, "1991-01-01", resultArticle.Date.Format(expectedDateFormatString))
-				assert.Equal(t, testTags, resultArticle.Tags)
-			})
-		})
-		t.Run("Given valid tag and date the correct stats are returned without errors", func(t *testing.T) {
-			resultArticles, err := dbClient.GetArticleRowByTagAndDate("TestTag1", testDate.Format(expectedDateFormatString))
-
-			t.Run("No error occured", func(t *testing.T) {
-				assert.NoError(t, err)
-			})
-
-			t.Run("The data returned is correct", func(t *testing.T) {
-				assert.Equal(t, 1, len(*resultArticles))
-			})
-		})
-		t.Run("Given valid id the artcile can be deleted without errors", func(t *testing.T) {
-			for _, i := range idsToDelete {
-				err := dbClient.DeleteArticleByID(i)
-
-				t.Run(fmt.Sprintf("No error occured for id %d", i), func(t *testing.T) {
-					assert.NoError(t, err)
-				})
-			}
-		})
+	connStr := fmt.Sprintf("host=%s port=%s password=%s user=%s dbname=%s sslmode=disable", DBHOST, DBPORT, DBPASSWORD, DBUSER, DBNAME)
+	adminDB, err := sql.Open("postgres", connStr)
+	require.NoError(t, err)
+	t.Cleanup(func() { adminDB.Close() })
+	if err := adminDB.Ping(); err != nil {
+		t.Skipf("postgres is not reachable, run scripts/sql/init.sh to start it: %v", err)
+	}
+	return adminDB, connStr
+}
+
+func TestPostgresErrorCodes(t *testing.T) {
+	t.Run("Given a wrapped postgres error, its code is still recognised", func(t *testing.T) {
+		assert.True(t, isUniqueViolation(fmt.Errorf("inserting tag: %w", &pq.Error{Code: "23505"})))
+		assert.True(t, isSerializationFailure(fmt.Errorf("committing: %w", &pq.Error{Code: "40001"})))
+		assert.False(t, isUniqueViolation(fmt.Errorf("inserting tag: %w", &pq.Error{Code: "23503"})))
 	})
+}
+
+//readTestSchema returns the statements of schema.sql
+func readTestSchema(t testing.TB) string {
+	schema, err := ioutil.ReadFile("../../scripts/sql/schema.sql")
+	require.NoError(t, err)
+	return string(schema)
+}
+
+var postgresSchemaCount uint64
+
+//newPostgresSchemaClient returns a func making clients that each work in a new schema, set up by running setup in order
+func newPostgresSchemaClient(adminDB *sql.DB, connStr string, setup []string) func(t *testing.T) DBClient {
+	return func(t *testing.T) DBClient {
+		db := newPostgresSchemaDB(t, adminDB, connStr, setup)
+		stmts, err := prepareStatements(db)
+		require.NoError(t, err)
+		return &ArticleDBClient{
+			DB:     db,
+			Logger: newTestLogger(),
+			stmts:  stmts,
+		}
+	}
+}
+
+//newPostgresSchemaDB returns a connection to a new schema, set up by running setup in order and dropped when the test ends
+func newPostgresSchemaDB(t testing.TB, adminDB *sql.DB, connStr string, setup []string) *sql.DB {
+	schema := fmt.Sprintf("conformance_%d_%d", os.Getpid(), atomic.AddUint64(&postgresSchemaCount, 1))
+	_, err := adminDB.Exec("CREATE SCHEMA " + schema)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_, err := adminDB.Exec("DROP SCHEMA " + schema + " CASCADE")
+		assert.NoError(t, err)
+	})
+
+	//every connection in the pool only sees the tables in the case's schema
+	db, err := sql.Open("postgres", connStr+" search_path="+schema)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	for _, statements := range setup {
+		_, err := db.Exec(statements)
+		require.NoError(t, err)
+	}
 
+	return db
 }
 
 func newTestLogger() *logrus.Entry {
@@ -0,0 +1,165 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+//replicaHealthCheckInterval is how often replicas are pinged, taking failing ones out of rotation and putting recovered ones back
+const replicaHealthCheckInterval = 5 * time.Second
+
+//replicaPingTimeout is how long a replica has to answer a health check
+const replicaPingTimeout = 2 * time.Second
+
+//replicaRecheckInterval is the least time between the checks a replica gets straight away when reads fail on it, so a
+//replica that is down isn't pinged by every read in flight
+const replicaRecheckInterval = time.Second
+
+//replicaPool round-robins reads over the postgres read replicas that passed their last health check
+type replicaPool struct {
+	replicas []*replica
+	next     uint64
+	logger   *logrus.Entry
+
+	//stop is closed by close to end watch
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+type replica struct {
+	//lastRecheck comes first so it is 64 bit aligned for atomic. It is when, in unix nanoseconds, the replica was last
+	//checked because a read failed on it
+	lastRecheck int64
+
+	//index identifies the replica in logs, its DSN holding the password
+	index   int
+	db      *sql.DB
+	healthy int32
+
+	//stmts are prepared on the replica the first time they are run, so a replica that is down at start doesn't stop the API
+	stmts *preparedStatements
+}
+
+//newReplicaPool opens a connection pool to each replica and checks them once. Replicas that can't be reached start out
+//of rotation rather than stopping the API, reads go to the primary until they recover
+func newReplicaPool(dsns []string, logger *logrus.Entry) *replicaPool {
+	pool := &replicaPool{logger: logger, stop: make(chan struct{})}
+	for i, dsn := range dsns {
+		db, err := sql.Open("postgres", dsn)
+		if err != nil {
+			log.Fatalf("newReplicaPool :: Error opening up replica %d : %v", i, err)
+		}
+		pool.replicas = append(pool.replicas, &replica{index: i, db: db, stmts: newPreparedStatements(db)})
+	}
+	pool.checkHealth()
+	return pool
+}
+
+//pick returns the next healthy replica, or nil when there are none
+func (p *replicaPool) pick() *replica {
+	if p == nil || len(p.replicas) == 0 {
+		return nil
+	}
+	start := atomic.AddUint64(&p.next, 1)
+	for i := range p.replicas {
+		r := p.replicas[(start+uint64(i))%uint64(len(p.replicas))]
+		if atomic.LoadInt32(&r.healthy) == 1 {
+			return r
+		}
+	}
+	return nil
+}
+
+//watch checks the health of every replica each interval until the pool is closed, so it is meant to be run in its own
+//goroutine
+func (p *replicaPool) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.checkHealth()
+		}
+	}
+}
+
+//close stops watch and closes the connection pool of every replica
+func (p *replicaPool) close() error {
+	var err error
+	p.closeOnce.Do(func() {
+		close(p.stop)
+		for _, r := range p.replicas {
+			if closeErr := r.db.Close(); closeErr != nil && err == nil {
+				err = closeErr
+			}
+		}
+	})
+	return err
+}
+
+//checkHealth pings every replica, updating whether it is in rotation
+func (p *replicaPool) checkHealth() {
+	for _, r := range p.replicas {
+		p.check(r)
+	}
+}
+
+//recheck checks the replica in the background after a read failed on it, at most once every replicaRecheckInterval
+func (p *replicaPool) recheck(r *replica) {
+	now := time.Now().UnixNano()
+	last := atomic.LoadInt64(&r.lastRecheck)
+	if now-last < int64(replicaRecheckInterval) || !atomic.CompareAndSwapInt64(&r.lastRecheck, last, now) {
+		return
+	}
+	go p.check(r)
+}
+
+//check pings the replica, taking it out of rotation if it doesn't answer and putting it back once it does
+func (p *replicaPool) check(r *replica) {
+	ctx, cancel := context.WithTimeout(context.Background(), replicaPingTimeout)
+	defer cancel()
+
+	err := r.db.PingContext(ctx)
+	healthy := int32(0)
+	if err == nil {
+		healthy = 1
+	}
+	if atomic.SwapInt32(&r.healthy, healthy) == healthy {
+		return
+	}
+	if err != nil {
+		p.logger.Warnf("replicaPool :: replica %d is out of rotation : %v", r.index, err)
+		return
+	}
+	p.logger.Infof("replicaPool :: replica %d is in rotation", r.index)
+}
+
+//isConnectionError reports whether err means the connection to the database failed rather than the query, so running the
+//query again on another database could succeed. pq reports a connection lost mid query as an io error, and a server that
+//is shutting down or can't take connections yet with a class 08 or 57P error
+func isConnectionError(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && (pqErr.Code.Class() == "08" || strings.HasPrefix(string(pqErr.Code), "57P"))
+}
@@ -0,0 +1,153 @@
+package database
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//newTestReplicaClient returns a client whose primary and replicas are empty sqlite databases, so which one a read went
+//to can be told apart without postgres
+func newTestReplicaClient(t *testing.T, replicaCount int) *ArticleDBClient {
+	open := func() *sql.DB {
+		db, err := sql.Open("sqlite3", ":memory:")
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+		return db
+	}
+
+	pool := &replicaPool{logger: newTestLogger(), stop: make(chan struct{})}
+	for i := 0; i < replicaCount; i++ {
+		pool.replicas = append(pool.replicas, &replica{index: i, db: open()})
+	}
+	pool.checkHealth()
+	return &ArticleDBClient{DB: open(), Logger: newTestLogger(), replicas: pool}
+}
+
+//readFrom returns which database the client's next read goes to, -1 being the primary
+func readFrom(t *testing.T, client *ArticleDBClient) int {
+	var conn sqlExecutor
+	err := client.read("readFrom", func(c sqlExecutor) error {
+		conn = c
+		return nil
+	})
+	require.NoError(t, err)
+	for _, r := range client.replicas.replicas {
+		if conn == r.db {
+			return r.index
+		}
+	}
+	return -1
+}
+
+func TestReplicaRouting(t *testing.T) {
+	t.Run("Given healthy replicas, reads take turns between them", func(t *testing.T) {
+		client := newTestReplicaClient(t, 2)
+
+		first, second, third := readFrom(t, client), readFrom(t, client), readFrom(t, client)
+		assert.NotEqual(t, -1, first)
+		assert.NotEqual(t, first, second)
+		assert.Equal(t, first, third)
+	})
+	t.Run("Given a replica failing its health check, it is left out until it recovers", func(t *testing.T) {
+		client := newTestReplicaClient(t, 2)
+		client.replicas.replicas[0].db.Close()
+		client.replicas.checkHealth()
+
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, 1, readFrom(t, client))
+		}
+	})
+	t.Run("Given no healthy replica, reads go to the primary", func(t *testing.T) {
+		client := newTestReplicaClient(t, 1)
+		client.replicas.replicas[0].db.Close()
+		client.replicas.checkHealth()
+
+		assert.Equal(t, -1, readFrom(t, client))
+	})
+	t.Run("Given a read failing on a replica's connection, it is run again on the primary", func(t *testing.T) {
+		client := newTestReplicaClient(t, 1)
+
+		conns := []sqlExecutor{}
+		err := client.read("failover", func(conn sqlExecutor) error {
+			conns = append(conns, conn)
+			if len(conns) == 1 {
+				return driver.ErrBadConn
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		require.Equal(t, 2, len(conns))
+		assert.Equal(t, client.replicas.replicas[0].db, conns[0])
+		assert.Equal(t, client.DB, conns[1])
+	})
+	t.Run("Given a query failing on a replica, its error is returned without reading from the primary", func(t *testing.T) {
+		client := newTestReplicaClient(t, 1)
+
+		reads := 0
+		err := client.read("failover", func(conn sqlExecutor) error {
+			reads++
+			return &pq.Error{Code: "22P02"}
+		})
+		assert.Error(t, err)
+		assert.Equal(t, 1, reads)
+	})
+	t.Run("Given the primary client, reads go to the primary", func(t *testing.T) {
+		client := newTestReplicaClient(t, 2)
+
+		assert.Equal(t, -1, readFrom(t, client.Primary().(*ArticleDBClient)))
+	})
+	t.Run("Given no replicas, reads go to the primary", func(t *testing.T) {
+		client := newTestReplicaClient(t, 0)
+
+		assert.Equal(t, -1, readFrom(t, client))
+	})
+}
+
+func TestReplicaPool(t *testing.T) {
+	t.Run("Given reads failing on a replica, it is rechecked at most once a recheck interval", func(t *testing.T) {
+		client := newTestReplicaClient(t, 1)
+		r := client.replicas.replicas[0]
+
+		client.replicas.recheck(r)
+		first := atomic.LoadInt64(&r.lastRecheck)
+		client.replicas.recheck(r)
+
+		assert.NotZero(t, first)
+		assert.Equal(t, first, atomic.LoadInt64(&r.lastRecheck))
+	})
+	t.Run("Given the pool is closed, watch returns", func(t *testing.T) {
+		client := newTestReplicaClient(t, 1)
+		done := make(chan struct{})
+		go func() {
+			client.replicas.watch(time.Millisecond)
+			close(done)
+		}()
+
+		assert.NoError(t, client.Close())
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("watch didn't return once the pool was closed")
+		}
+	})
+	t.Run("Given connection and query errors, only connection errors fail over", func(t *testing.T) {
+		assert.True(t, isConnectionError(driver.ErrBadConn))
+		assert.True(t, isConnectionError(fmt.Errorf("reading: %w", io.ErrUnexpectedEOF)))
+		assert.True(t, isConnectionError(&net.OpError{Op: "read", Err: errors.New("connection reset")}))
+		assert.True(t, isConnectionError(&pq.Error{Code: "57P01"}))
+		assert.False(t, isConnectionError(&pq.Error{Code: "42P01"}))
+		assert.False(t, isConnectionError(errors.New("query failed")))
+	})
+}
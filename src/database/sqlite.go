@@ -0,0 +1,1070 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bmordt/article-api/src/models"
+
+	"github.com/mattn/go-sqlite3"
+
+	"github.com/sirupsen/logrus"
+)
+
+//sqliteSchema is scripts/sql/schema.sql for sqlite. Times are stored as text in sqliteTimeFormat so they compare in order
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS ARTICLES (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    TITLE TEXT NOT NULL,
+    ARTICLE_DATE TEXT NOT NULL,
+    ARTICLE_DAY TEXT NOT NULL DEFAULT '',
+    BODY TEXT NOT NULL,
+    EXCERPT TEXT NOT NULL DEFAULT '',
+    WORD_COUNT INTEGER NOT NULL DEFAULT 0,
+    READING_MINUTES INTEGER NOT NULL DEFAULT 0,
+    CREATEDDATE TEXT NOT NULL,
+    STATUS TEXT NOT NULL DEFAULT 'draft',
+    PUBLISH_AT TEXT NULL,
+    PUBLISHED_AT TEXT NULL,
+    DELETED_AT TEXT NULL,
+    CONTENT_HASH TEXT NULL,
+    SIMHASH INTEGER NULL
+);
+
+CREATE INDEX IF NOT EXISTS ARTICLES_ARTICLE_DAY_IDX ON ARTICLES (ARTICLE_DAY);
+CREATE INDEX IF NOT EXISTS ARTICLES_CONTENT_HASH_IDX ON ARTICLES (CONTENT_HASH, ARTICLE_DAY);
+
+CREATE TABLE IF NOT EXISTS TAGS (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    SLUG TEXT NOT NULL UNIQUE,
+    NAME TEXT NOT NULL,
+    PARENT_ID INTEGER NULL REFERENCES TAGS (ID) ON DELETE SET NULL,
+    CREATEDDATE TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS TAGS_PARENT_ID_IDX ON TAGS (PARENT_ID);
+
+CREATE TABLE IF NOT EXISTS TAG_ALIASES (
+    SLUG TEXT PRIMARY KEY,
+    TAG_ID INTEGER NOT NULL REFERENCES TAGS (ID) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS TAG_ALIASES_TAG_ID_IDX ON TAG_ALIASES (TAG_ID);
+
+CREATE TABLE IF NOT EXISTS ARTICLE_TAGS (
+    ARTICLE_ID INTEGER NOT NULL REFERENCES ARTICLES (ID) ON DELETE CASCADE,
+    TAG_ID INTEGER NOT NULL REFERENCES TAGS (ID),
+    SORT_ORDER INTEGER NOT NULL,
+    PRIMARY KEY (ARTICLE_ID, TAG_ID)
+);
+CREATE INDEX IF NOT EXISTS ARTICLE_TAGS_TAG_ID_IDX ON ARTICLE_TAGS (TAG_ID);
+
+CREATE TABLE IF NOT EXISTS IDEMPOTENCY_KEYS (
+    IDEMPOTENCY_KEY TEXT PRIMARY KEY,
+    REQUEST_HASH TEXT NOT NULL,
+    STATUS_CODE INTEGER NULL,
+    CONTENT_TYPE TEXT NOT NULL DEFAULT '',
+    BODY BLOB NULL,
+    EXPIRES_AT TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS IDEMPOTENCY_KEYS_EXPIRES_AT_IDX ON IDEMPOTENCY_KEYS (EXPIRES_AT);
+`
+
+//sqliteTimeFormat is how times are stored. It is fixed width and always UTC so stored times compare as text in time order
+const sqliteTimeFormat = "2006-01-02 15:04:05.000000000"
+
+//sqliteArticleTagsColumn selects an article's tag names, in the order they were written, as a JSON array
+const sqliteArticleTagsColumn = "(SELECT json_group_array(NAME) FROM (SELECT t.NAME FROM ARTICLE_TAGS ats JOIN TAGS t ON t.ID = ats.TAG_ID WHERE ats.ARTICLE_ID = ARTICLES.ID ORDER BY ats.SORT_ORDER)) AS TAGS"
+
+//sqliteArticleColumns and sqliteArticleSummaryColumns are articleColumns and articleSummaryColumns for sqlite
+const sqliteArticleColumns = "ID, TITLE, ARTICLE_DATE, ARTICLE_DAY, BODY, " + sqliteArticleTagsColumn + ", EXCERPT, WORD_COUNT, READING_MINUTES, STATUS, PUBLISH_AT, PUBLISHED_AT, DELETED_AT"
+const sqliteArticleSummaryColumns = "ID, TITLE, ARTICLE_DATE, ARTICLE_DAY, '' AS BODY, " + sqliteArticleTagsColumn + ", EXCERPT, WORD_COUNT, READING_MINUTES, STATUS, PUBLISH_AT, PUBLISHED_AT, DELETED_AT"
+
+//sqliteTagCountsQuery is tagCountsQuery for sqlite, with the aliases as a JSON array. ?1 limits the counts to published articles
+const sqliteTagCountsQuery = `SELECT t.ID, t.SLUG, t.NAME, t.CREATEDDATE, p.SLUG AS PARENT,
+		(SELECT json_group_array(SLUG) FROM (SELECT al.SLUG FROM TAG_ALIASES al WHERE al.TAG_ID = t.ID ORDER BY al.SLUG)) AS ALIASES,
+		COUNT(a.ID) AS ARTICLE_COUNT, MAX(a.CREATEDDATE) AS LAST_USED_AT
+	FROM TAGS t
+	LEFT JOIN TAGS p ON p.ID = t.PARENT_ID
+	LEFT JOIN ARTICLE_TAGS ats ON ats.TAG_ID = t.ID
+	LEFT JOIN ARTICLES a ON a.ID = ats.ARTICLE_ID AND a.DELETED_AT IS NULL AND (?1 = 0 OR a.STATUS = 'published')
+	GROUP BY t.ID
+	HAVING ?1 = 0 OR COUNT(a.ID) > 0`
+
+//SQLiteDBClient stores articles and tags in an embedded sqlite database, behaving as ArticleDBClient does against postgres.
+//It is meant for local development and tests, not for running more than one instance of the API against
+type SQLiteDBClient struct {
+	DB     *sql.DB
+	Logger *logrus.Entry
+
+	//tx is set on the client WithTx passes to its func, every query then runs in the transaction
+	tx *sql.Tx
+}
+
+//NewSQLiteDBClient opens the sqlite database at the path, or an in-memory one for ":memory:", and creates its tables
+func NewSQLiteDBClient(path string, logger *logrus.Entry) *SQLiteDBClient {
+	connStr := fmt.Sprintf("file:%s?_foreign_keys=1&_txlock=immediate&_busy_timeout=5000", path)
+	log.Printf("NewSQLiteDBClient %s\n", connStr)
+	db, err := sql.Open("sqlite3", connStr)
+	if err != nil {
+		log.Fatalf("NewSQLiteDBClient :: Error opening up connStr %s : %v", connStr, err)
+	}
+	//sqlite only has one writer at a time, and each connection to ":memory:" would be its own database
+	db.SetMaxOpenConns(1)
+
+	err = sqliteAddArticleDay(db)
+	if err != nil {
+		log.Fatalf("NewSQLiteDBClient :: Error adding article days to %s : %v", path, err)
+	}
+	err = sqliteAddArticleFingerprints(db)
+	if err != nil {
+		log.Fatalf("NewSQLiteDBClient :: Error adding article fingerprints to %s : %v", path, err)
+	}
+	_, err = db.Exec(sqliteSchema)
+	if err != nil {
+		log.Fatalf("NewSQLiteDBClient :: Error creating tables in %s : %v", path, err)
+	}
+	log.Println("NewSQLiteDBClient connected")
+
+	return &SQLiteDBClient{
+		DB:     db,
+		Logger: logger,
+	}
+}
+
+//sqliteAddArticleDay adds the ARTICLE_DAY column to databases made before it existed, as migration 007 does for
+//postgres. Dates were stored at midnight UTC so each article's day is the start of its date
+func sqliteAddArticleDay(db *sql.DB) error {
+	var missing bool
+	err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'ARTICLES')
+		AND NOT EXISTS (SELECT 1 FROM pragma_table_info('ARTICLES') WHERE name = 'ARTICLE_DAY')`).Scan(&missing)
+	if err != nil || !missing {
+		return err
+	}
+	_, err = db.Exec(`ALTER TABLE ARTICLES ADD COLUMN ARTICLE_DAY TEXT NOT NULL DEFAULT '';
+		UPDATE ARTICLES SET ARTICLE_DAY = substr(ARTICLE_DATE, 1, 10);`)
+	return err
+}
+
+//sqliteAddArticleFingerprints adds the CONTENT_HASH and SIMHASH columns to databases made before they existed, as
+//migration 009 does for postgres
+func sqliteAddArticleFingerprints(db *sql.DB) error {
+	var missing bool
+	err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'ARTICLES')
+		AND NOT EXISTS (SELECT 1 FROM pragma_table_info('ARTICLES') WHERE name = 'CONTENT_HASH')`).Scan(&missing)
+	if err != nil || !missing {
+		return err
+	}
+	_, err = db.Exec(`ALTER TABLE ARTICLES ADD COLUMN CONTENT_HASH TEXT NULL;
+		ALTER TABLE ARTICLES ADD COLUMN SIMHASH INTEGER NULL;`)
+	return err
+}
+
+//WithTx runs fn in a transaction, passing it a client whose every query runs in the transaction. It commits when fn
+//returns nil and rolls back otherwise. A transaction that finds the database busy is run again from the start, so fn may
+//be called more than once. Inside another WithTx, fn runs in a savepoint of the surrounding transaction instead
+func (d *SQLiteDBClient) WithTx(ctx context.Context, fn func(tx DBClient) error) error {
+	if d.tx != nil {
+		return withSavepoint(d.tx, d, fn)
+	}
+
+	return retryTx(ctx, d.Logger, isSQLiteBusy, func() error {
+		//sqlite transactions are always serializable
+		tx, err := d.DB.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		err = fn(&SQLiteDBClient{DB: d.DB, Logger: d.Logger, tx: tx})
+		if err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+}
+
+//conn returns what queries run against, the WithTx transaction when there is one. The pool only has the one connection,
+//so inside WithTx a query not run in the transaction would wait for it forever
+func (d *SQLiteDBClient) conn() sqlExecutor {
+	if d.tx != nil {
+		return d.tx
+	}
+	return d.DB
+}
+
+//begin starts the transaction of a method writing several statements
+func (d *SQLiteDBClient) begin() (*writeTx, error) {
+	return beginWriteTx(d.DB, d.tx)
+}
+
+//Primary returns the client itself, it has no replicas to read from
+func (d *SQLiteDBClient) Primary() DBClient {
+	return d
+}
+
+//CreateArticleRow inserts new article row along with its tags
+func (d *SQLiteDBClient) CreateArticleRow(article *models.Article) (int, error) {
+	ids, err := d.CreateArticleRows([]*models.Article{article})
+	if err != nil {
+		return 0, err
+	}
+	return ids[0], nil
+}
+
+//CreateArticleRows inserts the articles inside one transaction, so either every article is stored or none are.
+//The new ids are returned in the same order as the articles
+func (d *SQLiteDBClient) CreateArticleRows(articles []*models.Article) ([]int, error) {
+	d.Logger.Infof("CreateArticleRows :: inserting %d articles", len(articles))
+
+	tx, err := d.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT INTO ARTICLES(TITLE, ARTICLE_DATE, ARTICLE_DAY, BODY, EXCERPT, WORD_COUNT, READING_MINUTES, STATUS, PUBLISHED_AT, CREATEDDATE, CONTENT_HASH, SIMHASH)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11, ?12)`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	now := sqliteTime(time.Now())
+	ids := make([]int, len(articles))
+	for i, article := range articles {
+		res, err := stmt.Exec(article.Title, sqliteTime(article.Date), article.Day, article.Body, article.Excerpt, article.WordCount,
+			article.ReadingMinutes, article.Status, sqliteNullTime(article.PublishedAt), now, nullString(article.ContentHash), articleSimhash(article))
+		if err != nil {
+			d.Logger.Errorf("CreateArticleRows :: error inserting article %d : %v", i, err)
+			return nil, err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = int(id)
+	}
+
+	err = sqliteInsertArticleTags(tx, ids, articles, now)
+	if err != nil {
+		d.Logger.Errorf("CreateArticleRows :: error inserting tags : %v", err)
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+//sqliteInsertArticleTags links each article to its tags as insertArticleTags does
+func sqliteInsertArticleTags(tx sqlExecutor, ids []int, articles []*models.Article, now string) error {
+	aliases := map[string]string{}
+	rows, err := tx.Query(`SELECT al.SLUG, t.SLUG FROM TAG_ALIASES al JOIN TAGS t ON t.ID = al.TAG_ID
+		WHERE al.SLUG IN (SELECT value FROM json_each(?1))`, sqliteJSONArray(writtenTagSlugs(articles)))
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var alias, slug string
+		err = rows.Scan(&alias, &slug)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		aliases[alias] = slug
+	}
+	rows.Close()
+	err = rows.Err()
+	if err != nil {
+		return err
+	}
+
+	newTags, links := planArticleTags(ids, articles, aliases)
+	for _, tag := range newTags {
+		_, err = tx.Exec(`INSERT INTO TAGS(SLUG, NAME, CREATEDDATE) VALUES (?1, ?2, ?3) ON CONFLICT (SLUG) DO NOTHING`, tag.Slug, tag.Name, now)
+		if err != nil {
+			return err
+		}
+	}
+	for _, link := range links {
+		_, err = tx.Exec(`INSERT INTO ARTICLE_TAGS(ARTICLE_ID, TAG_ID, SORT_ORDER) SELECT ?1, ID, ?3 FROM TAGS WHERE SLUG = ?2`,
+			link.ArticleID, link.Slug, link.SortOrder)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//GetArticleRowByID queries db for article by its ID, whatever its publication status
+func (d *SQLiteDBClient) GetArticleRowByID(findID int) (*models.Article, error) {
+	query := `SELECT ` + sqliteArticleColumns + ` FROM ARTICLES WHERE ID = ?1 AND DELETED_AT IS NULL`
+	d.Logger.Infof("GetArticleRowByID :: %s ID: %d", query, findID)
+
+	article, err := scanSQLiteArticle(d.conn().QueryRow(query, findID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return article, nil
+}
+
+//GetArticleRowByTagAndDate queries db for live articles carrying the tag published on the date, without their bodies.
+//publishedOnly limits the result to published articles and includeDescendants also returns articles carrying tags below the tag
+func (d *SQLiteDBClient) GetArticleRowByTagAndDate(tag, date string, publishedOnly, includeDescendants bool) (*[]models.Article, error) {
+	articleDay, err := time.Parse(models.DayFormat, date)
+	if err != nil {
+		return nil, err
+	}
+	tagCondition := hasTagCondition("?1")
+	if includeDescendants {
+		tagCondition = hasTagOrDescendantCondition("?1")
+	}
+	query := `SELECT ` + sqliteArticleSummaryColumns + ` FROM ARTICLES WHERE ` + tagCondition + ` AND ARTICLE_DAY = ?2 AND DELETED_AT IS NULL
+		AND (?3 = 0 OR STATUS = 'published') ORDER BY CREATEDDATE desc, ID desc`
+	d.Logger.Infof("GetArticleRowByTagAndDate :: %s tag %s date %s publishedOnly %t includeDescendants %t", query, tag, date, publishedOnly, includeDescendants)
+
+	return d.queryArticles(query, models.TagSlug(tag), articleDay.Format(models.DayFormat), publishedOnly)
+}
+
+//GetRecentArticleRows returns the most recently published articles, newest first, without their bodies. An empty tag returns articles with any tag
+func (d *SQLiteDBClient) GetRecentArticleRows(tag string, limit int) (*[]models.Article, error) {
+	query := `SELECT ` + sqliteArticleSummaryColumns + ` FROM ARTICLES WHERE (?1 = '' OR ` + hasTagCondition("?1") + `) AND STATUS = 'published' AND DELETED_AT IS NULL
+		ORDER BY PUBLISHED_AT desc NULLS LAST, CREATEDDATE desc, ID desc LIMIT ?2`
+	d.Logger.Infof("GetRecentArticleRows :: %s tag %s limit %d", query, tag, limit)
+
+	return d.queryArticles(query, models.TagSlug(tag), limit)
+}
+
+//ExportArticleRows calls fn for every live article matching the filter, oldest first. Articles are read a page at a
+//time by id so memory stays flat however many articles there are. An error from fn stops the export and is returned
+func (d *SQLiteDBClient) ExportArticleRows(filter models.ArticleFilter, fn func(article *models.Article) error) error {
+	conditions := []string{"ID > ?1", "DELETED_AT IS NULL"}
+	args := []interface{}{0}
+	if filter.Tag != "" {
+		args = append(args, models.TagSlug(filter.Tag))
+		conditions = append(conditions, hasTagCondition(fmt.Sprintf("?%d", len(args))))
+	}
+	if filter.From != nil {
+		args = append(args, filter.From.Format(models.DayFormat))
+		conditions = append(conditions, fmt.Sprintf("ARTICLE_DAY >= ?%d", len(args)))
+	}
+	if filter.To != nil {
+		args = append(args, filter.To.Format(models.DayFormat))
+		conditions = append(conditions, fmt.Sprintf("ARTICLE_DAY <= ?%d", len(args)))
+	}
+	if filter.PublishedOnly {
+		conditions = append(conditions, "STATUS = 'published'")
+	}
+	query := `SELECT ` + sqliteArticleColumns + ` FROM ARTICLES WHERE ` + strings.Join(conditions, " AND ") +
+		fmt.Sprintf(` ORDER BY ID LIMIT %d`, exportFetchSize)
+	d.Logger.Infof("ExportArticleRows :: %s filter %+v", query, filter)
+
+	for {
+		page, err := d.queryArticles(query, args...)
+		if err != nil {
+			return err
+		}
+		for i := range *page {
+			err = fn(&(*page)[i])
+			if err != nil {
+				return err
+			}
+		}
+		if len(*page) < exportFetchSize {
+			return nil
+		}
+		//the next page starts after the last article of this one
+		args[0], err = strconv.Atoi((*page)[len(*page)-1].ID)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+//UpdateArticleStatus moves an article from one status to another. The update only applies while the article is still
+//in fromStatus. Returns false if the article was not in fromStatus
+func (d *SQLiteDBClient) UpdateArticleStatus(id int, fromStatus, toStatus string, publishAt *time.Time) (bool, error) {
+	query := `UPDATE ARTICLES SET STATUS = ?3, PUBLISH_AT = ?4,
+		PUBLISHED_AT = CASE WHEN ?3 = 'published' THEN ?5 ELSE PUBLISHED_AT END
+		WHERE ID = ?1 AND STATUS = ?2 AND DELETED_AT IS NULL`
+	d.Logger.Infof("UpdateArticleStatus :: %s ID: %d %s -> %s", query, id, fromStatus, toStatus)
+
+	return d.execAffected("UpdateArticleStatus", query, id, fromStatus, toStatus, sqliteNullTime(publishAt), sqliteTime(time.Now()))
+}
+
+//PublishScheduledArticleRows publishes every scheduled article whose publish time has passed
+func (d *SQLiteDBClient) PublishScheduledArticleRows(now time.Time) (int64, error) {
+	query := `UPDATE ARTICLES SET STATUS = 'published', PUBLISHED_AT = PUBLISH_AT
+		WHERE STATUS = 'scheduled' AND PUBLISH_AT <= ?1 AND DELETED_AT IS NULL`
+	published, err := d.execCount("PublishScheduledArticleRows", query, sqliteTime(now))
+	if err != nil {
+		return 0, err
+	}
+	d.Logger.Infof("PublishScheduledArticleRows :: published %d scheduled rows", published)
+	return published, nil
+}
+
+//DeleteArticleByID soft deletes an article by id. Returns false if there was no live article with that id
+func (d *SQLiteDBClient) DeleteArticleByID(id int) (bool, error) {
+	query := `UPDATE ARTICLES SET DELETED_AT = ?2 WHERE ID = ?1 AND DELETED_AT IS NULL`
+	return d.execAffected("DeleteArticleByID", query, id, sqliteTime(time.Now()))
+}
+
+//GetDeletedArticleRows returns every soft deleted article that has not been purged yet, most recently deleted first, without their bodies
+func (d *SQLiteDBClient) GetDeletedArticleRows() (*[]models.Article, error) {
+	query := `SELECT ` + sqliteArticleSummaryColumns + ` FROM ARTICLES WHERE DELETED_AT IS NOT NULL ORDER BY DELETED_AT desc, ID desc`
+	d.Logger.Infof("GetDeletedArticleRows :: %s", query)
+
+	return d.queryArticles(query)
+}
+
+//RestoreArticleByID clears the deleted flag on an article. Returns false if there was no deleted article with that id
+func (d *SQLiteDBClient) RestoreArticleByID(id int) (bool, error) {
+	query := `UPDATE ARTICLES SET DELETED_AT = NULL WHERE ID = ?1 AND DELETED_AT IS NOT NULL`
+	return d.execAffected("RestoreArticleByID", query, id)
+}
+
+//PurgeDeletedArticleRows permanently removes articles that were soft deleted before the given time
+func (d *SQLiteDBClient) PurgeDeletedArticleRows(deletedBefore time.Time) (int64, error) {
+	query := `DELETE FROM ARTICLES WHERE DELETED_AT IS NOT NULL AND DELETED_AT < ?1`
+	purged, err := d.execCount("PurgeDeletedArticleRows", query, sqliteTime(deletedBefore))
+	if err != nil {
+		return 0, err
+	}
+	d.Logger.Infof("PurgeDeletedArticleRows :: purged %d rows deleted before %v", purged, deletedBefore)
+	return purged, nil
+}
+
+//GetTagRows returns a page of tags with their article counts in the given sort order, along with the total number of tags.
+//publishedOnly only counts published articles and leaves out tags that have none
+func (d *SQLiteDBClient) GetTagRows(sortBy string, limit, offset int, publishedOnly bool) (*[]models.Tag, int, error) {
+	orderBy, ok := tagSortOrders[sortBy]
+	if !ok {
+		return nil, 0, fmt.Errorf("unknown tag sort order %s", sortBy)
+	}
+
+	var total int
+	err := d.conn().QueryRow(`SELECT COUNT(*) FROM (`+sqliteTagCountsQuery+`) counts`, publishedOnly).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT * FROM (` + sqliteTagCountsQuery + `) counts ORDER BY ` + orderBy + ` LIMIT ?2 OFFSET ?3`
+	d.Logger.Infof("GetTagRows :: %s limit %d offset %d publishedOnly %t", query, limit, offset, publishedOnly)
+
+	rows, err := d.conn().Query(query, publishedOnly, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	tags := []models.Tag{}
+	for rows.Next() {
+		tag, err := scanSQLiteTag(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		tags = append(tags, *tag)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, 0, err
+	}
+	return &tags, total, nil
+}
+
+//GetTagRowBySlug returns the tag with the slug or alias and its article counts, or nil if there is no such tag.
+//publishedOnly only counts published articles and treats a tag without any as not found
+func (d *SQLiteDBClient) GetTagRowBySlug(slug string, publishedOnly bool) (*models.Tag, error) {
+	query := `SELECT * FROM (` + sqliteTagCountsQuery + `) counts WHERE ID IN (` + tagIDQuery("?2") + `)`
+	d.Logger.Infof("GetTagRowBySlug :: %s slug %s publishedOnly %t", query, slug, publishedOnly)
+
+	tag, err := scanSQLiteTag(d.conn().QueryRow(query, publishedOnly, slug))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+//GetTagSuggestionRows returns the tags whose slug or one of whose aliases starts with the prefix, by most used first.
+//The prefix is matched as a range of slugs so the slug indexes are used. publishedOnly only counts published articles
+//and leaves out tags that have none
+func (d *SQLiteDBClient) GetTagSuggestionRows(prefix string, limit int, publishedOnly bool) (*[]models.Tag, error) {
+	query := `SELECT t.SLUG, t.NAME, COUNT(a.ID) AS ARTICLE_COUNT
+		FROM TAGS t
+		LEFT JOIN ARTICLE_TAGS ats ON ats.TAG_ID = t.ID
+		LEFT JOIN ARTICLES a ON a.ID = ats.ARTICLE_ID AND a.DELETED_AT IS NULL AND (?2 = 0 OR a.STATUS = 'published')
+		WHERE (t.SLUG >= ?1 AND t.SLUG < ?1 || char(1114111))
+			OR t.ID IN (SELECT al.TAG_ID FROM TAG_ALIASES al WHERE al.SLUG >= ?1 AND al.SLUG < ?1 || char(1114111))
+		GROUP BY t.ID
+		HAVING ?2 = 0 OR COUNT(a.ID) > 0
+		ORDER BY ARTICLE_COUNT desc, t.SLUG
+		LIMIT ?3`
+	d.Logger.Infof("GetTagSuggestionRows :: %s prefix %s limit %d publishedOnly %t", query, prefix, limit, publishedOnly)
+
+	rows, err := d.conn().Query(query, prefix, publishedOnly, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []models.Tag{}
+	for rows.Next() {
+		tag := models.Tag{}
+		err = rows.Scan(&tag.Slug, &tag.Name, &tag.ArticleCount)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return &tags, nil
+}
+
+//RenameTagRow renames the tag with the slug, changing its slug to match the new name. Returns false if there is no tag
+//with the slug, and ErrTagExists if the new name belongs to another tag or is another tag's alias
+func (d *SQLiteDBClient) RenameTagRow(slug, name string) (bool, error) {
+	tag := models.NewTag(name)
+	d.Logger.Infof("RenameTagRow :: renaming tag %s to %s", slug, tag.Slug)
+
+	tx, err := d.begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	tagIDs, err := sqliteTagIDs(tx, slug)
+	if err != nil {
+		return false, err
+	}
+	id, ok := tagIDs[slug]
+	if !ok {
+		return false, nil
+	}
+
+	aliasOf, err := tagAliasOwner(tx, tag.Slug)
+	if err != nil {
+		return false, err
+	}
+	if aliasOf != 0 && aliasOf != id {
+		return false, ErrTagExists
+	}
+	//renaming a tag to one of its own aliases means the alias is no longer needed
+	_, err = tx.Exec(`DELETE FROM TAG_ALIASES WHERE SLUG = ?1 AND TAG_ID = ?2`, tag.Slug, id)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.Exec(`UPDATE TAGS SET SLUG = ?2, NAME = ?3 WHERE ID = ?1`, id, tag.Slug, tag.Name)
+	if err != nil {
+		if isSQLiteUniqueViolation(err) {
+			return false, ErrTagExists
+		}
+		d.Logger.Errorf("RenameTagRow :: error renaming tag %s : %v", slug, err)
+		return false, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+//MergeTagRows moves every article carrying the fromSlug tag onto the intoSlug tag and removes the fromSlug tag, all in one
+//transaction. Articles already carrying both keep the intoSlug tag where it was. The fromSlug tag's slug and aliases become
+//aliases of the intoSlug tag and its children move under it. Returns false if either tag doesn't exist
+func (d *SQLiteDBClient) MergeTagRows(fromSlug, intoSlug string) (bool, error) {
+	d.Logger.Infof("MergeTagRows :: merging tag %s into %s", fromSlug, intoSlug)
+
+	tx, err := d.begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	tagIDs, err := sqliteTagIDs(tx, fromSlug, intoSlug)
+	if err != nil {
+		return false, err
+	}
+	fromID, fromOK := tagIDs[fromSlug]
+	intoID, intoOK := tagIDs[intoSlug]
+	if !fromOK || !intoOK {
+		return false, nil
+	}
+
+	statements := []struct {
+		query string
+		args  []interface{}
+	}{
+		{`INSERT INTO ARTICLE_TAGS(ARTICLE_ID, TAG_ID, SORT_ORDER)
+			SELECT ARTICLE_ID, ?2, SORT_ORDER FROM ARTICLE_TAGS WHERE TAG_ID = ?1
+			ON CONFLICT (ARTICLE_ID, TAG_ID) DO NOTHING`, []interface{}{fromID, intoID}},
+		{`DELETE FROM ARTICLE_TAGS WHERE TAG_ID = ?1`, []interface{}{fromID}},
+		{`UPDATE TAG_ALIASES SET TAG_ID = ?2 WHERE TAG_ID = ?1`, []interface{}{fromID, intoID}},
+		{`INSERT INTO TAG_ALIASES(SLUG, TAG_ID) VALUES (?1, ?2)`, []interface{}{fromSlug, intoID}},
+		//when the intoSlug tag is below the fromSlug tag it takes the fromSlug tag's place first, so moving the children can't make a loop
+		{`UPDATE TAGS SET PARENT_ID = (SELECT PARENT_ID FROM TAGS WHERE ID = ?1)
+			WHERE ID = ?2 AND ?2 IN (` + tagTreeQuery("SELECT ?1") + `)`, []interface{}{fromID, intoID}},
+		{`UPDATE TAGS SET PARENT_ID = ?2 WHERE PARENT_ID = ?1 AND ID <> ?2`, []interface{}{fromID, intoID}},
+		{`DELETE FROM TAGS WHERE ID = ?1`, []interface{}{fromID}},
+	}
+	for _, statement := range statements {
+		_, err = tx.Exec(statement.query, statement.args...)
+		if err != nil {
+			d.Logger.Errorf("MergeTagRows :: error merging tag %s into %s : %v", fromSlug, intoSlug, err)
+			return false, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+//AddTagAliasRow makes alias another slug for the tag with the slug, so writing or asking for the alias means the tag.
+//Returns false if there is no tag with the slug, and ErrTagExists if the alias is a tag or another tag's alias
+func (d *SQLiteDBClient) AddTagAliasRow(slug, alias string) (bool, error) {
+	d.Logger.Infof("AddTagAliasRow :: adding alias %s to tag %s", alias, slug)
+
+	tx, err := d.begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	tagIDs, err := sqliteTagIDs(tx, slug, alias)
+	if err != nil {
+		return false, err
+	}
+	id, ok := tagIDs[slug]
+	if !ok {
+		return false, nil
+	}
+	if _, ok := tagIDs[alias]; ok {
+		return false, ErrTagExists
+	}
+
+	aliasOf, err := tagAliasOwner(tx, alias)
+	if err != nil {
+		return false, err
+	}
+	if aliasOf == id {
+		return true, nil
+	}
+	if aliasOf != 0 {
+		return false, ErrTagExists
+	}
+
+	_, err = tx.Exec(`INSERT INTO TAG_ALIASES(SLUG, TAG_ID) VALUES (?1, ?2)`, alias, id)
+	if err != nil {
+		d.Logger.Errorf("AddTagAliasRow :: error adding alias %s to tag %s : %v", alias, slug, err)
+		return false, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+//RemoveTagAliasRow removes an alias from the tag with the slug. Returns false if the tag has no such alias
+func (d *SQLiteDBClient) RemoveTagAliasRow(slug, alias string) (bool, error) {
+	query := `DELETE FROM TAG_ALIASES WHERE SLUG = ?2 AND TAG_ID = (SELECT ID FROM TAGS WHERE SLUG = ?1)`
+	return d.execAffected("RemoveTagAliasRow", query, slug, alias)
+}
+
+//SetTagParentRow puts the tag with the slug below the tag with parentSlug, or at the top when parentSlug is empty.
+//Returns false if either tag doesn't exist, and ErrTagCycle if the parent is the tag or below it
+func (d *SQLiteDBClient) SetTagParentRow(slug, parentSlug string) (bool, error) {
+	d.Logger.Infof("SetTagParentRow :: setting parent of tag %s to %s", slug, parentSlug)
+
+	tx, err := d.begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	tagIDs, err := sqliteTagIDs(tx, slug, parentSlug)
+	if err != nil {
+		return false, err
+	}
+	id, ok := tagIDs[slug]
+	if !ok {
+		return false, nil
+	}
+
+	var parentID *int
+	if parentSlug != "" {
+		found, ok := tagIDs[parentSlug]
+		if !ok {
+			return false, nil
+		}
+
+		var below bool
+		err = tx.QueryRow(`SELECT ?2 IN (`+tagTreeQuery("SELECT ?1")+`)`, id, found).Scan(&below)
+		if err != nil {
+			return false, err
+		}
+		if below {
+			return false, ErrTagCycle
+		}
+		parentID = &found
+	}
+
+	_, err = tx.Exec(`UPDATE TAGS SET PARENT_ID = ?2 WHERE ID = ?1`, id, parentID)
+	if err != nil {
+		d.Logger.Errorf("SetTagParentRow :: error setting parent of tag %s : %v", slug, err)
+		return false, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+//GetTrendingTagRows compares each tag's use on articles created in the last days with the days before that and returns
+//the tags whose use went up, by most gained first. publishedOnly only counts published articles
+func (d *SQLiteDBClient) GetTrendingTagRows(days, limit int, publishedOnly bool) (*[]models.TrendingTag, error) {
+	query := `SELECT t.SLUG, t.NAME,
+			COUNT(*) FILTER (WHERE a.CREATEDDATE >= ?1) AS CURRENT_COUNT,
+			COUNT(*) FILTER (WHERE a.CREATEDDATE < ?1) AS PREVIOUS_COUNT
+		FROM TAGS t
+		JOIN ARTICLE_TAGS ats ON ats.TAG_ID = t.ID
+		JOIN ARTICLES a ON a.ID = ats.ARTICLE_ID
+		WHERE a.DELETED_AT IS NULL AND (?2 = 0 OR a.STATUS = 'published') AND a.CREATEDDATE >= ?3
+		GROUP BY t.ID
+		HAVING CURRENT_COUNT > PREVIOUS_COUNT
+		ORDER BY CURRENT_COUNT - PREVIOUS_COUNT desc, CURRENT_COUNT desc, t.SLUG
+		LIMIT ?4`
+	d.Logger.Infof("GetTrendingTagRows :: %s days %d limit %d publishedOnly %t", query, days, limit, publishedOnly)
+
+	now := time.Now()
+	rows, err := d.conn().Query(query, sqliteTime(now.AddDate(0, 0, -days)), publishedOnly, sqliteTime(now.AddDate(0, 0, -days*2)), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []models.TrendingTag{}
+	for rows.Next() {
+		tag := models.TrendingTag{}
+		err = rows.Scan(&tag.Slug, &tag.Name, &tag.CurrentCount, &tag.PreviousCount)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return &tags, nil
+}
+
+//GetRelatedTagRows returns the tags appearing on the same articles as the tag with the slug or alias, counting articles created
+//in the last days. They are ordered by how many articles carry both tags then by their Jaccard index.
+//publishedOnly only counts published articles
+func (d *SQLiteDBClient) GetRelatedTagRows(slug string, days, limit int, publishedOnly bool) (*[]models.RelatedTag, error) {
+	query := `WITH windowed AS (
+			SELECT ats.ARTICLE_ID, ats.TAG_ID FROM ARTICLE_TAGS ats JOIN ARTICLES a ON a.ID = ats.ARTICLE_ID
+			WHERE a.DELETED_AT IS NULL AND (?2 = 0 OR a.STATUS = 'published') AND a.CREATEDDATE >= ?3
+		), tag_counts AS (
+			SELECT TAG_ID, COUNT(*) AS ARTICLE_COUNT FROM windowed GROUP BY TAG_ID
+		), target AS (
+			SELECT w.ARTICLE_ID, w.TAG_ID FROM windowed w WHERE w.TAG_ID IN (` + tagIDQuery("?1") + `)
+		)
+		SELECT t.SLUG, t.NAME, COUNT(*) AS CO_COUNT,
+			COUNT(*) * 1.0 / (tc.ARTICLE_COUNT + (SELECT COUNT(*) FROM target) - COUNT(*)) AS JACCARD
+		FROM target tg
+		JOIN windowed o ON o.ARTICLE_ID = tg.ARTICLE_ID AND o.TAG_ID <> tg.TAG_ID
+		JOIN TAGS t ON t.ID = o.TAG_ID
+		JOIN tag_counts tc ON tc.TAG_ID = o.TAG_ID
+		GROUP BY t.ID, tc.ARTICLE_COUNT
+		ORDER BY CO_COUNT desc, JACCARD desc, t.SLUG
+		LIMIT ?4`
+	d.Logger.Infof("GetRelatedTagRows :: %s slug %s days %d limit %d publishedOnly %t", query, slug, days, limit, publishedOnly)
+
+	rows, err := d.conn().Query(query, slug, publishedOnly, sqliteTime(time.Now().AddDate(0, 0, -days)), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []models.RelatedTag{}
+	for rows.Next() {
+		tag := models.RelatedTag{}
+		err = rows.Scan(&tag.Slug, &tag.Name, &tag.Count, &tag.Jaccard)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return &tags, nil
+}
+
+//GetArticleIDByContentHash returns the id of a live article published on the day whose title and body have the content
+//hash, or 0 when there is none
+func (d *SQLiteDBClient) GetArticleIDByContentHash(contentHash, day string) (int, error) {
+	var id int
+	err := d.conn().QueryRow(`SELECT ID FROM ARTICLES WHERE CONTENT_HASH = ?1 AND ARTICLE_DAY = ?2 AND DELETED_AT IS NULL ORDER BY ID LIMIT 1`,
+		contentHash, day).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		d.Logger.Errorf("GetArticleIDByContentHash :: error finding hash %s on %s : %v", contentHash, day, err)
+		return 0, err
+	}
+	return id, nil
+}
+
+//GetArticleFingerprintRows returns the simhash of every live article published between the days, both included.
+//Articles created before simhashes were kept are left out
+func (d *SQLiteDBClient) GetArticleFingerprintRows(fromDay, toDay string) (*[]models.ArticleFingerprint, error) {
+	rows, err := d.conn().Query(`SELECT ID, TITLE, ARTICLE_DAY, SIMHASH FROM ARTICLES
+		WHERE ARTICLE_DAY BETWEEN ?1 AND ?2 AND SIMHASH IS NOT NULL AND DELETED_AT IS NULL ORDER BY ID`, fromDay, toDay)
+	if err != nil {
+		d.Logger.Errorf("GetArticleFingerprintRows :: error reading fingerprints from %s to %s : %v", fromDay, toDay, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	fingerprints := []models.ArticleFingerprint{}
+	for rows.Next() {
+		fingerprint := models.ArticleFingerprint{}
+		err = rows.Scan(&fingerprint.ID, &fingerprint.Title, &fingerprint.Day, &fingerprint.Simhash)
+		if err != nil {
+			return nil, err
+		}
+		fingerprints = append(fingerprints, fingerprint)
+	}
+	return &fingerprints, rows.Err()
+}
+
+//ReserveIdempotencyKey reserves the key for the request with the hash until expiresAt, returning nil when it did. When an
+//unexpired request already holds the key what is stored for it is returned instead. Expired keys are removed as of now
+func (d *SQLiteDBClient) ReserveIdempotencyKey(key, requestHash string, now, expiresAt time.Time) (*models.IdempotentResponse, error) {
+	_, err := d.execCount("ReserveIdempotencyKey", `DELETE FROM IDEMPOTENCY_KEYS WHERE EXPIRES_AT <= ?1`, sqliteTime(now))
+	if err != nil {
+		return nil, err
+	}
+
+	//the key can be released between the insert and the select, in which case it is reserved again
+	for attempt := 0; attempt < 2; attempt++ {
+		reserved, err := d.execAffected("ReserveIdempotencyKey", `INSERT INTO IDEMPOTENCY_KEYS(IDEMPOTENCY_KEY, REQUEST_HASH, EXPIRES_AT)
+			VALUES (?1, ?2, ?3) ON CONFLICT (IDEMPOTENCY_KEY) DO NOTHING`, key, requestHash, sqliteTime(expiresAt))
+		if err != nil {
+			return nil, err
+		}
+		if reserved {
+			return nil, nil
+		}
+
+		response, err := scanIdempotentResponse(d.conn().QueryRow(`SELECT REQUEST_HASH, STATUS_CODE, CONTENT_TYPE, BODY
+			FROM IDEMPOTENCY_KEYS WHERE IDEMPOTENCY_KEY = ?1`, key))
+		if err != sql.ErrNoRows {
+			return response, err
+		}
+	}
+	return nil, fmt.Errorf("idempotency key %s could not be reserved", key)
+}
+
+//SaveIdempotentResponse stores the response to the request holding the key
+func (d *SQLiteDBClient) SaveIdempotentResponse(key string, response *models.IdempotentResponse) error {
+	_, err := d.execCount("SaveIdempotentResponse", `UPDATE IDEMPOTENCY_KEYS SET STATUS_CODE = ?2, CONTENT_TYPE = ?3, BODY = ?4 WHERE IDEMPOTENCY_KEY = ?1`,
+		key, response.StatusCode, response.ContentType, response.Body)
+	return err
+}
+
+//ReleaseIdempotencyKey removes the key, so the next request sent with it is handled as a new one
+func (d *SQLiteDBClient) ReleaseIdempotencyKey(key string) error {
+	_, err := d.execCount("ReleaseIdempotencyKey", `DELETE FROM IDEMPOTENCY_KEYS WHERE IDEMPOTENCY_KEY = ?1`, key)
+	return err
+}
+
+//queryArticles runs a query returning article rows and scans them all
+func (d *SQLiteDBClient) queryArticles(query string, args ...interface{}) (*[]models.Article, error) {
+	rows, err := d.conn().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	articles := []models.Article{}
+	for rows.Next() {
+		article, err := scanSQLiteArticle(rows)
+		if err != nil {
+			return nil, err
+		}
+		articles = append(articles, *article)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return &articles, nil
+}
+
+//execAffected runs an UPDATE or DELETE and reports whether it changed a row
+func (d *SQLiteDBClient) execAffected(funcName, query string, args ...interface{}) (bool, error) {
+	affected, err := d.execCount(funcName, query, args...)
+	return affected > 0, err
+}
+
+//execCount runs an UPDATE or DELETE and returns how many rows it changed
+func (d *SQLiteDBClient) execCount(funcName, query string, args ...interface{}) (int64, error) {
+	res, err := d.conn().Exec(query, args...)
+	if err != nil {
+		d.Logger.Errorf("%s :: error running %s : %v", funcName, query, err)
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+//sqliteTagIDs returns the ids of the tags with the slugs. Slugs without a tag are left out. Transactions are begun
+//immediate so the tags can't change before the transaction ends
+func sqliteTagIDs(tx sqlExecutor, slugs ...string) (map[string]int, error) {
+	rows, err := tx.Query(`SELECT ID, SLUG FROM TAGS WHERE SLUG IN (SELECT value FROM json_each(?1))`, sqliteJSONArray(slugs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tagIDs := map[string]int{}
+	for rows.Next() {
+		var id int
+		var slug string
+		err = rows.Scan(&id, &slug)
+		if err != nil {
+			return nil, err
+		}
+		tagIDs[slug] = id
+	}
+	return tagIDs, rows.Err()
+}
+
+//scanSQLiteArticle scans a single row selected with sqliteArticleColumns or sqliteArticleSummaryColumns
+func scanSQLiteArticle(row rowScanner) (*models.Article, error) {
+	article := &models.Article{}
+	var date, tags string
+	var publishAt, publishedAt, deletedAt sql.NullString
+	err := row.Scan(&article.ID, &article.Title, &date, &article.Day, &article.Body, &tags,
+		&article.Excerpt, &article.WordCount, &article.ReadingMinutes, &article.Status, &publishAt, &publishedAt, &deletedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	article.Date, err = time.Parse(sqliteTimeFormat, date)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal([]byte(tags), &article.Tags)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range []struct {
+		stored sql.NullString
+		into   **time.Time
+	}{{publishAt, &article.PublishAt}, {publishedAt, &article.PublishedAt}, {deletedAt, &article.DeletedAt}} {
+		*t.into, err = parseSQLiteNullTime(t.stored)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return article, nil
+}
+
+//scanSQLiteTag scans a single row selected by sqliteTagCountsQuery
+func scanSQLiteTag(row rowScanner) (*models.Tag, error) {
+	tag := &models.Tag{}
+	var createdAt, aliases string
+	var parent, lastUsedAt sql.NullString
+	err := row.Scan(&tag.ID, &tag.Slug, &tag.Name, &createdAt, &parent, &aliases, &tag.ArticleCount, &lastUsedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	tag.CreatedAt, err = time.Parse(sqliteTimeFormat, createdAt)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal([]byte(aliases), &tag.Aliases)
+	if err != nil {
+		return nil, err
+	}
+	tag.Parent = parent.String
+	tag.LastUsedAt, err = parseSQLiteNullTime(lastUsedAt)
+	if err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+//sqliteTime formats a time the way it is stored
+func sqliteTime(t time.Time) string {
+	return t.UTC().Format(sqliteTimeFormat)
+}
+
+//sqliteNullTime formats an optional time the way it is stored, nil staying NULL
+func sqliteNullTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return sqliteTime(*t)
+}
+
+func parseSQLiteNullTime(stored sql.NullString) (*time.Time, error) {
+	if !stored.Valid {
+		return nil, nil
+	}
+	t, err := time.Parse(sqliteTimeFormat, stored.String)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+//sqliteJSONArray encodes strings as a JSON array for json_each, sqlite having no array parameters
+func sqliteJSONArray(values []string) string {
+	encoded, _ := json.Marshal(values)
+	return string(encoded)
+}
+
+//isSQLiteBusy reports whether the error is sqlite giving up waiting for another connection's lock
+func isSQLiteBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && (sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked)
+}
+
+//isSQLiteUniqueViolation reports whether the error is sqlite refusing a duplicate key
+func isSQLiteUniqueViolation(err error) bool {
+	sqliteErr, ok := err.(sqlite3.Error)
+	return ok && (sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey)
+}
@@ -0,0 +1,35 @@
+package database
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSQLiteDBClientAddsArticleDays(t *testing.T) {
+	//a database made before articles had publication days
+	path := filepath.Join(t.TempDir(), "before-days.db")
+	db, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	_, err = db.Exec(`CREATE TABLE ARTICLES (
+		ID INTEGER PRIMARY KEY AUTOINCREMENT, TITLE TEXT NOT NULL, ARTICLE_DATE TEXT NOT NULL, BODY TEXT NOT NULL,
+		EXCERPT TEXT NOT NULL DEFAULT '', WORD_COUNT INTEGER NOT NULL DEFAULT 0, READING_MINUTES INTEGER NOT NULL DEFAULT 0,
+		CREATEDDATE TEXT NOT NULL, STATUS TEXT NOT NULL DEFAULT 'draft', PUBLISH_AT TEXT NULL, PUBLISHED_AT TEXT NULL, DELETED_AT TEXT NULL);
+		INSERT INTO ARTICLES(TITLE, ARTICLE_DATE, BODY, CREATEDDATE) VALUES ('old', '2016-09-22 00:00:00.000000000', 'body', '2016-09-22 00:00:00.000000000');`)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	client := NewSQLiteDBClient(path, newTestLogger())
+	defer client.DB.Close()
+
+	article, err := client.GetArticleRowByID(1)
+	require.NoError(t, err)
+	require.NotNil(t, article)
+	assert.Equal(t, "2016-09-22", article.Day)
+
+	//opening it again leaves it as it is
+	require.NoError(t, sqliteAddArticleDay(client.DB))
+}
@@ -0,0 +1,228 @@
+package database
+
+import (
+	"database/sql"
+	"strings"
+	"sync"
+
+	"github.com/lib/pq"
+)
+
+//preparedQueries are the queries run through prepared statements
+var preparedQueries = queriesToPrepare()
+
+func queriesToPrepare() map[string]bool {
+	queries := map[string]bool{}
+	for _, query := range []string{
+		createArticleQuery, createTagsQuery, linkArticleTagsQuery, resolveTagAliasesQuery,
+		articleByIDQuery, articlesByTagAndDateQuery, articlesByTagTreeAndDateQuery, recentArticlesQuery, deletedArticlesQuery,
+		articleByContentHashQuery, articleFingerprintsQuery,
+		updateArticleStatusQuery, publishScheduledArticlesQuery, deleteArticleQuery, restoreArticleQuery, purgeDeletedArticlesQuery,
+		tagTotalQuery, tagBySlugQuery, tagSuggestionsQuery, trendingTagsQuery, relatedTagsQuery,
+		lockTagQuery, lockTagsQuery, tagAliasOwnerQuery, tagIsBelowQuery, renameTagQuery, deleteOwnTagAliasQuery,
+		addTagAliasQuery, removeTagAliasQuery, setTagParentQuery, mergeArticleTagsQuery, unlinkTagQuery,
+		moveTagAliasesQuery, promoteMergedTagQuery, moveTagChildrenQuery, deleteTagQuery,
+		deleteExpiredIdempotencyKeysQuery, reserveIdempotencyKeyQuery, idempotentResponseQuery, saveIdempotentResponseQuery, releaseIdempotencyKeyQuery,
+	} {
+		queries[query] = true
+	}
+	for _, query := range tagPageQueries {
+		queries[query] = true
+	}
+	return queries
+}
+
+//preparedStatements holds a postgres connection pool's prepared statements for the queries in preparedQueries, so
+//postgres parses and plans each of them once rather than on every call. database/sql prepares a statement again on
+//each new connection it runs on, so connections being reset or replaced need nothing from here. Statements postgres
+//forgot or can no longer use e.g. after a pooler reset the connection or a migration changed a table are prepared again
+type preparedStatements struct {
+	db *sql.DB
+
+	mu    sync.RWMutex
+	stmts map[string]*sql.Stmt
+}
+
+//newPreparedStatements returns the statements of db, each prepared the first time it is run
+func newPreparedStatements(db *sql.DB) *preparedStatements {
+	return &preparedStatements{db: db, stmts: map[string]*sql.Stmt{}}
+}
+
+//prepareStatements prepares every query in preparedQueries on db up front, failing if postgres rejects any of them
+func prepareStatements(db *sql.DB) (*preparedStatements, error) {
+	p := newPreparedStatements(db)
+	for query := range preparedQueries {
+		_, err := p.stmt(query)
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+//stmt returns the statement of the query, preparing it if it hasn't been yet. Returns nil for queries that aren't in
+//preparedQueries, which run unprepared
+func (p *preparedStatements) stmt(query string) (*sql.Stmt, error) {
+	if !preparedQueries[query] {
+		return nil, nil
+	}
+
+	if stmt := p.prepared(query); stmt != nil {
+		return stmt, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if stmt, ok := p.stmts[query]; ok {
+		return stmt, nil
+	}
+	stmt, err := p.db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	p.stmts[query] = stmt
+	return stmt, nil
+}
+
+//prepared returns the statement of the query if it has been prepared, or nil
+func (p *preparedStatements) prepared(query string) *sql.Stmt {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.stmts[query]
+}
+
+//discard closes the stale statement of the query so the next call prepares it again. Concurrent calls finding the same
+//statement stale only close it once
+func (p *preparedStatements) discard(query string, stale *sql.Stmt) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stmts[query] == stale {
+		delete(p.stmts, query)
+		stale.Close()
+	}
+}
+
+//reprepare replaces the stale statement of the query with a newly prepared one
+func (p *preparedStatements) reprepare(query string, stale *sql.Stmt) (*sql.Stmt, error) {
+	p.discard(query, stale)
+	return p.stmt(query)
+}
+
+//Close closes every statement
+func (p *preparedStatements) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for query, stmt := range p.stmts {
+		stmt.Close()
+		delete(p.stmts, query)
+	}
+}
+
+//on returns an executor running queries against conn through the prepared statements, tx being conn when it is a
+//transaction. Without statements queries run against conn as they are
+func (p *preparedStatements) on(conn sqlExecutor, tx *sql.Tx) sqlExecutor {
+	if p == nil {
+		return conn
+	}
+	return &preparedExecutor{sqlExecutor: conn, tx: tx, stmts: p}
+}
+
+//preparedExecutor runs the queries in preparedQueries through their prepared statements and every other query against
+//the connection it wraps. Outside a transaction a query whose statement went stale is run once more on a new one, inside
+//a transaction the error aborted the transaction so it is returned and a later call prepares the statement again
+type preparedExecutor struct {
+	sqlExecutor
+
+	tx    *sql.Tx
+	stmts *preparedStatements
+}
+
+func (e *preparedExecutor) Exec(query string, args ...interface{}) (sql.Result, error) {
+	stmt, err := e.statement(query)
+	if err != nil || stmt == nil {
+		return e.sqlExecutor.Exec(query, args...)
+	}
+
+	res, err := e.bind(stmt).Exec(args...)
+	if isStaleStatement(err) {
+		if e.tx != nil {
+			e.stmts.discard(query, stmt)
+			return res, err
+		}
+		stmt, err = e.stmts.reprepare(query, stmt)
+		if err != nil {
+			return nil, err
+		}
+		return stmt.Exec(args...)
+	}
+	return res, err
+}
+
+func (e *preparedExecutor) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	stmt, err := e.statement(query)
+	if err != nil || stmt == nil {
+		return e.sqlExecutor.Query(query, args...)
+	}
+
+	rows, err := e.bind(stmt).Query(args...)
+	if isStaleStatement(err) {
+		if e.tx != nil {
+			e.stmts.discard(query, stmt)
+			return rows, err
+		}
+		stmt, err = e.stmts.reprepare(query, stmt)
+		if err != nil {
+			return nil, err
+		}
+		return stmt.Query(args...)
+	}
+	return rows, err
+}
+
+func (e *preparedExecutor) QueryRow(query string, args ...interface{}) *sql.Row {
+	stmt, err := e.statement(query)
+	if err != nil || stmt == nil {
+		return e.sqlExecutor.QueryRow(query, args...)
+	}
+
+	row := e.bind(stmt).QueryRow(args...)
+	if isStaleStatement(row.Err()) {
+		if e.tx != nil {
+			e.stmts.discard(query, stmt)
+			return row
+		}
+		stmt, err = e.stmts.reprepare(query, stmt)
+		if err == nil {
+			return stmt.QueryRow(args...)
+		}
+	}
+	return row
+}
+
+//statement returns the statement of the query. Inside a transaction only statements already prepared are used, as
+//preparing one would take a second connection from the pool while the transaction holds its own
+func (e *preparedExecutor) statement(query string) (*sql.Stmt, error) {
+	if e.tx != nil {
+		return e.stmts.prepared(query), nil
+	}
+	return e.stmts.stmt(query)
+}
+
+//bind returns the statement to run, the statement on the transaction's connection when there is one
+func (e *preparedExecutor) bind(stmt *sql.Stmt) *sql.Stmt {
+	if e.tx != nil {
+		return e.tx.Stmt(stmt)
+	}
+	return stmt
+}
+
+//isStaleStatement reports whether postgres couldn't run a prepared statement because it no longer has it, or because
+//the tables it reads changed shape since it was prepared
+func isStaleStatement(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	if !ok {
+		return false
+	}
+	return pqErr.Code == "26000" || (pqErr.Code == "0A000" && strings.Contains(pqErr.Message, "cached plan must not change result type"))
+}
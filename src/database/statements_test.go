@@ -0,0 +1,93 @@
+package database
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreparedStatements(t *testing.T) {
+	//the statements don't depend on postgres, sqlite runs the queries simple enough for both
+	newStatements := func(t *testing.T) *preparedStatements {
+		client := NewSQLiteDBClient(":memory:", newTestLogger())
+		t.Cleanup(func() { client.DB.Close() })
+		return newPreparedStatements(client.DB)
+	}
+
+	t.Run("Given a query to prepare, it is prepared once and reused", func(t *testing.T) {
+		stmts := newStatements(t)
+		conn := stmts.on(stmts.db, nil)
+
+		_, err := conn.Exec(deleteTagQuery, 1)
+		require.NoError(t, err)
+		first := stmts.stmts[deleteTagQuery]
+		require.NotNil(t, first)
+
+		_, err = conn.Exec(deleteTagQuery, 2)
+		require.NoError(t, err)
+		assert.Equal(t, first, stmts.stmts[deleteTagQuery])
+		assert.Equal(t, 1, len(stmts.stmts))
+	})
+	t.Run("Given a query not to prepare, it runs as it is", func(t *testing.T) {
+		stmts := newStatements(t)
+
+		_, err := stmts.on(stmts.db, nil).Exec(`DELETE FROM TAGS WHERE ID = $1 OR ID = $2`, 1, 2)
+		require.NoError(t, err)
+		assert.Equal(t, 0, len(stmts.stmts))
+	})
+	t.Run("Given a query in a transaction, it runs through the statement in the transaction", func(t *testing.T) {
+		stmts := newStatements(t)
+		stmt, err := stmts.stmt(tagAliasOwnerQuery)
+		require.NoError(t, err)
+		tx, err := stmts.db.Begin()
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		var id int
+		err = stmts.on(tx, tx).QueryRow(tagAliasOwnerQuery, "missing").Scan(&id)
+		assert.Equal(t, sql.ErrNoRows, err)
+		assert.Equal(t, stmt, stmts.stmts[tagAliasOwnerQuery])
+	})
+	t.Run("Given a query in a transaction that hasn't been prepared, it runs as it is", func(t *testing.T) {
+		//sqlite has a single connection, which the transaction holds, so preparing the statement would wait forever
+		stmts := newStatements(t)
+		tx, err := stmts.db.Begin()
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		var id int
+		err = stmts.on(tx, tx).QueryRow(tagAliasOwnerQuery, "missing").Scan(&id)
+		assert.Equal(t, sql.ErrNoRows, err)
+		assert.Equal(t, 0, len(stmts.stmts))
+	})
+	t.Run("Given a stale statement, it is replaced once", func(t *testing.T) {
+		stmts := newStatements(t)
+		stale, err := stmts.stmt(deleteTagQuery)
+		require.NoError(t, err)
+
+		fresh, err := stmts.reprepare(deleteTagQuery, stale)
+		require.NoError(t, err)
+		assert.NotEqual(t, stale, fresh)
+
+		again, err := stmts.reprepare(deleteTagQuery, stale)
+		require.NoError(t, err)
+		assert.Equal(t, fresh, again)
+	})
+	t.Run("Given no statements, queries run against the connection", func(t *testing.T) {
+		stmts := newStatements(t)
+		var none *preparedStatements
+
+		assert.Equal(t, stmts.db, none.on(stmts.db, nil))
+	})
+}
+
+func TestIsStaleStatement(t *testing.T) {
+	assert.True(t, isStaleStatement(&pq.Error{Code: "26000", Message: `prepared statement "1" does not exist`}))
+	assert.True(t, isStaleStatement(&pq.Error{Code: "0A000", Message: "cached plan must not change result type"}))
+	assert.False(t, isStaleStatement(&pq.Error{Code: "0A000", Message: "something else unsupported"}))
+	assert.False(t, isStaleStatement(&pq.Error{Code: "23505"}))
+	assert.False(t, isStaleStatement(nil))
+}
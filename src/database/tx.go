@@ -0,0 +1,115 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+//maxTxAttempts is how many times WithTx runs a transaction that keeps conflicting with concurrent ones before giving up
+const maxTxAttempts = 5
+
+//txRetryDelay is how long WithTx waits before its first retry, doubling before each retry after that
+const txRetryDelay = 10 * time.Millisecond
+
+//txSavepoint names the savepoints set inside WithTx. Savepoints stack, so nested ones can share the name
+const txSavepoint = "article_api_write"
+
+//sqlExecutor is satisfied by both *sql.DB and *sql.Tx, so queries run the same inside WithTx as outside it
+type sqlExecutor interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Prepare(query string) (*sql.Stmt, error)
+}
+
+//writeTx is the transaction of a method writing several statements. Outside WithTx it is a transaction of its own and
+//inside WithTx it is a savepoint, so a method that fails only undoes its own writes
+type writeTx struct {
+	sqlExecutor
+
+	tx        *sql.Tx
+	savepoint bool
+	done      bool
+}
+
+//beginWriteTx begins a transaction on db, or sets a savepoint in tx when there is one
+func beginWriteTx(db *sql.DB, tx *sql.Tx) (*writeTx, error) {
+	if tx != nil {
+		_, err := tx.Exec("SAVEPOINT " + txSavepoint)
+		if err != nil {
+			return nil, err
+		}
+		return &writeTx{sqlExecutor: tx, tx: tx, savepoint: true}, nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &writeTx{sqlExecutor: tx, tx: tx}, nil
+}
+
+//Commit commits the transaction, or releases the savepoint leaving its writes to the surrounding transaction
+func (w *writeTx) Commit() error {
+	if !w.savepoint {
+		return w.tx.Commit()
+	}
+	w.done = true
+	_, err := w.tx.Exec("RELEASE SAVEPOINT " + txSavepoint)
+	return err
+}
+
+//Rollback undoes the writes. Like sql.Tx it does nothing once committed, so it can be deferred
+func (w *writeTx) Rollback() error {
+	if !w.savepoint {
+		return w.tx.Rollback()
+	}
+	if w.done {
+		return nil
+	}
+	w.done = true
+	_, err := w.tx.Exec("ROLLBACK TO SAVEPOINT " + txSavepoint)
+	if err != nil {
+		return err
+	}
+	_, err = w.tx.Exec("RELEASE SAVEPOINT " + txSavepoint)
+	return err
+}
+
+//withSavepoint runs fn with client in a savepoint of tx, for WithTx called inside another WithTx. Retrying is left to the
+//outermost WithTx since a conflict aborts the whole transaction
+func withSavepoint(tx *sql.Tx, client DBClient, fn func(tx DBClient) error) error {
+	savepoint, err := beginWriteTx(nil, tx)
+	if err != nil {
+		return err
+	}
+	defer savepoint.Rollback()
+
+	err = fn(client)
+	if err != nil {
+		return err
+	}
+	return savepoint.Commit()
+}
+
+//retryTx calls run until it returns nil or an error retryable doesn't accept, the context is done or maxTxAttempts is reached
+func retryTx(ctx context.Context, logger *logrus.Entry, retryable func(err error) bool, run func() error) error {
+	delay := txRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := run()
+		if err == nil || !retryable(err) || attempt == maxTxAttempts {
+			return err
+		}
+		logger.Warnf("WithTx :: retrying transaction after attempt %d : %v", attempt, err)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
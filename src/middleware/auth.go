@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+)
+
+//APIKeyHeader is the request header the admin api key is read from
+const APIKeyHeader = "X-API-Key"
+
+type contextKey string
+
+const authenticatedKey contextKey = "authenticated"
+
+//Authenticate flags requests carrying the configured api key as authenticated. It never rejects a request,
+//handlers use IsAuthenticated to decide what an anonymous caller can see
+func Authenticate(apiKey string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if HasAPIKey(r, apiKey) {
+				r = r.WithContext(context.WithValue(r.Context(), authenticatedKey, true))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+//IsAuthenticated reports whether Authenticate accepted the api key on the request
+func IsAuthenticated(r *http.Request) bool {
+	authenticated, _ := r.Context().Value(authenticatedKey).(bool)
+	return authenticated
+}
+
+//RequireAPIKey only lets a request through when it carries the configured api key.
+//An empty key rejects every request so admin routes are closed unless a key is configured
+func RequireAPIKey(apiKey string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !HasAPIKey(r, apiKey) {
+				apiError.ApiError(w, r, http.StatusUnauthorized, "a valid api key is required")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+//HasAPIKey reports whether the request carries the configured api key
+func HasAPIKey(r *http.Request, apiKey string) bool {
+	if apiKey == "" {
+		return false
+	}
+	provided := r.Header.Get(APIKeyHeader)
+	return subtle.ConstantTimeCompare([]byte(provided), []byte(apiKey)) == 1
+}
@@ -0,0 +1,150 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+//ErrRequestTooLarge is returned when reading a request body past the limit BodyLimiter set for its route
+var ErrRequestTooLarge = errors.New("request body too large")
+
+//byteSizeUnits are the units ParseByteSize accepts, bytes last so "MB" isn't read as "M" bytes
+var byteSizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"KB", 1 << 10},
+	{"MB", 1 << 20},
+	{"GB", 1 << 30},
+	{"B", 1},
+}
+
+//ParseByteSize parses a size in bytes, optionally with a KB, MB or GB suffix e.g. "512KB"
+func ParseByteSize(value string) (int64, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	multiplier := int64(1)
+	for _, unit := range byteSizeUnits {
+		if strings.HasSuffix(value, unit.suffix) {
+			value, multiplier = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix)), unit.bytes
+			break
+		}
+	}
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || size < 0 {
+		return 0, errors.New("size must be a non negative number of bytes, KB, MB or GB e.g. 512KB")
+	}
+	return size * multiplier, nil
+}
+
+//ParseRouteByteSizes parses comma separated route body limits, each the method and path template of a route and its
+//size e.g. "POST /articles=64KB,POST /articles/bulk=64MB"
+func ParseRouteByteSizes(value string) (map[string]int64, error) {
+	entries, err := parseRouteEntries(value, "METHOD /path=size")
+	if err != nil {
+		return nil, err
+	}
+	sizes := map[string]int64{}
+	for route, entry := range entries {
+		size, err := ParseByteSize(entry)
+		if err != nil {
+			return nil, err
+		}
+		sizes[route] = size
+	}
+	return sizes, nil
+}
+
+//BodyLimiter caps how much of a request body handlers can read. Routes without their own limit get the default
+type BodyLimiter struct {
+	defaultLimit int64
+	routeLimits  map[string]int64
+}
+
+//NewBodyLimiter limits routes without their own limit in routeLimits, keyed by "METHOD /path/template", to defaultLimit
+//bytes. A limit of 0 doesn't limit
+func NewBodyLimiter(defaultLimit int64, routeLimits map[string]int64) *BodyLimiter {
+	return &BodyLimiter{defaultLimit: defaultLimit, routeLimits: routeLimits}
+}
+
+//Limit answers a request whose Content-Length is over its route's limit with a 413 straight away. Reading any other body
+//past the limit fails with ErrRequestTooLarge and the connection is closed
+func (l *BodyLimiter) Limit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit := l.defaultLimit
+		if route, ok := routeKey(r); ok {
+			if routeLimit, ok := l.routeLimits[route]; ok {
+				limit = routeLimit
+			}
+		}
+		if limit == 0 || r.Body == nil || r.Body == http.NoBody {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.ContentLength > limit {
+			apiError.ApiError(w, r, http.StatusRequestEntityTooLarge, "Request body must be at most "+strconv.FormatInt(limit, 10)+" bytes")
+			return
+		}
+		r.Body = &limitedBody{ReadCloser: r.Body, w: w, remaining: limit}
+		next.ServeHTTP(w, r)
+	})
+}
+
+//limitedBody reads up to remaining bytes of the body like http.MaxBytesReader, failing with ErrRequestTooLarge once there
+//is more. The error the body itself fails with is returned as it is. Go 1.16 has no http.MaxBytesError to tell the two apart
+type limitedBody struct {
+	io.ReadCloser
+
+	w         http.ResponseWriter
+	remaining int64
+	err       error
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	//reading a byte more than is left tells a body at the limit apart from one over it
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) <= b.remaining {
+		b.remaining -= int64(n)
+		return n, err
+	}
+
+	n = int(b.remaining)
+	b.remaining = 0
+	b.err = ErrRequestTooLarge
+	//the rest of the body isn't read, so the connection can't be used for another request
+	b.w.Header().Set("Connection", "close")
+	return n, b.err
+}
+
+const strictDecodingKey contextKey = "strictDecoding"
+
+//StrictDecoding makes DecodeRequest reject JSON and MessagePack bodies with fields the request doesn't have, rather than
+//ignoring them
+func StrictDecoding(strict bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strict {
+				r = r.WithContext(context.WithValue(r.Context(), strictDecodingKey, true))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+//DecodesStrictly reports whether StrictDecoding flagged the request to have unknown fields rejected
+func DecodesStrictly(r *http.Request) bool {
+	strict, _ := r.Context().Value(strictDecodingKey).(bool)
+	return strict
+}
@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBodyLimiter(t *testing.T) {
+	var readErr error
+	read := func(w http.ResponseWriter, r *http.Request) {
+		_, readErr = io.ReadAll(r.Body)
+	}
+	router := mux.NewRouter()
+	router.Use(NewBodyLimiter(8, map[string]int64{"POST /articles/bulk": 16}).Limit)
+	router.HandleFunc("/articles", read).Methods("POST")
+	router.HandleFunc("/articles/bulk", read).Methods("POST")
+
+	t.Run("Given a Content-Length over the limit, 413 is returned without reading the body", func(t *testing.T) {
+		readErr = nil
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/articles", strings.NewReader("123456789")))
+
+		assert.Equal(t, 413, w.Result().StatusCode)
+		assert.Equal(t, "application/json", w.Result().Header.Get("Content-Type"))
+	})
+	t.Run("Given a body without a Content-Length, reading past the limit fails with ErrRequestTooLarge", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "/articles", strings.NewReader("123456789"))
+		r.ContentLength = -1
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		assert.True(t, errors.Is(readErr, ErrRequestTooLarge))
+		assert.Equal(t, "close", w.Result().Header.Get("Connection"))
+	})
+	t.Run("Given a body failing to read at the limit, its own error is returned", func(t *testing.T) {
+		failure := errors.New("connection reset")
+		r := httptest.NewRequest("POST", "/articles", io.MultiReader(strings.NewReader("12345678"), &failingReader{failure}))
+		r.ContentLength = -1
+		router.ServeHTTP(httptest.NewRecorder(), r)
+
+		assert.True(t, errors.Is(readErr, failure))
+	})
+	t.Run("Given a body at the limit, it is read", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/articles", strings.NewReader("12345678")))
+
+		assert.Equal(t, 200, w.Result().StatusCode)
+		assert.NoError(t, readErr)
+	})
+	t.Run("Given a route with its own limit, its limit is used", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/articles/bulk", strings.NewReader("123456789")))
+
+		assert.Equal(t, 200, w.Result().StatusCode)
+		assert.NoError(t, readErr)
+	})
+}
+
+func TestParseByteSize(t *testing.T) {
+	t.Run("Given a size with or without a unit, it is parsed in bytes", func(t *testing.T) {
+		for value, expected := range map[string]int64{"100": 100, "100B": 100, "512KB": 512 << 10, "1mb": 1 << 20, "2 GB": 2 << 30} {
+			size, err := ParseByteSize(value)
+			assert.NoError(t, err, value)
+			assert.Equal(t, expected, size, value)
+		}
+	})
+	t.Run("Given an invalid size, an error is returned", func(t *testing.T) {
+		for _, value := range []string{"", "MB", "-1KB", "1TB", "lots"} {
+			_, err := ParseByteSize(value)
+			assert.Error(t, err, value)
+		}
+	})
+	t.Run("Given route sizes, each route's size is parsed", func(t *testing.T) {
+		sizes, err := ParseRouteByteSizes("POST /articles/bulk=64MB")
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]int64{"POST /articles/bulk": 64 << 20}, sizes)
+	})
+}
+
+type failingReader struct {
+	err error
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	return 0, f.err
+}
@@ -0,0 +1,285 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+//Content types the API can read and write
+const (
+	JSONContentType    = "application/json"
+	XMLContentType     = "application/xml"
+	MsgpackContentType = "application/msgpack"
+)
+
+//AcceptDatetimeHeader is the request header choosing how dates are returned. DatetimeRFC3339 returns articles' dates as
+//full RFC 3339 timestamps, anything else as the publication day
+const (
+	AcceptDatetimeHeader = "Accept-Datetime"
+	DatetimeRFC3339      = "rfc3339"
+)
+
+//ErrUnsupportedMediaType is returned by DecodeRequest when the request Content-Type can't be read
+var ErrUnsupportedMediaType = errors.New("unsupported media type")
+
+//ErrTrailingData is returned by DecodeRequest when the request body has more after the value it decoded
+var ErrTrailingData = errors.New("request body has data after its value")
+
+//ErrUnknownField is wrapped by DecodeRequest's error when a request decoded strictly has a field v doesn't
+var ErrUnknownField = errors.New("unknown field")
+
+//mediaTypeAliases maps every media type we understand to the content type used for it
+var mediaTypeAliases = map[string]string{
+	"application/json":        JSONContentType,
+	"text/json":               JSONContentType,
+	"application/xml":         XMLContentType,
+	"text/xml":                XMLContentType,
+	"application/msgpack":     MsgpackContentType,
+	"application/x-msgpack":   MsgpackContentType,
+	"application/vnd.msgpack": MsgpackContentType,
+	"application/*":           JSONContentType,
+	"*/*":                     JSONContentType,
+}
+
+//xmlList gives a top level slice a root element so the XML is a single document
+type xmlList struct {
+	XMLName xml.Name `xml:"list"`
+	Items   interface{}
+}
+
+//Negotiate rejects requests whose Accept header doesn't allow any content type the API can respond with
+func Negotiate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if NegotiateResponseType(r.Header.Get("Accept")) == "" {
+			apiError.ApiError(w, r, http.StatusNotAcceptable, "Responses are only available as \""+JSONContentType+"\", \""+XMLContentType+"\" or \""+MsgpackContentType+"\"")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+//NegotiateResponseType picks the response content type from an Accept header, honouring q values and defaulting to JSON.
+//An empty result means none of the accepted types can be produced
+func NegotiateResponseType(accept string) string {
+	return NegotiateContentType(accept, mediaTypeAliases)
+}
+
+//NegotiateContentType picks the content type offered for the most preferred media type in an Accept header, honouring q
+//values. offers maps every media type, wildcards included, to the content type sent for it, and no Accept header is
+//taken as */*. An empty result means none of the accepted types are offered
+func NegotiateContentType(accept string, offers map[string]string) string {
+	if strings.TrimSpace(accept) == "" {
+		return offers["*/*"]
+	}
+
+	type acceptedType struct {
+		mediaType string
+		quality   float64
+	}
+	accepted := []acceptedType{}
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			quality, err = strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+		}
+		if quality <= 0 {
+			continue
+		}
+		accepted = append(accepted, acceptedType{mediaType: mediaType, quality: quality})
+	}
+	sort.SliceStable(accepted, func(i, j int) bool {
+		return accepted[i].quality > accepted[j].quality
+	})
+
+	for _, a := range accepted {
+		if contentType, ok := offers[a.mediaType]; ok {
+			return contentType
+		}
+	}
+	return ""
+}
+
+//AcceptsTimestamps reports whether the request asks for dates as full timestamps rather than days
+func AcceptsTimestamps(r *http.Request) bool {
+	return r != nil && strings.EqualFold(strings.TrimSpace(r.Header.Get(AcceptDatetimeHeader)), DatetimeRFC3339)
+}
+
+//DecodeRequest decodes the request body into v using the request Content-Type, defaulting to JSON when it isn't set.
+//The body must hold that one value and nothing after it. Requests flagged by StrictDecoding also can't have JSON or
+//MessagePack fields v doesn't, XML has no way of finding them
+func DecodeRequest(r *http.Request, v interface{}) error {
+	contentType := JSONContentType
+	if header := r.Header.Get("Content-Type"); header != "" {
+		mediaType, _, err := mime.ParseMediaType(header)
+		if err != nil {
+			return ErrUnsupportedMediaType
+		}
+		//wildcards are only meaningful in Accept
+		if strings.Contains(mediaType, "*") {
+			return ErrUnsupportedMediaType
+		}
+		var ok bool
+		contentType, ok = mediaTypeAliases[mediaType]
+		if !ok {
+			return ErrUnsupportedMediaType
+		}
+	}
+
+	strict := DecodesStrictly(r)
+	switch contentType {
+	case XMLContentType:
+		return decodeXML(r.Body, v)
+	case MsgpackContentType:
+		return decodeMsgpack(r.Body, v, strict)
+	default:
+		return DecodeJSON(r.Body, v, strict)
+	}
+}
+
+//DecodeJSON decodes the one JSON value the body holds into v, rejecting fields v doesn't have when strict
+func DecodeJSON(body io.Reader, v interface{}, strict bool) error {
+	decoder := json.NewDecoder(body)
+	if strict {
+		decoder.DisallowUnknownFields()
+	}
+	err := decoder.Decode(v)
+	if err != nil {
+		return unknownFieldError(err, "json: unknown field ")
+	}
+	_, err = decoder.Token()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil && !isSyntaxError(err) {
+		return err
+	}
+	return ErrTrailingData
+}
+
+func decodeXML(body io.Reader, v interface{}) error {
+	decoder := xml.NewDecoder(body)
+	err := decoder.Decode(v)
+	if err != nil {
+		return err
+	}
+	//only whitespace, comments and processing instructions can follow the root element
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if _, ok := err.(*xml.SyntaxError); ok {
+				return ErrTrailingData
+			}
+			return err
+		}
+		switch token := token.(type) {
+		case xml.Comment, xml.ProcInst:
+		case xml.CharData:
+			if len(bytes.TrimSpace(token)) > 0 {
+				return ErrTrailingData
+			}
+		default:
+			return ErrTrailingData
+		}
+	}
+}
+
+func decodeMsgpack(body io.Reader, v interface{}, strict bool) error {
+	decoder := msgpack.NewDecoder(body)
+	decoder.SetCustomStructTag("json")
+	decoder.DisallowUnknownFields(strict)
+	err := decoder.Decode(v)
+	if err != nil {
+		return unknownFieldError(err, "msgpack: unknown field ")
+	}
+	_, err = decoder.PeekCode()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return ErrTrailingData
+}
+
+//unknownFieldError wraps ErrUnknownField around a decoder's error about a field that isn't known, which is only told
+//apart by its message starting with prefix
+func unknownFieldError(err error, prefix string) error {
+	if strings.HasPrefix(err.Error(), prefix) {
+		return fmt.Errorf("%w %s", ErrUnknownField, strings.TrimPrefix(err.Error(), prefix))
+	}
+	return err
+}
+
+func isSyntaxError(err error) bool {
+	_, ok := err.(*json.SyntaxError)
+	return ok
+}
+
+//encodeResponse writes the body in the content type negotiated from the request's Accept header. The body is encoded
+//before anything is written so a body that can't be encoded is answered with a 500 rather than a partial response
+func encodeResponse(w http.ResponseWriter, r *http.Request, responseCode int, responseBody interface{}) {
+	contentType := JSONContentType
+	if r != nil {
+		contentType = NegotiateResponseType(r.Header.Get("Accept"))
+		if contentType == "" {
+			contentType = JSONContentType
+		}
+	}
+
+	var body bytes.Buffer
+	if err := encodeBody(&body, contentType, responseBody); err != nil {
+		apiError.ApiError(w, r, http.StatusInternalServerError, "Internal server error encoding response")
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(responseCode)
+	w.Write(body.Bytes())
+}
+
+//encodeBody encodes v in the content type, giving a top level slice a root element in XML
+func encodeBody(w io.Writer, contentType string, v interface{}) error {
+	switch contentType {
+	case XMLContentType:
+		if isSlice(v) {
+			v = xmlList{Items: v}
+		}
+		io.WriteString(w, xml.Header)
+		return xml.NewEncoder(w).Encode(v)
+	case MsgpackContentType:
+		encoder := msgpack.NewEncoder(w)
+		encoder.SetCustomStructTag("json")
+		return encoder.Encode(v)
+	default:
+		return json.NewEncoder(w).Encode(v)
+	}
+}
+
+func isSlice(v interface{}) bool {
+	value := reflect.ValueOf(v)
+	if value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+	return value.Kind() == reflect.Slice
+}
@@ -0,0 +1,234 @@
+package middleware
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type testBody struct {
+	XMLName xml.Name `json:"-" xml:"test"`
+	Name    string   `json:"name" xml:"name"`
+	Tags    []string `json:"tags" xml:"tags>tag"`
+}
+
+func TestNegotiateResponseType(t *testing.T) {
+	t.Run("No Accept header defaults to JSON", func(t *testing.T) {
+		assert.Equal(t, JSONContentType, NegotiateResponseType(""))
+	})
+	t.Run("Wildcards are answered with JSON", func(t *testing.T) {
+		assert.Equal(t, JSONContentType, NegotiateResponseType("*/*"))
+		assert.Equal(t, JSONContentType, NegotiateResponseType("application/*"))
+	})
+	t.Run("XML and MessagePack aliases are recognised", func(t *testing.T) {
+		assert.Equal(t, XMLContentType, NegotiateResponseType("text/xml"))
+		assert.Equal(t, MsgpackContentType, NegotiateResponseType("application/x-msgpack"))
+	})
+	t.Run("q values pick the most preferred supported type", func(t *testing.T) {
+		assert.Equal(t, XMLContentType, NegotiateResponseType("application/json;q=0.5, application/xml;q=0.9"))
+		assert.Equal(t, MsgpackContentType, NegotiateResponseType("text/html, application/msgpack;q=0.1"))
+		assert.Equal(t, JSONContentType, NegotiateResponseType("application/xml;q=0, application/json"))
+	})
+	t.Run("Unsupported types give no match", func(t *testing.T) {
+		assert.Equal(t, "", NegotiateResponseType("text/html"))
+		assert.Equal(t, "", NegotiateResponseType("application/xml;q=0"))
+	})
+}
+
+func TestAcceptsTimestamps(t *testing.T) {
+	for header, expected := range map[string]bool{"": false, "rfc3339": true, " RFC3339 ": true, "Thu, 31 May 2007 20:35:00 GMT": false} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set(AcceptDatetimeHeader, header)
+		assert.Equal(t, expected, AcceptsTimestamps(r), header)
+	}
+	assert.False(t, AcceptsTimestamps(nil))
+}
+
+func TestNegotiate(t *testing.T) {
+	handler := Negotiate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+	}))
+	t.Run("Given an unsupported Accept header, 406 is returned", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", "text/html")
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		assert.Equal(t, 406, w.Result().StatusCode)
+		assert.Equal(t, JSONContentType, w.Result().Header.Get("Content-Type"))
+	})
+	t.Run("Given a supported Accept header, the request is handled", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", "application/xml")
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		assert.Equal(t, 200, w.Result().StatusCode)
+	})
+}
+
+func TestModelResponse(t *testing.T) {
+	body := &testBody{Name: "name", Tags: []string{"a", "b"}}
+	t.Run("Given Accept XML, the body is written as XML", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", "application/xml")
+		w := httptest.NewRecorder()
+
+		ModelResponse(w, req, 200, body)
+
+		assert.Equal(t, XMLContentType, w.Result().Header.Get("Content-Type"))
+		assert.Equal(t, xml.Header+"<test><name>name</name><tags><tag>a</tag><tag>b</tag></tags></test>", w.Body.String())
+	})
+	t.Run("Given Accept XML and a slice, the items are wrapped in a list element", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", "application/xml")
+		w := httptest.NewRecorder()
+
+		ModelResponse(w, req, 200, []*testBody{body})
+
+		assert.Equal(t, xml.Header+"<list><test><name>name</name><tags><tag>a</tag><tag>b</tag></tags></test></list>", w.Body.String())
+	})
+	t.Run("Given Accept MessagePack, the body is written as MessagePack using the json names", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", "application/msgpack")
+		w := httptest.NewRecorder()
+
+		ModelResponse(w, req, 200, body)
+
+		assert.Equal(t, MsgpackContentType, w.Result().Header.Get("Content-Type"))
+		decoded := map[string]interface{}{}
+		err := msgpack.Unmarshal(w.Body.Bytes(), &decoded)
+		assert.NoError(t, err)
+		assert.Equal(t, "name", decoded["name"])
+		assert.NotContains(t, decoded, "XMLName")
+	})
+	t.Run("Given no Accept header, the body is written as JSON", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		ModelResponse(w, httptest.NewRequest("GET", "/", nil), 200, body)
+
+		assert.Equal(t, JSONContentType, w.Result().Header.Get("Content-Type"))
+		assert.Equal(t, "{\"name\":\"name\",\"tags\":[\"a\",\"b\"]}\n", w.Body.String())
+	})
+	t.Run("Given a body that can't be encoded, 500 is returned without any of it", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		ModelResponse(w, httptest.NewRequest("GET", "/", nil), 200, map[string]interface{}{"value": func() {}})
+
+		assert.Equal(t, 500, w.Result().StatusCode)
+		assert.NotContains(t, w.Body.String(), "value")
+	})
+	t.Run("Given Accept XML, errors are written as XML", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", "application/xml")
+		w := httptest.NewRecorder()
+
+		apiError.ApiErrorFields(w, req, 409, "conflict", map[string]string{"ID": "7"})
+
+		assert.Equal(t, 409, w.Result().StatusCode)
+		assert.Equal(t, XMLContentType, w.Result().Header.Get("Content-Type"))
+		assert.Equal(t, xml.Header+"<error><ID>7</ID><Message>conflict</Message><Status>409</Status></error>", w.Body.String())
+	})
+}
+
+func TestDecodeRequest(t *testing.T) {
+	t.Run("Given an XML body, it is decoded", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/", strings.NewReader("<test><name>name</name><tags><tag>a</tag></tags></test>"))
+		req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+
+		decoded := &testBody{}
+		err := DecodeRequest(req, decoded)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "name", decoded.Name)
+		assert.Equal(t, []string{"a"}, decoded.Tags)
+	})
+	t.Run("Given a MessagePack body, it is decoded using the json names", func(t *testing.T) {
+		encoded, _ := msgpack.Marshal(map[string]interface{}{"name": "name", "tags": []string{"a"}})
+		req := httptest.NewRequest("POST", "/", strings.NewReader(string(encoded)))
+		req.Header.Set("Content-Type", "application/msgpack")
+
+		decoded := &testBody{}
+		err := DecodeRequest(req, decoded)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "name", decoded.Name)
+	})
+	t.Run("Given no Content-Type, the body is decoded as JSON", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(`{"name":"name"}`))
+
+		decoded := &testBody{}
+		err := DecodeRequest(req, decoded)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "name", decoded.Name)
+	})
+	t.Run("Given an unsupported Content-Type, ErrUnsupportedMediaType is returned", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/", strings.NewReader("name=name"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		err := DecodeRequest(req, &testBody{})
+
+		assert.Equal(t, ErrUnsupportedMediaType, err)
+	})
+	t.Run("Given data after the value, ErrTrailingData is returned", func(t *testing.T) {
+		encoded, _ := msgpack.Marshal(map[string]interface{}{"name": "name"})
+		for contentType, body := range map[string]string{
+			"application/json":    `{"name":"name"} {"name":"other"}`,
+			"text/json":           `{"name":"name"}}`,
+			"application/xml":     "<test><name>name</name></test><test></test>",
+			"application/msgpack": string(encoded) + string(encoded),
+		} {
+			req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+			req.Header.Set("Content-Type", contentType)
+
+			err := DecodeRequest(req, &testBody{})
+
+			assert.Equal(t, ErrTrailingData, err, contentType)
+		}
+	})
+	t.Run("Given whitespace after the value, it is decoded", func(t *testing.T) {
+		for contentType, body := range map[string]string{
+			"application/json": "{\"name\":\"name\"}\n\n",
+			"application/xml":  "<test><name>name</name></test>\n<!-- comment -->\n",
+		} {
+			req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+			req.Header.Set("Content-Type", contentType)
+
+			decoded := &testBody{}
+			err := DecodeRequest(req, decoded)
+
+			assert.NoError(t, err, contentType)
+			assert.Equal(t, "name", decoded.Name, contentType)
+		}
+	})
+	t.Run("Given an unknown field, it is only rejected when decoding strictly", func(t *testing.T) {
+		encoded, _ := msgpack.Marshal(map[string]interface{}{"name": "name", "titel": "typo"})
+		for contentType, body := range map[string]string{
+			"application/json":    `{"name":"name","titel":"typo"}`,
+			"application/msgpack": string(encoded),
+		} {
+			req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+			req.Header.Set("Content-Type", contentType)
+			assert.NoError(t, DecodeRequest(req, &testBody{}), contentType)
+
+			req = httptest.NewRequest("POST", "/", strings.NewReader(body))
+			req.Header.Set("Content-Type", contentType)
+			StrictDecoding(true)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				req = r
+			})).ServeHTTP(httptest.NewRecorder(), req)
+			err := DecodeRequest(req, &testBody{})
+
+			assert.True(t, errors.Is(err, ErrUnknownField), contentType)
+			assert.Equal(t, `unknown field "titel"`, err.Error(), contentType)
+		}
+	})
+}
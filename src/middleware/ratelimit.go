@@ -0,0 +1,283 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+//RateLimit allows a client Requests requests every Per. Up to Requests can be made at once, after that they are allowed
+//again at an even rate. A limit of 0 requests doesn't limit
+type RateLimit struct {
+	Requests int
+	Per      time.Duration
+}
+
+//ParseRateLimit parses a limit written as requests/period e.g. "100/1m"
+func ParseRateLimit(value string) (RateLimit, error) {
+	parts := strings.SplitN(strings.TrimSpace(value), "/", 2)
+	if len(parts) != 2 {
+		return RateLimit{}, errors.New("rate limit must be written as requests/period e.g. 100/1m")
+	}
+	requests, err := strconv.Atoi(parts[0])
+	if err != nil || requests < 0 {
+		return RateLimit{}, errors.New("rate limit requests must be a non negative number")
+	}
+	per, err := time.ParseDuration(parts[1])
+	if err != nil || per <= 0 {
+		return RateLimit{}, errors.New("rate limit period must be a positive duration")
+	}
+	return RateLimit{Requests: requests, Per: per}, nil
+}
+
+//ParseRouteRateLimits parses comma separated route limits, each the method and path template of a route and its limit
+//e.g. "POST /articles=10/1m,POST /articles/bulk=2/1m"
+func ParseRouteRateLimits(value string) (map[string]RateLimit, error) {
+	entries, err := parseRouteEntries(value, "METHOD /path=requests/period")
+	if err != nil {
+		return nil, err
+	}
+	limits := map[string]RateLimit{}
+	for route, entry := range entries {
+		limit, err := ParseRateLimit(entry)
+		if err != nil {
+			return nil, err
+		}
+		limits[route] = limit
+	}
+	return limits, nil
+}
+
+//parseRouteEntries splits comma separated METHOD /path=value entries into the value of each route, keyed like routeKey
+func parseRouteEntries(value, format string) (map[string]string, error) {
+	entries := map[string]string{}
+	for _, entry := range strings.Split(value, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		separator := strings.LastIndex(entry, "=")
+		if separator < 0 {
+			return nil, errors.New("route entries must be written as " + format)
+		}
+		fields := strings.Fields(entry[:separator])
+		if len(fields) != 2 {
+			return nil, errors.New("route entries must be written as " + format)
+		}
+		entries[strings.ToUpper(fields[0])+" "+fields[1]] = entry[separator+1:]
+	}
+	return entries, nil
+}
+
+//routeKey identifies the route mux matched the request to by its method and path template e.g. "GET /articles/{id}"
+func routeKey(r *http.Request) (string, bool) {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return "", false
+	}
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return "", false
+	}
+	return r.Method + " " + template, true
+}
+
+//ParseTrustedProxies parses the addresses or CIDR ranges e.g. "10.0.0.0/8" of the proxies trusted to set X-Forwarded-For
+func ParseTrustedProxies(values []string) ([]*net.IPNet, error) {
+	proxies := []*net.IPNet{}
+	for _, value := range values {
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, errors.New("trusted proxy is not an IP address or CIDR range: " + value)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, proxy, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, errors.New("trusted proxy is not an IP address or CIDR range: " + value)
+		}
+		proxies = append(proxies, proxy)
+	}
+	return proxies, nil
+}
+
+//RateLimiter limits how many requests each client makes with a token bucket per client. Authenticated clients are told
+//apart by their api key and everyone else by their IP address. A route with its own limit gets buckets of its own, every
+//other route shares the default limit. Buckets are kept in memory, so each instance of the API limits separately
+type RateLimiter struct {
+	defaultLimit   RateLimit
+	routeLimits    map[string]RateLimit
+	trustedProxies []*net.IPNet
+
+	now       func() time.Time
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+//tokenBucket holds the requests a client has left as of updated
+type tokenBucket struct {
+	limit   RateLimit
+	tokens  float64
+	updated time.Time
+}
+
+//NewRateLimiter limits routes without their own limit in routeLimits, keyed by "METHOD /path/template", to defaultLimit.
+//X-Forwarded-For is only believed when the request comes through one of the trusted proxies
+func NewRateLimiter(defaultLimit RateLimit, routeLimits map[string]RateLimit, trustedProxies []*net.IPNet) *RateLimiter {
+	return &RateLimiter{
+		defaultLimit:   defaultLimit,
+		routeLimits:    routeLimits,
+		trustedProxies: trustedProxies,
+		now:            time.Now,
+		buckets:        map[string]*tokenBucket{},
+	}
+}
+
+//Limit answers a client that has used up its limit with a 429. Every limited response has RateLimit-Limit,
+//RateLimit-Remaining and RateLimit-Reset headers, the seconds until the client has its whole limit again
+func (l *RateLimiter) Limit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, limit := l.routeLimit(r)
+		if limit.Requests == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		allowed, remaining, reset, retryAfter := l.take(route+"|"+l.clientKey(r), limit)
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Requests))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(reset))
+		w.Header().Set("RateLimit-Policy", strconv.Itoa(limit.Requests)+";w="+strconv.Itoa(int(math.Ceil(limit.Per.Seconds()))))
+		if !allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			apiError.ApiError(w, r, http.StatusTooManyRequests, "Too many requests, retry in "+strconv.Itoa(retryAfter)+" seconds")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+//routeLimit returns the route the request is limited by and its limit. The route is empty for the default limit
+func (l *RateLimiter) routeLimit(r *http.Request) (string, RateLimit) {
+	if route, ok := routeKey(r); ok {
+		if limit, ok := l.routeLimits[route]; ok {
+			return route, limit
+		}
+	}
+	return "", l.defaultLimit
+}
+
+//take uses up one of the client's requests if it has one left. It returns the requests left afterwards, the seconds until
+//the bucket is full again and, when the request isn't allowed, the seconds until it would be
+func (l *RateLimiter) take(key string, limit RateLimit) (allowed bool, remaining, reset, retryAfter int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+	bucket, ok := l.buckets[key]
+	if !ok || bucket.limit != limit {
+		bucket = &tokenBucket{limit: limit, tokens: float64(limit.Requests), updated: now}
+		l.buckets[key] = bucket
+	}
+	bucket.refill(now)
+
+	rate := float64(limit.Requests) / limit.Per.Seconds()
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		allowed = true
+	} else {
+		retryAfter = int(math.Ceil((1 - bucket.tokens) / rate))
+	}
+	remaining = int(bucket.tokens)
+	reset = int(math.Ceil((float64(limit.Requests) - bucket.tokens) / rate))
+	return allowed, remaining, reset, retryAfter
+}
+
+//sweep drops the buckets that have filled up again at most once a minute, a full bucket is the same as none
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	for key, bucket := range l.buckets {
+		bucket.refill(now)
+		if bucket.tokens >= float64(bucket.limit.Requests) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	elapsed := now.Sub(b.updated)
+	if elapsed <= 0 {
+		return
+	}
+	b.tokens = math.Min(float64(b.limit.Requests), b.tokens+elapsed.Seconds()*float64(b.limit.Requests)/b.limit.Per.Seconds())
+	b.updated = now
+}
+
+//clientKey identifies who made the request, authenticated callers by a hash of their api key so the key itself isn't kept
+func (l *RateLimiter) clientKey(r *http.Request) string {
+	if IsAuthenticated(r) {
+		sum := sha256.Sum256([]byte(r.Header.Get(APIKeyHeader)))
+		return "key:" + hex.EncodeToString(sum[:])
+	}
+	return "ip:" + l.clientIP(r)
+}
+
+//clientIP is the address the request came from. When that is a trusted proxy the addresses in X-Forwarded-For are read
+//from the right, the first one that isn't a trusted proxy being the client
+func (l *RateLimiter) clientIP(r *http.Request) string {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		ip = host
+	}
+	if !l.isTrustedProxy(ip) {
+		return ip
+	}
+
+	forwarded := []string{}
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		forwarded = append(forwarded, strings.Split(header, ",")...)
+	}
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(forwarded[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		ip = hop
+		if !l.isTrustedProxy(hop) {
+			break
+		}
+	}
+	return ip
+}
+
+func (l *RateLimiter) isTrustedProxy(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, proxy := range l.trustedProxies {
+		if proxy.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+//newRateLimitedRouter routes /articles and /articles/{id} through the rate limiter, with the limiter's clock at now
+func newRateLimitedRouter(limiter *RateLimiter, now *time.Time) *mux.Router {
+	limiter.now = func() time.Time { return *now }
+	router := mux.NewRouter()
+	router.Use(Authenticate("test-key"))
+	router.Use(limiter.Limit)
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	router.HandleFunc("/articles", ok).Methods("POST")
+	router.HandleFunc("/articles/{id}", ok).Methods("GET")
+	return router
+}
+
+func serveFrom(router http.Handler, method, path, remoteAddr string, headers map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, nil)
+	r.RemoteAddr = remoteAddr
+	for name, value := range headers {
+		r.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func TestRateLimiter(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("Given a client over its limit, 429 is returned until a request is refilled", func(t *testing.T) {
+		now := start
+		router := newRateLimitedRouter(NewRateLimiter(RateLimit{Requests: 2, Per: time.Minute}, nil, nil), &now)
+
+		first := serveFrom(router, "GET", "/articles/1", "192.0.2.1:1234", nil)
+		assert.Equal(t, 200, first.Result().StatusCode)
+		assert.Equal(t, "2", first.Result().Header.Get("RateLimit-Limit"))
+		assert.Equal(t, "1", first.Result().Header.Get("RateLimit-Remaining"))
+		assert.Equal(t, "30", first.Result().Header.Get("RateLimit-Reset"))
+		assert.Equal(t, "2;w=60", first.Result().Header.Get("RateLimit-Policy"))
+		assert.Equal(t, 200, serveFrom(router, "GET", "/articles/1", "192.0.2.1:1234", nil).Result().StatusCode)
+
+		limited := serveFrom(router, "GET", "/articles/1", "192.0.2.1:1234", nil)
+		assert.Equal(t, 429, limited.Result().StatusCode)
+		assert.Equal(t, "0", limited.Result().Header.Get("RateLimit-Remaining"))
+		assert.Equal(t, "30", limited.Result().Header.Get("Retry-After"))
+		assert.Equal(t, "application/json", limited.Result().Header.Get("Content-Type"))
+
+		assert.Equal(t, 200, serveFrom(router, "GET", "/articles/1", "192.0.2.2:1234", nil).Result().StatusCode, "another client has its own limit")
+
+		now = now.Add(30 * time.Second)
+		assert.Equal(t, 200, serveFrom(router, "GET", "/articles/1", "192.0.2.1:1234", nil).Result().StatusCode)
+	})
+	t.Run("Given a route with its own limit, it is counted apart from the default", func(t *testing.T) {
+		now := start
+		routeLimits := map[string]RateLimit{"POST /articles": {Requests: 1, Per: time.Minute}}
+		router := newRateLimitedRouter(NewRateLimiter(RateLimit{Requests: 5, Per: time.Minute}, routeLimits, nil), &now)
+
+		assert.Equal(t, 200, serveFrom(router, "POST", "/articles", "192.0.2.1:1234", nil).Result().StatusCode)
+		assert.Equal(t, 429, serveFrom(router, "POST", "/articles", "192.0.2.1:1234", nil).Result().StatusCode)
+
+		read := serveFrom(router, "GET", "/articles/1", "192.0.2.1:1234", nil)
+		assert.Equal(t, 200, read.Result().StatusCode)
+		assert.Equal(t, "4", read.Result().Header.Get("RateLimit-Remaining"))
+	})
+	t.Run("Given an authenticated client, it is limited by its api key rather than its address", func(t *testing.T) {
+		now := start
+		router := newRateLimitedRouter(NewRateLimiter(RateLimit{Requests: 1, Per: time.Minute}, nil, nil), &now)
+		withKey := map[string]string{APIKeyHeader: "test-key"}
+
+		assert.Equal(t, 200, serveFrom(router, "GET", "/articles/1", "192.0.2.1:1234", withKey).Result().StatusCode)
+		assert.Equal(t, 429, serveFrom(router, "GET", "/articles/1", "192.0.2.2:1234", withKey).Result().StatusCode)
+		assert.Equal(t, 200, serveFrom(router, "GET", "/articles/1", "192.0.2.1:1234", nil).Result().StatusCode)
+	})
+	t.Run("Given a limit of 0, requests aren't limited", func(t *testing.T) {
+		now := start
+		router := newRateLimitedRouter(NewRateLimiter(RateLimit{Requests: 0, Per: time.Minute}, nil, nil), &now)
+
+		w := serveFrom(router, "GET", "/articles/1", "192.0.2.1:1234", nil)
+		assert.Equal(t, 200, w.Result().StatusCode)
+		assert.Equal(t, "", w.Result().Header.Get("RateLimit-Limit"))
+	})
+}
+
+func TestRateLimiterClientIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.10"})
+	assert.NoError(t, err)
+	limiter := NewRateLimiter(RateLimit{}, nil, proxies)
+
+	clientIP := func(remoteAddr, forwardedFor string) string {
+		r := httptest.NewRequest("GET", "/articles/1", nil)
+		r.RemoteAddr = remoteAddr
+		if forwardedFor != "" {
+			r.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		return limiter.clientIP(r)
+	}
+
+	t.Run("Given a request from a trusted proxy, the rightmost untrusted forwarded address is the client", func(t *testing.T) {
+		assert.Equal(t, "203.0.113.7", clientIP("10.0.0.1:1234", "198.51.100.1, 203.0.113.7, 192.0.2.10"))
+	})
+	t.Run("Given a request from an untrusted address, X-Forwarded-For is ignored", func(t *testing.T) {
+		assert.Equal(t, "198.51.100.9", clientIP("198.51.100.9:1234", "203.0.113.7"))
+	})
+	t.Run("Given a forwarded address that isn't an address, the last good one is the client", func(t *testing.T) {
+		assert.Equal(t, "192.0.2.10", clientIP("10.0.0.1:1234", "junk, 192.0.2.10"))
+	})
+}
+
+func TestParseRateLimits(t *testing.T) {
+	t.Run("Given requests/period, the limit is parsed", func(t *testing.T) {
+		limit, err := ParseRateLimit("100/1m")
+		assert.NoError(t, err)
+		assert.Equal(t, RateLimit{Requests: 100, Per: time.Minute}, limit)
+	})
+	t.Run("Given an invalid limit, an error is returned", func(t *testing.T) {
+		for _, value := range []string{"100", "x/1m", "-1/1m", "100/0s", "100/soon"} {
+			_, err := ParseRateLimit(value)
+			assert.Error(t, err, value)
+		}
+	})
+	t.Run("Given route limits, each route's limit is parsed", func(t *testing.T) {
+		limits, err := ParseRouteRateLimits("post /articles=10/1m, GET /tags/{tagName}/{date}=100/1h")
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]RateLimit{
+			"POST /articles":             {Requests: 10, Per: time.Minute},
+			"GET /tags/{tagName}/{date}": {Requests: 100, Per: time.Hour},
+		}, limits)
+
+		_, err = ParseRouteRateLimits("/articles=10/1m")
+		assert.Error(t, err)
+	})
+}
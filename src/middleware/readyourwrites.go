@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+//ReadYourWritesCookie is set on the response to every write so the caller's reads for a while after go to the primary
+//database, where the write is already visible, rather than to a replica that may not have caught up
+const ReadYourWritesCookie = "read_your_writes"
+
+//ReadYourWritesHeader lets a caller ask for a read from the primary database without the cookie e.g. "X-Read-Your-Writes: true"
+const ReadYourWritesHeader = "X-Read-Your-Writes"
+
+const readYourWritesKey contextKey = "readYourWrites"
+
+//ReadYourWrites flags requests carrying the ReadYourWritesCookie or the ReadYourWritesHeader as needing to read their own
+//writes, and sets the cookie for window on the response to every request that isn't a GET or HEAD
+func ReadYourWrites(window time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if wantsOwnWrites(r) {
+				r = r.WithContext(context.WithValue(r.Context(), readYourWritesKey, true))
+			}
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				http.SetCookie(w, &http.Cookie{
+					Name:     ReadYourWritesCookie,
+					Value:    "1",
+					Path:     "/",
+					MaxAge:   int(window / time.Second),
+					HttpOnly: true,
+				})
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+//ReadsOwnWrites reports whether ReadYourWrites flagged the request as needing to read its own writes
+func ReadsOwnWrites(r *http.Request) bool {
+	readsOwnWrites, _ := r.Context().Value(readYourWritesKey).(bool)
+	return readsOwnWrites
+}
+
+func wantsOwnWrites(r *http.Request) bool {
+	if _, err := r.Cookie(ReadYourWritesCookie); err == nil {
+		return true
+	}
+	wants, _ := strconv.ParseBool(r.Header.Get(ReadYourWritesHeader))
+	return wants
+}
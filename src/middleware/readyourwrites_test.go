@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadYourWrites(t *testing.T) {
+	var readsOwnWrites bool
+	handler := ReadYourWrites(5 * time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		readsOwnWrites = ReadsOwnWrites(r)
+	}))
+
+	t.Run("Given a write, the cookie is set for the window", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("POST", "/articles", nil))
+
+		cookies := w.Result().Cookies()
+		assert.Equal(t, 1, len(cookies))
+		assert.Equal(t, ReadYourWritesCookie, cookies[0].Name)
+		assert.Equal(t, 5, cookies[0].MaxAge)
+		assert.False(t, readsOwnWrites)
+	})
+	t.Run("Given a read with the cookie, it reads its own writes", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/articles/1", nil)
+		r.AddCookie(&http.Cookie{Name: ReadYourWritesCookie, Value: "1"})
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		assert.True(t, readsOwnWrites)
+		assert.Equal(t, 0, len(w.Result().Cookies()))
+	})
+	t.Run("Given a read with the header, it reads its own writes", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/articles/1", nil)
+		r.Header.Set(ReadYourWritesHeader, "true")
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+
+		assert.True(t, readsOwnWrites)
+	})
+	t.Run("Given a read without either, it may read from a replica", func(t *testing.T) {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/articles/1", nil))
+
+		assert.False(t, readsOwnWrites)
+	})
+}
@@ -1,30 +1,60 @@
 package middleware
 
 import (
-	"encoding/json"
+	"encoding/xml"
 	"net/http"
+	"sort"
 	"strconv"
 )
 
-//Used to allow nice json response format for errors
+//Used to allow nice response formats for errors
 type CustomError struct{}
 
-var error = CustomError{}
+var apiError = CustomError{}
 
-func ModelResponse(w http.ResponseWriter, responseCode int, responseBody interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(responseCode)
-	json.NewEncoder(w).Encode(responseBody)
+//ModelResponse writes the body as JSON, XML or MessagePack depending on the request's Accept header
+func ModelResponse(w http.ResponseWriter, r *http.Request, responseCode int, responseBody interface{}) {
+	encodeResponse(w, r, responseCode, responseBody)
 	return
 }
 
-func (e CustomError) ApiError(w http.ResponseWriter, status int, message string) {
-	error := make(map[string]string)
+//ApiError writes the error in the content type negotiated from the request's Accept header, JSON when none can be
+func (e CustomError) ApiError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	e.ApiErrorFields(w, r, status, message, nil)
+}
+
+//ApiErrorFields writes the error with the fields added to it e.g. the ID of the article a request conflicts with
+func (e CustomError) ApiErrorFields(w http.ResponseWriter, r *http.Request, status int, message string, fields map[string]string) {
+	error := errorBody{}
 
+	for name, value := range fields {
+		error[name] = value
+	}
 	error["Message"] = message
 	error["Status"] = strconv.Itoa(status)
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(error)
+	encodeResponse(w, r, status, error)
+}
+
+//errorBody is the body of an error response. XML has no maps, so it is written as an error element with an element for
+//each field in name order
+type errorBody map[string]string
+
+func (e errorBody) MarshalXML(encoder *xml.Encoder, start xml.StartElement) error {
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	start.Name.Local = "error"
+	if err := encoder.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := encoder.EncodeElement(e[name], xml.StartElement{Name: xml.Name{Local: name}}); err != nil {
+			return err
+		}
+	}
+	return encoder.EncodeToken(start.End())
 }
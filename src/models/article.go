@@ -1,6 +1,21 @@
 package models
 
-import "time"
+import (
+	"encoding/xml"
+	"time"
+)
+
+//Article publication statuses
+const (
+	StatusDraft     = "draft"
+	StatusInReview  = "in_review"
+	StatusScheduled = "scheduled"
+	StatusPublished = "published"
+	StatusArchived  = "archived"
+)
+
+//DayFormat is the layout of an article's publication day e.g. 2016-09-22
+const DayFormat = "2006-01-02"
 
 type Article struct {
 	ID    string    `json:"id"`
@@ -8,26 +23,113 @@ type Article struct {
 	Date  time.Time `json:"date"`
 	Body  string    `json:"body"`
 	Tags  []string  `json:"tags"`
+
+	//Day is the publication day, the day Date falls on in the publication timezone in DayFormat. Looking articles up
+	//by date matches on it so an article's day doesn't depend on the timezone of whoever is asking
+	Day string `json:"day"`
+
+	Excerpt        string `json:"excerpt"`
+	WordCount      int    `json:"word_count"`
+	ReadingMinutes int    `json:"reading_minutes"`
+
+	//ContentHash identifies the article's normalized title and body, Simhash is a fingerprint of them that only differs in
+	//a few bits for articles that are nearly the same. Both are written on create and not read back with the article
+	ContentHash string `json:"-"`
+	Simhash     int64  `json:"-"`
+
+	Status      string     `json:"status"`
+	PublishAt   *time.Time `json:"publish_at,omitempty"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
 }
 
 type CreateArticleReq struct {
-	Title string   `json:"title"`
-	Date  string   `json:"date"`
-	Body  string   `json:"body"`
-	Tags  []string `json:"tags"`
+	XMLName xml.Name `json:"-" xml:"article"`
+	Title   string   `json:"title" xml:"title"`
+	Date    string   `json:"date" xml:"date"`
+	Body    string   `json:"body" xml:"body"`
+	Tags    []string `json:"tags" xml:"tags>tag"`
+	Status  string   `json:"status,omitempty" xml:"status,omitempty"`
+}
+
+//PublishArticleReq is the optional body of a publish request. A publish_at in the future schedules the article
+type PublishArticleReq struct {
+	XMLName   xml.Name `json:"-" xml:"publish"`
+	PublishAt string   `json:"publish_at,omitempty" xml:"publish_at,omitempty"`
 }
 
 type ArticleResp struct {
-	ID    string   `json:"id"`
-	Title string   `json:"title"`
-	Date  string   `json:"date"`
-	Body  string   `json:"body"`
-	Tags  []string `json:"tags"`
+	XMLName xml.Name `json:"-" xml:"article"`
+	ID      string   `json:"id" xml:"id"`
+	Title   string   `json:"title" xml:"title"`
+	Date    string   `json:"date" xml:"date"`
+	Body    string   `json:"body" xml:"body"`
+	Tags    []string `json:"tags" xml:"tags>tag"`
+
+	BodyHTML string `json:"body_html,omitempty" xml:"body_html,omitempty"`
+
+	Excerpt            string `json:"excerpt" xml:"excerpt"`
+	WordCount          int    `json:"word_count" xml:"word_count"`
+	ReadingTimeMinutes int    `json:"reading_time_minutes" xml:"reading_time_minutes"`
+
+	Status      string `json:"status,omitempty" xml:"status,omitempty"`
+	PublishAt   string `json:"publish_at,omitempty" xml:"publish_at,omitempty"`
+	PublishedAt string `json:"published_at,omitempty" xml:"published_at,omitempty"`
+	DeletedAt   string `json:"deleted_at,omitempty" xml:"deleted_at,omitempty"`
+
+	Warnings []ArticleWarning `json:"warnings,omitempty" xml:"warnings>warning,omitempty"`
+}
+
+//ArticleWarning is something the caller may want to know about an article it created, which didn't stop it being created
+type ArticleWarning struct {
+	Code       string  `json:"code" xml:"code"`
+	Message    string  `json:"message" xml:"message"`
+	ArticleID  string  `json:"article_id,omitempty" xml:"article_id,omitempty"`
+	Similarity float64 `json:"similarity,omitempty" xml:"similarity,omitempty"`
+}
+
+//WarningNearDuplicate warns that an article is nearly the same as another one
+const WarningNearDuplicate = "near_duplicate"
+
+//ArticleFingerprint is an article's Simhash, for comparing it with other articles
+type ArticleFingerprint struct {
+	ID      string
+	Title   string
+	Day     string
+	Simhash int64
 }
 
 type GroupArticleResp struct {
-	Tag         string   `json:"tag"`
-	Count       int      `json:"count"`
-	Articles    []string `json:"articles"`
-	RelatedTags []string `json:"related_tags"`
+	XMLName     xml.Name `json:"-" xml:"tag_articles"`
+	Tag         string   `json:"tag" xml:"tag"`
+	Count       int      `json:"count" xml:"count"`
+	Articles    []string `json:"articles" xml:"articles>id"`
+	RelatedTags []string `json:"related_tags" xml:"related_tags>tag"`
+}
+
+//ArticleFilter narrows the articles an export returns. Zero values don't filter. From and To are compared with the
+//articles' publication days, only the date in DayFormat counting
+type ArticleFilter struct {
+	Tag           string
+	From          *time.Time
+	To            *time.Time
+	PublishedOnly bool
+}
+
+//BulkItemResult is the outcome of one item of a bulk import. Index is the item's position in the request
+type BulkItemResult struct {
+	XMLName xml.Name `json:"-" xml:"result"`
+	Index   int      `json:"index" xml:"index"`
+	ID      string   `json:"id,omitempty" xml:"id,omitempty"`
+	Error   string   `json:"error,omitempty" xml:"error,omitempty"`
+
+	//DuplicateID is the article an item wasn't created for because it is identical to it
+	DuplicateID string `json:"duplicate_id,omitempty" xml:"duplicate_id,omitempty"`
+}
+
+type BulkImportResp struct {
+	XMLName xml.Name         `json:"-" xml:"bulk_import"`
+	Created int              `json:"created" xml:"created"`
+	Failed  int              `json:"failed" xml:"failed"`
+	Results []BulkItemResult `json:"results" xml:"results>result"`
 }
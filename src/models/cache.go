@@ -0,0 +1,15 @@
+package models
+
+import "encoding/xml"
+
+type CacheStatsResp struct {
+	XMLName    xml.Name `json:"-" xml:"cache"`
+	Enabled    bool     `json:"enabled" xml:"enabled"`
+	Hits       uint64   `json:"hits" xml:"hits"`
+	Misses     uint64   `json:"misses" xml:"misses"`
+	HitRatio   float64  `json:"hit_ratio" xml:"hit_ratio"`
+	Evictions  uint64   `json:"evictions" xml:"evictions"`
+	Entries    int      `json:"entries" xml:"entries"`
+	Size       int      `json:"size" xml:"size"`
+	TTLSeconds float64  `json:"ttl_seconds" xml:"ttl_seconds"`
+}
@@ -0,0 +1,10 @@
+package models
+
+//IdempotentResponse is what is stored for an idempotency key, the request it was first used with and the response to it.
+//StatusCode is 0 while the first request is still being handled
+type IdempotentResponse struct {
+	RequestHash string
+	StatusCode  int
+	ContentType string
+	Body        []byte
+}
@@ -0,0 +1,154 @@
+package models
+
+import (
+	"encoding/xml"
+	"strings"
+	"time"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+//Tag is a normalized tag. Slug identifies the tag, so "Health", "health " and "HEALTH" are all the same tag,
+//and Name is how it is displayed
+type Tag struct {
+	ID        int       `json:"id"`
+	Slug      string    `json:"slug"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+
+	//Parent is the slug of the tag this tag is below, empty for a top level tag
+	Parent string `json:"parent,omitempty"`
+	//Aliases are other slugs that mean this tag when written or asked for e.g. "ai" for "artificial-intelligence"
+	Aliases []string `json:"aliases"`
+
+	//ArticleCount and LastUsedAt only count the articles the caller can see
+	ArticleCount int        `json:"article_count"`
+	LastUsedAt   *time.Time `json:"last_used_at,omitempty"`
+}
+
+//Tag catalogue sort orders
+const (
+	TagSortUsage = "usage"
+	TagSortName  = "name"
+)
+
+type TagResp struct {
+	XMLName      xml.Name `json:"-" xml:"tag"`
+	Slug         string   `json:"slug" xml:"slug"`
+	Name         string   `json:"name" xml:"name"`
+	Parent       string   `json:"parent,omitempty" xml:"parent,omitempty"`
+	Aliases      []string `json:"aliases" xml:"aliases>alias"`
+	ArticleCount int      `json:"article_count" xml:"article_count"`
+	CreatedAt    string   `json:"created_at" xml:"created_at"`
+	LastUsedAt   string   `json:"last_used_at,omitempty" xml:"last_used_at,omitempty"`
+}
+
+type TagListResp struct {
+	XMLName xml.Name  `json:"-" xml:"tags"`
+	Total   int       `json:"total" xml:"total"`
+	Limit   int       `json:"limit" xml:"limit"`
+	Offset  int       `json:"offset" xml:"offset"`
+	Tags    []TagResp `json:"tags" xml:"tag"`
+}
+
+type TagSuggestionResp struct {
+	XMLName      xml.Name `json:"-" xml:"tag"`
+	Slug         string   `json:"slug" xml:"slug"`
+	Name         string   `json:"name" xml:"name"`
+	ArticleCount int      `json:"article_count" xml:"article_count"`
+}
+
+type TagSuggestionsResp struct {
+	XMLName xml.Name            `json:"-" xml:"suggestions"`
+	Prefix  string              `json:"prefix" xml:"prefix"`
+	Tags    []TagSuggestionResp `json:"tags" xml:"tag"`
+}
+
+//TagAliasReq adds an alias to a tag
+type TagAliasReq struct {
+	XMLName xml.Name `json:"-" xml:"alias"`
+	Alias   string   `json:"alias" xml:"alias"`
+}
+
+//TagParentReq sets the tag a tag is below. An empty Parent makes it a top level tag
+type TagParentReq struct {
+	XMLName xml.Name `json:"-" xml:"parent"`
+	Parent  string   `json:"parent" xml:"parent"`
+}
+
+//TrendingTag is a tag's usage in the latest window of days and the window before it
+type TrendingTag struct {
+	Slug          string
+	Name          string
+	CurrentCount  int
+	PreviousCount int
+}
+
+//RelatedTag is a tag that appears on the same articles as another. Count is how many articles carry both and
+//Jaccard is that count over the number of articles carrying either
+type RelatedTag struct {
+	Slug    string
+	Name    string
+	Count   int
+	Jaccard float64
+}
+
+type TrendingTagResp struct {
+	XMLName       xml.Name `json:"-" xml:"tag"`
+	Slug          string   `json:"slug" xml:"slug"`
+	Name          string   `json:"name" xml:"name"`
+	CurrentCount  int      `json:"current_count" xml:"current_count"`
+	PreviousCount int      `json:"previous_count" xml:"previous_count"`
+	Change        int      `json:"change" xml:"change"`
+}
+
+type TrendingTagsResp struct {
+	XMLName xml.Name          `json:"-" xml:"trending_tags"`
+	Days    int               `json:"days" xml:"days"`
+	Tags    []TrendingTagResp `json:"tags" xml:"tag"`
+}
+
+type RelatedTagResp struct {
+	XMLName xml.Name `json:"-" xml:"related_tag"`
+	Slug    string   `json:"slug" xml:"slug"`
+	Name    string   `json:"name" xml:"name"`
+	Count   int      `json:"count" xml:"count"`
+	Jaccard float64  `json:"jaccard" xml:"jaccard"`
+}
+
+type RelatedTagsResp struct {
+	XMLName xml.Name         `json:"-" xml:"related_tags"`
+	Tag     string           `json:"tag" xml:"tag"`
+	Days    int              `json:"days" xml:"days"`
+	Related []RelatedTagResp `json:"related" xml:"related_tag"`
+}
+
+//RenameTagReq renames a tag. Renaming to a name with another tag's slug is refused, those tags should be merged
+type RenameTagReq struct {
+	XMLName xml.Name `json:"-" xml:"rename"`
+	Name    string   `json:"name" xml:"name"`
+}
+
+//MergeTagReq moves every article off the tag in the path onto the Into tag and removes the merged tag
+type MergeTagReq struct {
+	XMLName xml.Name `json:"-" xml:"merge"`
+	Into    string   `json:"into" xml:"into"`
+}
+
+//NewTag normalizes a tag as written by a caller. The name is trimmed with inner whitespace collapsed and the slug is
+//the name unicode normalized, case folded and with spaces replaced by hyphens. An empty slug means there is no tag
+func NewTag(name string) Tag {
+	name = strings.Join(strings.Fields(norm.NFC.String(name)), " ")
+	return Tag{
+		Slug: TagSlug(name),
+		Name: name,
+	}
+}
+
+//TagSlug returns the slug a tag name is stored and looked up by. A cases.Caser keeps state while folding, so every call
+//makes its own rather than sharing one between the requests doing so at the same time
+func TagSlug(name string) string {
+	folded := cases.Fold().String(norm.NFKC.String(name))
+	return strings.Join(strings.Fields(folded), "-")
+}
@@ -1,7 +1,7 @@
 package services
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -17,6 +17,8 @@ import (
 
 var (
 	expectedDateFormatString = "2006-01-02"
+	//compactDateFormatString is the date format of the original spec, still accepted on the tag route
+	compactDateFormatString = "20060102"
 
 	apiError = middleware.CustomError{}
 )
@@ -26,6 +28,16 @@ var (
 type ArticleService struct {
 	DBClient database.DBClient
 	Logger   *logrus.Entry
+
+	//PublicURL is the address links to the API are built from e.g. in feeds. Taken from the request when empty
+	PublicURL string
+
+	//Location is the publication timezone. A request date is a day there and articles are looked up by the day they
+	//were published on there. UTC when nil
+	Location *time.Location
+
+	//IdempotencyTTL is how long the response to a create sent with an Idempotency-Key is kept for retries. 24h when 0
+	IdempotencyTTL time.Duration
 }
 
 //NewArticleService everything we need for the article functions
@@ -36,43 +48,87 @@ func NewArticleService(dbClient database.DBClient, logger *logrus.Entry) *Articl
 	}
 }
 
+//location returns the publication timezone
+func (a *ArticleService) location() *time.Location {
+	if a.Location == nil {
+		return time.UTC
+	}
+	return a.Location
+}
+
+//reader returns the client a read for the request goes through. Callers that just wrote read from the primary database
+//so they see their own writes, everyone else may be served by a replica
+func (a *ArticleService) reader(r *http.Request) database.DBClient {
+	if middleware.ReadsOwnWrites(r) {
+		return a.DBClient.Primary()
+	}
+	return a.DBClient
+}
+
 //CreateArticle gets the fields from the req and creates a new article in the DB
 func (a *ArticleService) CreateArticle(w http.ResponseWriter, r *http.Request) {
 	a.Logger.Infof("Inside CreateArticle function")
 
 	//parse json request
 	newReq := &models.CreateArticleReq{}
-	err := json.NewDecoder(r.Body).Decode(&newReq)
+	err := middleware.DecodeRequest(r, newReq)
 	if err != nil {
-		a.Logger.Errorf("CreateArticle :: Error decoding request: %v", err)
-		apiError.ApiError(w, http.StatusBadRequest, "Error decoding request")
+		a.decodeError(w, r, "CreateArticle", err)
 		return
 	}
 	a.Logger.Infof("CreateArticle :: Incoming create article request: %+v", newReq)
 
-	tDate, err := time.Parse(expectedDateFormatString, newReq.Date)
-	if err != nil {
-		a.Logger.Errorf("CreateArticle :: Error decoding request date: %v", err)
-		apiError.ApiError(w, http.StatusBadRequest, fmt.Sprintf("Request date is not expected format \"%s\"", expectedDateFormatString))
+	newArticle, status, message := a.validateCreateArticleReq(newReq, middleware.IsAuthenticated(r))
+	if newArticle == nil {
+		a.Logger.Errorf("CreateArticle :: Invalid request: %s", message)
+		apiError.ApiError(w, r, status, message)
 		return
 	}
 
-	//map request to db article object
-	newArticle := mapCreateArticleReqToDBArticle(newReq, tDate)
+	checkNearDuplicates := false
+	if value := r.FormValue("check_near_duplicates"); value != "" {
+		checkNearDuplicates, err = strconv.ParseBool(value)
+		if err != nil {
+			a.Logger.Warnf("CreateArticle :: check_near_duplicates is not a valid bool %s", value)
+			apiError.ApiError(w, r, http.StatusBadRequest, "check_near_duplicates query parameter is not valid")
+			return
+		}
+	}
 
-	//store in db
-	newID, err := a.DBClient.CreateArticleRow(newArticle.Title, newArticle.Body, newArticle.Date, newArticle.Tags)
+	//store in db unless the same article is already there for the day
+	var newID, duplicateID int
+	err = a.DBClient.WithTx(r.Context(), func(tx database.DBClient) error {
+		var err error
+		duplicateID, err = tx.GetArticleIDByContentHash(newArticle.ContentHash, newArticle.Day)
+		if err != nil || duplicateID != 0 {
+			return err
+		}
+		newID, err = tx.CreateArticleRow(newArticle)
+		return err
+	})
 	if err != nil {
 		a.Logger.Errorf("CreateArticle :: Error storing article %+v : %v", newArticle, err)
-		apiError.ApiError(w, http.StatusInternalServerError, "Internal server error storing article")
+		apiError.ApiError(w, r, http.StatusInternalServerError, "Internal server error storing article")
+		return
+	}
+	if duplicateID != 0 {
+		a.Logger.Warnf("CreateArticle :: Article is the same as article ID %d published %s", duplicateID, newArticle.Day)
+		apiError.ApiErrorFields(w, r, http.StatusConflict, "An identical article already exists for this date", map[string]string{"ID": strconv.Itoa(duplicateID)})
 		return
 	}
 
 	newArticle.ID = strconv.Itoa(newID)
 	a.Logger.Infof("CreateArticle :: Successfully created new article ID: %d", newID)
 
-	resp := mapToArticleResponse(newArticle)
-	middleware.ModelResponse(w, 201, resp)
+	resp := a.articleResponse(r, newArticle)
+	if checkNearDuplicates {
+		//the article is already stored, so failing to look for near duplicates only loses the warnings
+		resp.Warnings, err = a.nearDuplicateWarnings(a.DBClient.Primary(), newArticle)
+		if err != nil {
+			a.Logger.Errorf("CreateArticle :: Error looking for near duplicates of article ID %d : %v", newID, err)
+		}
+	}
+	middleware.ModelResponse(w, r, 201, resp)
 	return
 }
 
@@ -85,28 +141,131 @@ func (a *ArticleService) GetArticle(w http.ResponseWriter, r *http.Request) {
 	id, ok := vars["id"]
 	if !ok {
 		a.Logger.Warnf("GetArticle :: id is not present in the url path %s", r.URL.Path)
-		apiError.ApiError(w, http.StatusBadRequest, "id path parameter is not provided")
+		apiError.ApiError(w, r, http.StatusBadRequest, "id path parameter is not provided")
 		return
 	}
 	idInt, err := strconv.Atoi(id)
 	if err != nil {
 		a.Logger.Warnf("GetArticle :: id is not a valid integer %s", id)
-		apiError.ApiError(w, http.StatusBadRequest, "id path parameter is not valid")
+		apiError.ApiError(w, r, http.StatusBadRequest, "id path parameter is not valid")
 		return
 	}
 
 	//Check to see if it exists first
-	article, err := a.DBClient.GetArticleRowByID(idInt)
+	article, err := a.reader(r).GetArticleRowByID(idInt)
 	if err != nil {
 		a.Logger.Errorf("GetArticle :: Error getting article %d from DB : %v", idInt, err)
-		apiError.ApiError(w, http.StatusInternalServerError, "Internal server error getting article")
+		apiError.ApiError(w, r, http.StatusInternalServerError, "Internal server error getting article")
+		return
+	}
+
+	//anonymous callers only get to see published articles
+	if article == nil || (article.Status != models.StatusPublished && !middleware.IsAuthenticated(r)) {
+		a.Logger.Warnf("GetArticle :: article %d not found", idInt)
+		apiError.ApiError(w, r, http.StatusNotFound, "article not found")
 		return
 	}
 
 	a.Logger.Infof("GetArticle :: Successfully found article: %+v", article)
 
-	resp := mapToArticleResponse(article)
-	middleware.ModelResponse(w, 200, resp)
+	resp := a.articleResponse(r, article)
+
+	//bodies are stored as markdown, ?format=html adds a sanitized HTML rendering alongside it
+	switch format := r.FormValue("format"); format {
+	case "", "markdown":
+	case "html":
+		resp.BodyHTML, err = renderMarkdownHTML(article.Body)
+		if err != nil {
+			a.Logger.Errorf("GetArticle :: Error rendering article %d body : %v", idInt, err)
+			apiError.ApiError(w, r, http.StatusInternalServerError, "Internal server error rendering article")
+			return
+		}
+	default:
+		a.Logger.Warnf("GetArticle :: unsupported format %s", format)
+		apiError.ApiError(w, r, http.StatusBadRequest, "format query parameter must be \"markdown\" or \"html\"")
+		return
+	}
+	middleware.ModelResponse(w, r, 200, resp)
+	return
+}
+
+//DeleteArticle soft deletes the article that belongs to the ID provided in the path parameter
+func (a *ArticleService) DeleteArticle(w http.ResponseWriter, r *http.Request) {
+	a.Logger.Infof("Inside DeleteArticle function")
+
+	idInt, ok := a.articleIDFromPath(w, r, "DeleteArticle")
+	if !ok {
+		return
+	}
+
+	found, err := a.DBClient.DeleteArticleByID(idInt)
+	if err != nil {
+		a.Logger.Errorf("DeleteArticle :: Error deleting article %d from DB : %v", idInt, err)
+		apiError.ApiError(w, r, http.StatusInternalServerError, "Internal server error deleting article")
+		return
+	}
+	if !found {
+		a.Logger.Warnf("DeleteArticle :: article %d not found", idInt)
+		apiError.ApiError(w, r, http.StatusNotFound, "article not found")
+		return
+	}
+
+	a.Logger.Infof("DeleteArticle :: Successfully deleted article %d", idInt)
+	w.WriteHeader(http.StatusNoContent)
+	return
+}
+
+//GetDeletedArticles lists the soft deleted articles that are waiting to be purged
+func (a *ArticleService) GetDeletedArticles(w http.ResponseWriter, r *http.Request) {
+	a.Logger.Infof("Inside GetDeletedArticles function")
+
+	articles, err := a.reader(r).GetDeletedArticleRows()
+	if err != nil {
+		a.Logger.Errorf("GetDeletedArticles :: Error getting deleted articles from DB : %v", err)
+		apiError.ApiError(w, r, http.StatusInternalServerError, "Internal server error getting deleted articles")
+		return
+	}
+
+	resp := []*models.ArticleResp{}
+	for i := range *articles {
+		resp = append(resp, a.articleResponse(r, &(*articles)[i]))
+	}
+
+	a.Logger.Infof("GetDeletedArticles :: Successfully found %d deleted articles", len(resp))
+	middleware.ModelResponse(w, r, 200, resp)
+	return
+}
+
+//RestoreArticle undoes the soft delete of the article that belongs to the ID provided in the path parameter
+func (a *ArticleService) RestoreArticle(w http.ResponseWriter, r *http.Request) {
+	a.Logger.Infof("Inside RestoreArticle function")
+
+	idInt, ok := a.articleIDFromPath(w, r, "RestoreArticle")
+	if !ok {
+		return
+	}
+
+	restored, err := a.DBClient.RestoreArticleByID(idInt)
+	if err != nil {
+		a.Logger.Errorf("RestoreArticle :: Error restoring article %d : %v", idInt, err)
+		apiError.ApiError(w, r, http.StatusInternalServerError, "Internal server error restoring article")
+		return
+	}
+	if !restored {
+		a.Logger.Warnf("RestoreArticle :: deleted article %d not found", idInt)
+		apiError.ApiError(w, r, http.StatusNotFound, "deleted article not found")
+		return
+	}
+
+	article, err := a.DBClient.Primary().GetArticleRowByID(idInt)
+	if err != nil || article == nil {
+		a.Logger.Errorf("RestoreArticle :: Error getting restored article %d from DB : %v", idInt, err)
+		apiError.ApiError(w, r, http.StatusInternalServerError, "Internal server error getting article")
+		return
+	}
+
+	a.Logger.Infof("RestoreArticle :: Successfully restored article %d", idInt)
+	middleware.ModelResponse(w, r, 200, a.articleResponse(r, article))
 	return
 }
 
@@ -119,29 +278,40 @@ func (a *ArticleService) GetArticlesByTagAndDate(w http.ResponseWriter, r *http.
 	tagName, ok := vars["tagName"]
 	if !ok {
 		a.Logger.Warnf("GetArticlesByTagAndDate :: tagName is not present in the url path %s", r.URL.Path)
-		apiError.ApiError(w, http.StatusBadRequest, "tagName path parameter is not provided")
+		apiError.ApiError(w, r, http.StatusBadRequest, "tagName path parameter is not provided")
 		return
 	}
 	date, ok := vars["date"]
 	if !ok {
 		a.Logger.Warnf("GetArticlesByTagAndDate :: date is not present in the url path %s", r.URL.Path)
-		apiError.ApiError(w, http.StatusBadRequest, "date path parameter is not provided")
+		apiError.ApiError(w, r, http.StatusBadRequest, "date path parameter is not provided")
 		return
 	}
 
 	//Validate the date
-	_, err := time.Parse(expectedDateFormatString, date)
+	date, err := parseDay(date)
 	if err != nil {
-		a.Logger.Errorf("CreateArticle :: Error parsing param date: %v", err)
-		apiError.ApiError(w, http.StatusBadRequest, fmt.Sprintf("Path parameter date is not in expected format \"%s\"", expectedDateFormatString))
+		a.Logger.Errorf("GetArticlesByTagAndDate :: Error parsing param date: %v", err)
+		apiError.ApiError(w, r, http.StatusBadRequest, fmt.Sprintf("Path parameter date is not in expected format \"%s\" or \"%s\"", expectedDateFormatString, compactDateFormatString))
 		return
 	}
 
+	//?include_descendants=true also returns articles carrying the tags below this one e.g. physics for science
+	includeDescendants := false
+	if value := r.FormValue("include_descendants"); value != "" {
+		includeDescendants, err = strconv.ParseBool(value)
+		if err != nil {
+			a.Logger.Warnf("GetArticlesByTagAndDate :: include_descendants is not a valid boolean %s", value)
+			apiError.ApiError(w, r, http.StatusBadRequest, "include_descendants query parameter must be \"true\" or \"false\"")
+			return
+		}
+	}
+
 	//Check to see the tag and date have results
-	articles, err := a.DBClient.GetArticleRowByTagAndDate(tagName, date)
+	articles, err := a.reader(r).GetArticleRowByTagAndDate(tagName, date, !middleware.IsAuthenticated(r), includeDescendants)
 	if err != nil {
 		a.Logger.Errorf("GetArticlesByTagAndDate :: Error getting articles %s %s from DB : %v", tagName, date, err)
-		apiError.ApiError(w, http.StatusInternalServerError, "Internal server error getting article")
+		apiError.ApiError(w, r, http.StatusInternalServerError, "Internal server error getting article")
 		return
 	}
 
@@ -149,27 +319,157 @@ func (a *ArticleService) GetArticlesByTagAndDate(w http.ResponseWriter, r *http.
 	resp := mapToTagGroupArticleResp(*articles, tagName)
 
 	a.Logger.Infof("GetArticlesByTagAndDate :: Successfully found articles and mapped to response: %+v", resp)
-	middleware.ModelResponse(w, 200, resp)
+	middleware.ModelResponse(w, r, 200, resp)
 	return
 }
 
-func mapCreateArticleReqToDBArticle(req *models.CreateArticleReq, reqDate time.Time) *models.Article {
-	return &models.Article{
-		Title: req.Title,
-		Body:  req.Body,
-		Tags:  req.Tags,
-		Date:  reqDate,
+//articleIDFromPath reads and validates the id path parameter, writing a 400 if it is missing or invalid
+func (a *ArticleService) articleIDFromPath(w http.ResponseWriter, r *http.Request, funcName string) (int, bool) {
+	vars := mux.Vars(r)
+	id, ok := vars["id"]
+	if !ok {
+		a.Logger.Warnf("%s :: id is not present in the url path %s", funcName, r.URL.Path)
+		apiError.ApiError(w, r, http.StatusBadRequest, "id path parameter is not provided")
+		return 0, false
+	}
+	idInt, err := strconv.Atoi(id)
+	if err != nil {
+		a.Logger.Warnf("%s :: id is not a valid integer %s", funcName, id)
+		apiError.ApiError(w, r, http.StatusBadRequest, "id path parameter is not valid")
+		return 0, false
+	}
+	return idInt, true
+}
+
+//decodeError answers a request whose body couldn't be decoded with the status for why
+func (a *ArticleService) decodeError(w http.ResponseWriter, r *http.Request, funcName string, err error) {
+	switch {
+	case errors.Is(err, middleware.ErrUnsupportedMediaType):
+		a.Logger.Errorf("%s :: Unsupported request Content-Type: %s", funcName, r.Header.Get("Content-Type"))
+		apiError.ApiError(w, r, http.StatusUnsupportedMediaType, "Request Content-Type is not supported")
+	case errors.Is(err, middleware.ErrRequestTooLarge):
+		a.Logger.Warnf("%s :: Request body is too large", funcName)
+		apiError.ApiError(w, r, http.StatusRequestEntityTooLarge, "Request body is too large")
+	case errors.Is(err, middleware.ErrUnknownField):
+		a.Logger.Warnf("%s :: Request has an %v", funcName, err)
+		apiError.ApiError(w, r, http.StatusBadRequest, "Request has an "+err.Error())
+	case errors.Is(err, middleware.ErrTrailingData):
+		a.Logger.Warnf("%s :: Request body has data after the request", funcName)
+		apiError.ApiError(w, r, http.StatusBadRequest, "Request body has data after the request")
+	default:
+		a.Logger.Errorf("%s :: Error decoding request: %v", funcName, err)
+		apiError.ApiError(w, r, http.StatusBadRequest, "Error decoding request")
+	}
+}
+
+//parseDay reads a date path parameter as YYYY-MM-DD or YYYYMMDD, returning it in YYYY-MM-DD
+func parseDay(date string) (string, error) {
+	day, err := time.Parse(expectedDateFormatString, date)
+	if err != nil {
+		var compactErr error
+		day, compactErr = time.Parse(compactDateFormatString, date)
+		if compactErr != nil {
+			return "", err
+		}
+	}
+	return day.Format(models.DayFormat), nil
+}
+
+//parseArticleDate reads a request date, either a day in the publication timezone, the article being dated the start of
+//it, or an RFC 3339 timestamp keeping its time of day
+func (a *ArticleService) parseArticleDate(date string) (time.Time, error) {
+	tDate, err := time.ParseInLocation(expectedDateFormatString, date, a.location())
+	if err != nil {
+		var timestampErr error
+		tDate, timestampErr = time.Parse(time.RFC3339, date)
+		if timestampErr != nil {
+			return time.Time{}, err
+		}
+	}
+	return tDate, nil
+}
+
+//validateCreateArticleReq checks a create request and maps it to a db article.
+//When the request is invalid the article is nil and the status code and message to respond with are returned
+func (a *ArticleService) validateCreateArticleReq(req *models.CreateArticleReq, authenticated bool) (*models.Article, int, string) {
+	tDate, err := a.parseArticleDate(req.Date)
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Sprintf("Request date is not expected format \"%s\" or RFC 3339", expectedDateFormatString)
+	}
+
+	//new articles start as drafts unless an authenticated caller asks for another starting status
+	if req.Status == "" {
+		req.Status = models.StatusDraft
+	}
+	if !isCreateStatus(req.Status) {
+		return nil, http.StatusBadRequest, fmt.Sprintf("Request status must be one of \"%s\", \"%s\" or \"%s\"", models.StatusDraft, models.StatusInReview, models.StatusPublished)
+	}
+	if req.Status != models.StatusDraft && !authenticated {
+		return nil, http.StatusForbidden, "Only authenticated callers can create articles that are not drafts"
+	}
+
+	//map request to db article object
+	return mapCreateArticleReqToDBArticle(req, tDate, a.location()), 0, ""
+}
+
+//isCreateStatus reports whether an article can be created in the status
+func isCreateStatus(status string) bool {
+	return status == models.StatusDraft || status == models.StatusInReview || status == models.StatusPublished
+}
+
+//mapCreateArticleReqToDBArticle maps the request to a db article published on the day reqDate falls on in location
+func mapCreateArticleReqToDBArticle(req *models.CreateArticleReq, reqDate time.Time, location *time.Location) *models.Article {
+	article := &models.Article{
+		Title:  req.Title,
+		Body:   req.Body,
+		Tags:   normalizeTags(req.Tags),
+		Date:   reqDate,
+		Day:    reqDate.In(location).Format(models.DayFormat),
+		Status: req.Status,
+	}
+	if article.Status == models.StatusPublished {
+		now := time.Now().UTC()
+		article.PublishedAt = &now
+	}
+	summarizeArticle(article)
+	fingerprintArticle(article)
+	return article
+}
+
+//articleResponse maps the article to the response for the request. Its date is the publication day unless the caller
+//asked for full timestamps, then it is the time it is dated in the publication timezone
+func (a *ArticleService) articleResponse(r *http.Request, dbArticle *models.Article) *models.ArticleResp {
+	resp := mapToArticleResponse(dbArticle)
+	if middleware.AcceptsTimestamps(r) {
+		resp.Date = dbArticle.Date.In(a.location()).Format(time.RFC3339)
 	}
+	return resp
 }
 
 func mapToArticleResponse(dbArticle *models.Article) *models.ArticleResp {
-	return &models.ArticleResp{
+	resp := &models.ArticleResp{
 		ID:    dbArticle.ID,
 		Title: dbArticle.Title,
 		Body:  dbArticle.Body,
 		Tags:  dbArticle.Tags,
-		Date:  dbArticle.Date.Format(expectedDateFormatString),
+		Date:  dbArticle.Day,
+
+		Excerpt:            dbArticle.Excerpt,
+		WordCount:          dbArticle.WordCount,
+		ReadingTimeMinutes: dbArticle.ReadingMinutes,
+
+		Status: dbArticle.Status,
+	}
+	if dbArticle.PublishAt != nil {
+		resp.PublishAt = dbArticle.PublishAt.Format(time.RFC3339)
 	}
+	if dbArticle.PublishedAt != nil {
+		resp.PublishedAt = dbArticle.PublishedAt.Format(time.RFC3339)
+	}
+	if dbArticle.DeletedAt != nil {
+		resp.DeletedAt = dbArticle.DeletedAt.Format(time.RFC3339)
+	}
+	return resp
 }
 
 //mapToTagGroupArticleResp returns the desired response
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"io"
@@ -13,6 +14,7 @@ import (
 	"time"
 
 	"github.com/bmordt/article-api/src/database"
+	"github.com/bmordt/article-api/src/middleware"
 	"github.com/bmordt/article-api/src/models"
 
 	"github.com/gorilla/mux"
@@ -22,6 +24,15 @@ import (
 
 var (
 	testLogger = newTestLogger()
+
+	//missingTestID is an id the db mock treats as not existing
+	missingTestID = 404
+	//draftTestID is an id the db mock returns as a draft article
+	draftTestID = 7
+	//missingTestTag is a tag slug the db mock treats as not existing
+	missingTestTag = "missing"
+	//takenTestTag is a tag slug the db mock refuses to rename a tag to
+	takenTestTag = "taken"
 )
 
 func TestCreateArticle(t *testing.T) {
@@ -51,11 +62,142 @@ func TestCreateArticle(t *testing.T) {
 		})
 		t.Run("CreateArticleRow was Called once with the correct info", func(t *testing.T) {
 			assert.Equal(t, 1, len(dbMock.CreateArticleRowCalls()))
-			assert.Equal(t, testReq.Title, dbMock.CreateArticleRowCalls()[0].Title)
-			assert.Equal(t, expectedDateTime, dbMock.CreateArticleRowCalls()[0].Date)
-			assert.Equal(t, testReq.Body, dbMock.CreateArticleRowCalls()[0].Body)
-			assert.Equal(t, testReq.Tags, dbMock.CreateArticleRowCalls()[0].Tags)
+			assert.Equal(t, testReq.Title, dbMock.CreateArticleRowCalls()[0].Article.Title)
+			assert.Equal(t, expectedDateTime, dbMock.CreateArticleRowCalls()[0].Article.Date)
+			assert.Equal(t, testReq.Body, dbMock.CreateArticleRowCalls()[0].Article.Body)
+			assert.Equal(t, testReq.Tags, dbMock.CreateArticleRowCalls()[0].Article.Tags)
+		})
+		t.Run("The excerpt, word count and reading time are stored with the article", func(t *testing.T) {
+			stored := dbMock.CreateArticleRowCalls()[0].Article
+			assert.Equal(t, testReq.Body, stored.Excerpt)
+			assert.Equal(t, 12, stored.WordCount)
+			assert.Equal(t, 1, stored.ReadingMinutes)
+		})
+		t.Run("The article is created as a draft", func(t *testing.T) {
+			assert.Equal(t, models.StatusDraft, dbMock.CreateArticleRowCalls()[0].Article.Status)
+			assert.Nil(t, dbMock.CreateArticleRowCalls()[0].Article.PublishedAt)
 		})
+		t.Run("The publication day is stored with the article", func(t *testing.T) {
+			assert.Equal(t, dateString, dbMock.CreateArticleRowCalls()[0].Article.Day)
+		})
+	})
+	t.Run("Given a publication timezone, the date is the start of the day there", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+		location, err := time.LoadLocation("Pacific/Auckland")
+		assert.NoError(t, err)
+
+		a := NewArticleService(dbMock, testLogger)
+		a.Location = location
+
+		testReq := models.CreateArticleReq{
+			Title: "title",
+			Date:  "2016-09-22",
+			Body:  "body",
+			Tags:  []string{"health"},
+		}
+		w := httptest.NewRecorder()
+
+		a.CreateArticle(w, httptest.NewRequest("POST", "/articles", getBody(testReq)))
+
+		assert.Equal(t, 201, w.Result().StatusCode)
+		stored := dbMock.CreateArticleRowCalls()[0].Article
+		assert.True(t, time.Date(2016, 9, 22, 0, 0, 0, 0, location).Equal(stored.Date))
+		assert.Equal(t, "2016-09-22", stored.Day)
+	})
+	t.Run("Given an RFC 3339 date, the time of day is kept and the day is taken in the publication timezone", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+		location, err := time.LoadLocation("America/New_York")
+		assert.NoError(t, err)
+
+		a := NewArticleService(dbMock, testLogger)
+		a.Location = location
+
+		testReq := models.CreateArticleReq{
+			Title: "title",
+			Date:  "2016-09-23T02:30:00Z",
+			Body:  "body",
+			Tags:  []string{"health"},
+		}
+		w := httptest.NewRecorder()
+
+		a.CreateArticle(w, httptest.NewRequest("POST", "/articles", getBody(testReq)))
+
+		assert.Equal(t, 201, w.Result().StatusCode)
+		stored := dbMock.CreateArticleRowCalls()[0].Article
+		assert.True(t, time.Date(2016, 9, 23, 2, 30, 0, 0, time.UTC).Equal(stored.Date))
+		assert.Equal(t, "2016-09-22", stored.Day)
+	})
+	t.Run("Given an unauthenticated request to create a published article, 403 is returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		testReq := models.CreateArticleReq{
+			Title:  "title",
+			Date:   "2016-09-22",
+			Body:   "body",
+			Tags:   []string{"health"},
+			Status: models.StatusPublished,
+		}
+		testIncomingReq := httptest.NewRequest("POST", "/articles", getBody(testReq))
+		w := httptest.NewRecorder()
+
+		a.CreateArticle(w, testIncomingReq)
+
+		assert.Equal(t, 403, w.Result().StatusCode)
+		assert.Equal(t, 0, len(dbMock.CreateArticleRowCalls()))
+	})
+	t.Run("Given an authenticated request to create a published article, it is stored as published", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		testReq := models.CreateArticleReq{
+			Title:  "title",
+			Date:   "2016-09-22",
+			Body:   "body",
+			Tags:   []string{"health"},
+			Status: models.StatusPublished,
+		}
+		testIncomingReq := authenticatedRequest(httptest.NewRequest("POST", "/articles", getBody(testReq)))
+		w := httptest.NewRecorder()
+
+		a.CreateArticle(w, testIncomingReq)
+
+		assert.Equal(t, 201, w.Result().StatusCode)
+		assert.Equal(t, models.StatusPublished, dbMock.CreateArticleRowCalls()[0].Article.Status)
+		assert.NotNil(t, dbMock.CreateArticleRowCalls()[0].Article.PublishedAt)
+	})
+	t.Run("Given an XML create request, it is decoded from XML", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		body := "<article><title>title</title><date>2016-09-22</date><body>body</body><tags><tag>health</tag><tag>science</tag></tags></article>"
+		testIncomingReq := httptest.NewRequest("POST", "/articles", strings.NewReader(body))
+		testIncomingReq.Header.Set("Content-Type", "application/xml")
+		testIncomingReq.Header.Set("Accept", "application/xml")
+		w := httptest.NewRecorder()
+
+		a.CreateArticle(w, testIncomingReq)
+
+		assert.Equal(t, 201, w.Result().StatusCode)
+		assert.Equal(t, "application/xml", w.Result().Header.Get("Content-Type"))
+		assert.Equal(t, []string{"health", "science"}, dbMock.CreateArticleRowCalls()[0].Article.Tags)
+	})
+	t.Run("Given a create request with an unsupported Content-Type, 415 is returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		testIncomingReq := httptest.NewRequest("POST", "/articles", strings.NewReader("title=title"))
+		testIncomingReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+
+		a.CreateArticle(w, testIncomingReq)
+
+		assert.Equal(t, 415, w.Result().StatusCode)
+		assert.Equal(t, 0, len(dbMock.CreateArticleRowCalls()))
 	})
 	t.Run("Given an invalid create request, the correct resp is returned with 400", func(t *testing.T) {
 		dbMock := newDbClientMock(false, false, false)
@@ -85,7 +227,7 @@ func TestCreateArticle(t *testing.T) {
 			err := json.Unmarshal(w.Body.Bytes(), &actualResp)
 			assert.NoError(t, err)
 
-			assert.Equal(t, "Request date is not expected format \"2006-01-02\"", actualResp["Message"])
+			assert.Equal(t, "Request date is not expected format \"2006-01-02\" or RFC 3339", actualResp["Message"])
 		})
 		t.Run("CreateArticleRow was not Called", func(t *testing.T) {
 			assert.Equal(t, 0, len(dbMock.CreateArticleRowCalls()))
@@ -124,12 +266,54 @@ func TestCreateArticle(t *testing.T) {
 		})
 		t.Run("CreateArticleRow was Called once with the correct info", func(t *testing.T) {
 			assert.Equal(t, 1, len(dbMock.CreateArticleRowCalls()))
-			assert.Equal(t, testReq.Title, dbMock.CreateArticleRowCalls()[0].Title)
-			assert.Equal(t, expectedDateTime, dbMock.CreateArticleRowCalls()[0].Date)
-			assert.Equal(t, testReq.Body, dbMock.CreateArticleRowCalls()[0].Body)
-			assert.Equal(t, testReq.Tags, dbMock.CreateArticleRowCalls()[0].Tags)
+			assert.Equal(t, testReq.Title, dbMock.CreateArticleRowCalls()[0].Article.Title)
+			assert.Equal(t, expectedDateTime, dbMock.CreateArticleRowCalls()[0].Article.Date)
+			assert.Equal(t, testReq.Body, dbMock.CreateArticleRowCalls()[0].Article.Body)
+			assert.Equal(t, testReq.Tags, dbMock.CreateArticleRowCalls()[0].Article.Tags)
 		})
 	})
+	t.Run("Given a body over the size limit, 413 is returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		testIncomingReq := httptest.NewRequest("POST", "/articles", strings.NewReader(`{"title":"`+strings.Repeat("a", 100)+`"}`))
+		testIncomingReq.ContentLength = -1
+		w := httptest.NewRecorder()
+
+		middleware.NewBodyLimiter(64, nil).Limit(http.HandlerFunc(a.CreateArticle)).ServeHTTP(w, testIncomingReq)
+
+		assert.Equal(t, 413, w.Result().StatusCode)
+		assert.Equal(t, 0, len(dbMock.CreateArticleRowCalls()))
+	})
+	t.Run("Given data after the request, 400 is returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		w := httptest.NewRecorder()
+
+		a.CreateArticle(w, httptest.NewRequest("POST", "/articles", strings.NewReader(`{"title":"title","date":"2016-09-22"} trailing`)))
+
+		assert.Equal(t, 400, w.Result().StatusCode)
+		assert.Equal(t, 0, len(dbMock.CreateArticleRowCalls()))
+	})
+	t.Run("Given strict decoding and an unknown field, 400 is returned naming it", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		w := httptest.NewRecorder()
+
+		middleware.StrictDecoding(true)(http.HandlerFunc(a.CreateArticle)).ServeHTTP(w,
+			httptest.NewRequest("POST", "/articles", strings.NewReader(`{"titel":"title","date":"2016-09-22"}`)))
+
+		assert.Equal(t, 400, w.Result().StatusCode)
+		actualResp := make(map[string]string)
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &actualResp))
+		assert.Equal(t, `Request has an unknown field "titel"`, actualResp["Message"])
+		assert.Equal(t, 0, len(dbMock.CreateArticleRowCalls()))
+	})
 }
 
 func TestGetArticle(t *testing.T) {
@@ -224,6 +408,256 @@ func TestGetArticle(t *testing.T) {
 	})
 }
 
+func TestDeleteArticle(t *testing.T) {
+	testIDInt := 111111
+	t.Run("Given a valid delete request, the article is soft deleted and 204 returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		testIncomingReq := mux.SetURLVars(&http.Request{}, map[string]string{"id": strconv.Itoa(testIDInt)})
+		w := httptest.NewRecorder()
+
+		a.DeleteArticle(w, testIncomingReq)
+
+		assert.Equal(t, 204, w.Result().StatusCode)
+		assert.Equal(t, 1, len(dbMock.DeleteArticleByIDCalls()))
+		assert.Equal(t, testIDInt, dbMock.DeleteArticleByIDCalls()[0].ID)
+	})
+	t.Run("Given an id that does not exist, 404 is returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		testIncomingReq := mux.SetURLVars(&http.Request{}, map[string]string{"id": strconv.Itoa(missingTestID)})
+		w := httptest.NewRecorder()
+
+		a.DeleteArticle(w, testIncomingReq)
+
+		assert.Equal(t, 404, w.Result().StatusCode)
+	})
+	t.Run("Given an error during the delete we respond with 500", func(t *testing.T) {
+		dbMock := newDbClientMock(false, true, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		testIncomingReq := mux.SetURLVars(&http.Request{}, map[string]string{"id": strconv.Itoa(testIDInt)})
+		w := httptest.NewRecorder()
+
+		a.DeleteArticle(w, testIncomingReq)
+
+		assert.Equal(t, 500, w.Result().StatusCode)
+	})
+}
+
+func TestGetArticleVisibility(t *testing.T) {
+	t.Run("Given an unauthenticated request for a draft, 404 is returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		testIncomingReq := mux.SetURLVars(httptest.NewRequest("GET", "/articles/7", nil), map[string]string{"id": strconv.Itoa(draftTestID)})
+		w := httptest.NewRecorder()
+
+		a.GetArticle(w, testIncomingReq)
+
+		assert.Equal(t, 404, w.Result().StatusCode)
+	})
+	t.Run("Given an authenticated request for a draft, the draft is returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		testIncomingReq := mux.SetURLVars(authenticatedRequest(httptest.NewRequest("GET", "/articles/7", nil)), map[string]string{"id": strconv.Itoa(draftTestID)})
+		w := httptest.NewRecorder()
+
+		a.GetArticle(w, testIncomingReq)
+
+		assert.Equal(t, 200, w.Result().StatusCode)
+	})
+}
+
+func TestGetArticleReadYourWrites(t *testing.T) {
+	t.Run("Given a request from a caller that just wrote, the article is read from the primary", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		testIncomingReq := mux.SetURLVars(httptest.NewRequest("GET", "/articles/1", nil), map[string]string{"id": "1"})
+		testIncomingReq.AddCookie(&http.Cookie{Name: middleware.ReadYourWritesCookie, Value: "1"})
+		w := httptest.NewRecorder()
+
+		middleware.ReadYourWrites(time.Second)(http.HandlerFunc(a.GetArticle)).ServeHTTP(w, testIncomingReq)
+
+		assert.Equal(t, 200, w.Result().StatusCode)
+		assert.Equal(t, 1, len(dbMock.PrimaryCalls()))
+	})
+	t.Run("Given any other request, the article may be read from a replica", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		testIncomingReq := mux.SetURLVars(httptest.NewRequest("GET", "/articles/1", nil), map[string]string{"id": "1"})
+		w := httptest.NewRecorder()
+
+		middleware.ReadYourWrites(time.Second)(http.HandlerFunc(a.GetArticle)).ServeHTTP(w, testIncomingReq)
+
+		assert.Equal(t, 200, w.Result().StatusCode)
+		assert.Equal(t, 0, len(dbMock.PrimaryCalls()))
+	})
+}
+
+func TestGetArticleFormat(t *testing.T) {
+	t.Run("Given format=html, a sanitized HTML rendering of the body is returned alongside it", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+		dbMock.GetArticleRowByIDFunc = func(findID int) (*models.Article, error) {
+			return &models.Article{
+				ID:     "1",
+				Body:   "some **markup** <script>alert(1)</script>",
+				Status: models.StatusPublished,
+			}, nil
+		}
+
+		a := NewArticleService(dbMock, testLogger)
+
+		testIncomingReq := mux.SetURLVars(httptest.NewRequest("GET", "/articles/1?format=html", nil), map[string]string{"id": "1"})
+		w := httptest.NewRecorder()
+
+		a.GetArticle(w, testIncomingReq)
+
+		assert.Equal(t, 200, w.Result().StatusCode)
+		actualResp := &models.ArticleResp{}
+		err := json.Unmarshal(w.Body.Bytes(), actualResp)
+		assert.NoError(t, err)
+		assert.Equal(t, "some **markup** <script>alert(1)</script>", actualResp.Body)
+		assert.Equal(t, "<p>some <strong>markup</strong> <!-- raw HTML omitted -->alert(1)<!-- raw HTML omitted --></p>\n", actualResp.BodyHTML)
+	})
+	t.Run("Given Accept-Datetime rfc3339, the date is the full timestamp in the publication timezone", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+		dbMock.GetArticleRowByIDFunc = func(findID int) (*models.Article, error) {
+			return &models.Article{
+				ID:     "1",
+				Date:   time.Date(2016, 9, 23, 2, 30, 0, 0, time.UTC),
+				Day:    "2016-09-22",
+				Status: models.StatusPublished,
+			}, nil
+		}
+		location, err := time.LoadLocation("America/New_York")
+		assert.NoError(t, err)
+
+		a := NewArticleService(dbMock, testLogger)
+		a.Location = location
+
+		for accept, expected := range map[string]string{"": "2016-09-22", "RFC3339": "2016-09-22T22:30:00-04:00"} {
+			testIncomingReq := mux.SetURLVars(httptest.NewRequest("GET", "/articles/1", nil), map[string]string{"id": "1"})
+			testIncomingReq.Header.Set(middleware.AcceptDatetimeHeader, accept)
+			w := httptest.NewRecorder()
+
+			a.GetArticle(w, testIncomingReq)
+
+			assert.Equal(t, 200, w.Result().StatusCode)
+			actualResp := &models.ArticleResp{}
+			err := json.Unmarshal(w.Body.Bytes(), actualResp)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, actualResp.Date)
+		}
+	})
+	t.Run("Given an unknown format, 400 is returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		testIncomingReq := mux.SetURLVars(httptest.NewRequest("GET", "/articles/1?format=pdf", nil), map[string]string{"id": "1"})
+		w := httptest.NewRecorder()
+
+		a.GetArticle(w, testIncomingReq)
+
+		assert.Equal(t, 400, w.Result().StatusCode)
+	})
+}
+
+func TestGetArticleNotFound(t *testing.T) {
+	t.Run("Given an id that does not exist or was deleted, 404 is returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		testIncomingReq := mux.SetURLVars(&http.Request{}, map[string]string{"id": strconv.Itoa(missingTestID)})
+		w := httptest.NewRecorder()
+
+		a.GetArticle(w, testIncomingReq)
+
+		assert.Equal(t, 404, w.Result().StatusCode)
+	})
+}
+
+func TestGetDeletedArticles(t *testing.T) {
+	t.Run("Deleted articles are returned with their deleted_at time", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		w := httptest.NewRecorder()
+
+		a.GetDeletedArticles(w, &http.Request{})
+
+		assert.Equal(t, 200, w.Result().StatusCode)
+
+		actualResp := []models.ArticleResp{}
+		err := json.Unmarshal(w.Body.Bytes(), &actualResp)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(actualResp))
+		assert.Equal(t, "2022-01-02T03:04:05Z", actualResp[0].DeletedAt)
+	})
+}
+
+func TestRestoreArticle(t *testing.T) {
+	t.Run("Given a deleted id, the article is restored and returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		testIncomingReq := mux.SetURLVars(&http.Request{}, map[string]string{"id": "1"})
+		w := httptest.NewRecorder()
+
+		a.RestoreArticle(w, testIncomingReq)
+
+		assert.Equal(t, 200, w.Result().StatusCode)
+		assert.Equal(t, 1, len(dbMock.RestoreArticleByIDCalls()))
+		assert.Equal(t, 1, len(dbMock.GetArticleRowByIDCalls()))
+	})
+	t.Run("Given an id that is not deleted, 404 is returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		testIncomingReq := mux.SetURLVars(&http.Request{}, map[string]string{"id": strconv.Itoa(missingTestID)})
+		w := httptest.NewRecorder()
+
+		a.RestoreArticle(w, testIncomingReq)
+
+		assert.Equal(t, 404, w.Result().StatusCode)
+		assert.Equal(t, 0, len(dbMock.GetArticleRowByIDCalls()))
+	})
+}
+
+func TestPurgeDeletedArticles(t *testing.T) {
+	t.Run("The purge runs straight away with the retention applied and stops when told to", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		stop := make(chan struct{})
+		close(stop)
+		before := time.Now()
+		a.PurgeDeletedArticles(24*time.Hour, time.Hour, stop)
+
+		assert.Equal(t, 1, len(dbMock.PurgeDeletedArticleRowsCalls()))
+		deletedBefore := dbMock.PurgeDeletedArticleRowsCalls()[0].DeletedBefore
+		assert.WithinDuration(t, before.Add(-24*time.Hour), deletedBefore, time.Minute)
+	})
+}
+
 func TestGetArticlesByTagAndDate(t *testing.T) {
 	testTagName := "TestTag2"
 	testDate := "2022-01-01"
@@ -265,26 +699,110 @@ func TestGetArticlesByTagAndDate(t *testing.T) {
 			assert.Equal(t, testTagName, dbMock.GetArticleRowByTagAndDateCalls()[0].Tag)
 			assert.Equal(t, testDate, dbMock.GetArticleRowByTagAndDateCalls()[0].Date)
 		})
+		t.Run("Unauthenticated callers only see published articles", func(t *testing.T) {
+			assert.True(t, dbMock.GetArticleRowByTagAndDateCalls()[0].PublishedOnly)
+		})
+		t.Run("Articles with descendant tags are not included by default", func(t *testing.T) {
+			assert.False(t, dbMock.GetArticleRowByTagAndDateCalls()[0].IncludeDescendants)
+		})
+	})
+	t.Run("Given a YYYYMMDD date, the articles of that day are asked for", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		testIncomingReq := mux.SetURLVars(httptest.NewRequest("GET", "/tags/science/20220101", nil), map[string]string{"tagName": "science", "date": "20220101"})
+		w := httptest.NewRecorder()
+
+		a.GetArticlesByTagAndDate(w, testIncomingReq)
+
+		assert.Equal(t, 200, w.Result().StatusCode)
+		assert.Equal(t, testDate, dbMock.GetArticleRowByTagAndDateCalls()[0].Date)
+	})
+	t.Run("Given a date in neither format, 400 is returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		testIncomingReq := mux.SetURLVars(httptest.NewRequest("GET", "/tags/science/01-01-2022", nil), map[string]string{"tagName": "science", "date": "01-01-2022"})
+		w := httptest.NewRecorder()
+
+		a.GetArticlesByTagAndDate(w, testIncomingReq)
+
+		assert.Equal(t, 400, w.Result().StatusCode)
+		assert.Equal(t, 0, len(dbMock.GetArticleRowByTagAndDateCalls()))
+	})
+	t.Run("Given include_descendants, articles carrying tags below the tag are asked for", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		testIncomingReq := mux.SetURLVars(httptest.NewRequest("GET", "/tags/science/2022-01-01?include_descendants=true", nil), map[string]string{"tagName": "science", "date": testDate})
+		w := httptest.NewRecorder()
+
+		a.GetArticlesByTagAndDate(w, testIncomingReq)
+
+		assert.Equal(t, 200, w.Result().StatusCode)
+		assert.True(t, dbMock.GetArticleRowByTagAndDateCalls()[0].IncludeDescendants)
+	})
+	t.Run("Given an invalid include_descendants, 400 is returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		testIncomingReq := mux.SetURLVars(httptest.NewRequest("GET", "/tags/science/2022-01-01?include_descendants=maybe", nil), map[string]string{"tagName": "science", "date": testDate})
+		w := httptest.NewRecorder()
+
+		a.GetArticlesByTagAndDate(w, testIncomingReq)
+
+		assert.Equal(t, 400, w.Result().StatusCode)
+		assert.Equal(t, 0, len(dbMock.GetArticleRowByTagAndDateCalls()))
 	})
 }
 
 func newDbClientMock(createErr, getErr, getTagErr bool) *database.DBClientMock {
-	return &database.DBClientMock{
-		CreateArticleRowFunc: func(title, body string, date time.Time, tags []string) (int, error) {
+	dbMock := &database.DBClientMock{
+		CreateArticleRowFunc: func(article *models.Article) (int, error) {
 			if createErr {
 				return 0, errors.New("Create Error")
 			}
 			return 1, nil
 		},
+		CreateArticleRowsFunc: func(articles []*models.Article) ([]int, error) {
+			if createErr {
+				return nil, errors.New("Create Error")
+			}
+			ids := make([]int, len(articles))
+			for i := range articles {
+				ids[i] = i + 1
+			}
+			return ids, nil
+		},
+		GetArticleIDByContentHashFunc: func(contentHash, day string) (int, error) {
+			return 0, nil
+		},
+		GetArticleFingerprintRowsFunc: func(fromDay, toDay string) (*[]models.ArticleFingerprint, error) {
+			return &[]models.ArticleFingerprint{}, nil
+		},
 		GetArticleRowByIDFunc: func(findID int) (*models.Article, error) {
 			if getErr {
 				return &models.Article{}, errors.New("Get Error")
 			}
+			if findID == missingTestID {
+				return nil, nil
+			}
+			if findID == draftTestID {
+				return &models.Article{
+					ID:     strconv.Itoa(draftTestID),
+					Status: models.StatusDraft,
+				}, nil
+			}
 			return &models.Article{
-				ID: "1",
+				ID:     "1",
+				Status: models.StatusPublished,
 			}, nil
 		},
-		GetArticleRowByTagAndDateFunc: func(tag, date string) (*[]models.Article, error) {
+		GetArticleRowByTagAndDateFunc: func(tag, date string, publishedOnly, includeDescendants bool) (*[]models.Article, error) {
 			if getErr {
 				return &[]models.Article{}, errors.New("Get Error")
 			}
@@ -303,7 +821,176 @@ func newDbClientMock(createErr, getErr, getTagErr bool) *database.DBClientMock {
 				},
 			}, nil
 		},
+		ExportArticleRowsFunc: func(filter models.ArticleFilter, fn func(article *models.Article) error) error {
+			if getErr {
+				return errors.New("Export Error")
+			}
+			for i := 1; i <= 3; i++ {
+				err := fn(&models.Article{
+					ID:     strconv.Itoa(i),
+					Title:  "title, with comma",
+					Date:   time.Date(2022, 1, i, 0, 0, 0, 0, time.UTC),
+					Day:    "2022-01-0" + strconv.Itoa(i),
+					Body:   "body",
+					Tags:   []string{"TestTag1", "TestTag2"},
+					Status: models.StatusPublished,
+				})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		GetRecentArticleRowsFunc: func(tag string, limit int) (*[]models.Article, error) {
+			if getErr {
+				return &[]models.Article{}, errors.New("Get Error")
+			}
+			publishedAt := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+			return &[]models.Article{
+				models.Article{
+					ID:          "2",
+					Title:       "newest",
+					Date:        time.Date(2022, 1, 2, 0, 0, 0, 0, time.UTC),
+					Day:         "2022-01-02",
+					Body:        "",
+					Excerpt:     "a body that is long…",
+					Tags:        []string{"TestTag1"},
+					Status:      models.StatusPublished,
+					PublishedAt: &publishedAt,
+				},
+				models.Article{
+					ID:     "1",
+					Title:  "older",
+					Date:   time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+					Day:    "2022-01-01",
+					Body:   "body",
+					Tags:   []string{"TestTag1", "TestTag2"},
+					Status: models.StatusPublished,
+				},
+			}, nil
+		},
+		DeleteArticleByIDFunc: func(id int) (bool, error) {
+			if getErr {
+				return false, errors.New("Delete Error")
+			}
+			return id != missingTestID, nil
+		},
+		GetDeletedArticleRowsFunc: func() (*[]models.Article, error) {
+			if getErr {
+				return &[]models.Article{}, errors.New("Get Error")
+			}
+			deletedAt := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+			return &[]models.Article{
+				models.Article{
+					ID:        "1",
+					DeletedAt: &deletedAt,
+				},
+			}, nil
+		},
+		RestoreArticleByIDFunc: func(id int) (bool, error) {
+			if getErr {
+				return false, errors.New("Restore Error")
+			}
+			return id != missingTestID, nil
+		},
+		PurgeDeletedArticleRowsFunc: func(deletedBefore time.Time) (int64, error) {
+			return 2, nil
+		},
+		UpdateArticleStatusFunc: func(id int, fromStatus, toStatus string, publishAt *time.Time) (bool, error) {
+			if getErr {
+				return false, errors.New("Update Error")
+			}
+			return true, nil
+		},
+		PublishScheduledArticleRowsFunc: func(now time.Time) (int64, error) {
+			return 1, nil
+		},
+		GetTagRowsFunc: func(sortBy string, limit, offset int, publishedOnly bool) (*[]models.Tag, int, error) {
+			if getErr {
+				return nil, 0, errors.New("Get Error")
+			}
+			lastUsedAt := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+			return &[]models.Tag{
+				models.Tag{ID: 1, Slug: "health", Name: "Health", CreatedAt: lastUsedAt, ArticleCount: 3, LastUsedAt: &lastUsedAt},
+				models.Tag{ID: 2, Slug: "fitness", Name: "fitness", CreatedAt: lastUsedAt, ArticleCount: 1, LastUsedAt: &lastUsedAt},
+			}, 5, nil
+		},
+		GetTagRowBySlugFunc: func(slug string, publishedOnly bool) (*models.Tag, error) {
+			if getErr {
+				return nil, errors.New("Get Error")
+			}
+			if slug == missingTestTag {
+				return nil, nil
+			}
+			return &models.Tag{ID: 1, Slug: slug, Name: slug, ArticleCount: 2}, nil
+		},
+		GetTagSuggestionRowsFunc: func(prefix string, limit int, publishedOnly bool) (*[]models.Tag, error) {
+			if getErr {
+				return nil, errors.New("Get Error")
+			}
+			return &[]models.Tag{
+				models.Tag{Slug: prefix + "alth", Name: "Health", ArticleCount: 3},
+			}, nil
+		},
+		RenameTagRowFunc: func(slug, name string) (bool, error) {
+			if models.TagSlug(name) == takenTestTag {
+				return false, database.ErrTagExists
+			}
+			return slug != missingTestTag, nil
+		},
+		MergeTagRowsFunc: func(fromSlug, intoSlug string) (bool, error) {
+			return fromSlug != missingTestTag && intoSlug != missingTestTag, nil
+		},
+		AddTagAliasRowFunc: func(slug, alias string) (bool, error) {
+			if alias == takenTestTag {
+				return false, database.ErrTagExists
+			}
+			return true, nil
+		},
+		RemoveTagAliasRowFunc: func(slug, alias string) (bool, error) {
+			return alias != missingTestTag, nil
+		},
+		SetTagParentRowFunc: func(slug, parentSlug string) (bool, error) {
+			if parentSlug == slug {
+				return false, database.ErrTagCycle
+			}
+			return true, nil
+		},
+		GetTrendingTagRowsFunc: func(days, limit int, publishedOnly bool) (*[]models.TrendingTag, error) {
+			if getErr {
+				return nil, errors.New("Get Error")
+			}
+			return &[]models.TrendingTag{
+				models.TrendingTag{Slug: "health", Name: "Health", CurrentCount: 5, PreviousCount: 1},
+			}, nil
+		},
+		GetRelatedTagRowsFunc: func(slug string, days, limit int, publishedOnly bool) (*[]models.RelatedTag, error) {
+			if getErr {
+				return nil, errors.New("Get Error")
+			}
+			return &[]models.RelatedTag{
+				models.RelatedTag{Slug: "fitness", Name: "fitness", Count: 2, Jaccard: 0.5},
+			}, nil
+		},
+	}
+	//the primary and the transaction are the mock itself
+	dbMock.PrimaryFunc = func() database.DBClient {
+		return dbMock
+	}
+	dbMock.WithTxFunc = func(ctx context.Context, fn func(tx database.DBClient) error) error {
+		return fn(dbMock)
 	}
+	return dbMock
+}
+
+//authenticatedRequest runs the request through the authentication middleware with a valid api key
+func authenticatedRequest(r *http.Request) *http.Request {
+	r.Header.Set(middleware.APIKeyHeader, "test-key")
+	var authenticated *http.Request
+	middleware.Authenticate("test-key")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authenticated = r
+	})).ServeHTTP(httptest.NewRecorder(), r)
+	return authenticated
 }
 
 func newTestLogger() *logrus.Entry {
@@ -0,0 +1,319 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/bmordt/article-api/src/database"
+	"github.com/bmordt/article-api/src/middleware"
+	"github.com/bmordt/article-api/src/models"
+)
+
+var (
+	//bulkBatchSize is how many articles are handed to each CreateArticleRows call, and so how many are stored or fail together
+	//when an import is not atomic. How those are split into INSERT statements is up to the DBClient
+	bulkBatchSize = 500
+
+	ndjsonContentType = "application/x-ndjson"
+)
+
+//errBulkDuplicates rolls back an atomic import that has an article identical to one already stored
+var errBulkDuplicates = errors.New("bulk import has duplicate articles")
+
+//bulkItem is one decoded item of a bulk import, or the reason it could not be decoded
+type bulkItem struct {
+	req       *models.CreateArticleReq
+	decodeErr error
+}
+
+//BulkCreateArticles creates many articles from a JSON array or an NDJSON stream (Content-Type application/x-ndjson).
+//Every item is validated like a single create and gets its own result, and an item identical to an article already stored
+//for its day, or to an earlier item, isn't created. With ?atomic=true nothing is stored unless every item is valid and
+//stored, otherwise valid items are stored in batches and a failing batch only fails its own items
+func (a *ArticleService) BulkCreateArticles(w http.ResponseWriter, r *http.Request) {
+	a.Logger.Infof("Inside BulkCreateArticles function")
+
+	atomic := false
+	if value := r.URL.Query().Get("atomic"); value != "" {
+		var err error
+		atomic, err = strconv.ParseBool(value)
+		if err != nil {
+			a.Logger.Warnf("BulkCreateArticles :: atomic is not a valid bool %s", value)
+			apiError.ApiError(w, r, http.StatusBadRequest, "atomic query parameter is not valid")
+			return
+		}
+	}
+
+	var items []bulkItem
+	var err error
+	strict := middleware.DecodesStrictly(r)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == ndjsonContentType {
+		items, err = decodeNDJSONItems(r.Body, strict)
+	} else {
+		items, err = decodeJSONArrayItems(r.Body, strict)
+	}
+	if err != nil {
+		a.decodeError(w, r, "BulkCreateArticles", err)
+		return
+	}
+	if len(items) == 0 {
+		apiError.ApiError(w, r, http.StatusBadRequest, "Request contains no articles")
+		return
+	}
+	a.Logger.Infof("BulkCreateArticles :: Incoming bulk request with %d articles atomic %t", len(items), atomic)
+
+	//validate everything first so an atomic import can be rejected before anything is written
+	resp := &models.BulkImportResp{Results: make([]models.BulkItemResult, len(items))}
+	articles := []*models.Article{}
+	articleIndexes := []int{}
+	//the items already in the import by content hash and day, which the lookups in the DB can't see yet
+	firstItems := map[string]int{}
+	authenticated := middleware.IsAuthenticated(r)
+	for i, item := range items {
+		resp.Results[i].Index = i
+		if errors.Is(item.decodeErr, middleware.ErrUnknownField) {
+			resp.Results[i].Error = "Article has an " + item.decodeErr.Error()
+			continue
+		}
+		if item.decodeErr != nil {
+			resp.Results[i].Error = "Error decoding article"
+			continue
+		}
+		article, _, message := a.validateCreateArticleReq(item.req, authenticated)
+		if article == nil {
+			resp.Results[i].Error = message
+			continue
+		}
+		key := article.ContentHash + "|" + article.Day
+		if first, ok := firstItems[key]; ok {
+			resp.Results[i].Error = "Article is identical to item " + strconv.Itoa(first) + " of this import"
+			continue
+		}
+		firstItems[key] = i
+		articles = append(articles, article)
+		articleIndexes = append(articleIndexes, i)
+	}
+
+	if atomic && len(articles) != len(items) {
+		a.Logger.Warnf("BulkCreateArticles :: %d of %d articles are invalid, nothing stored", len(items)-len(articles), len(items))
+		countBulkResults(resp)
+		middleware.ModelResponse(w, r, http.StatusUnprocessableEntity, resp)
+		return
+	}
+
+	if atomic {
+		ids, duplicateIDs, err := a.createArticlesAtomically(r.Context(), articles)
+		if errors.Is(err, errBulkDuplicates) {
+			setBulkResults(resp, articleIndexes, nil, duplicateIDs)
+			countBulkResults(resp)
+			a.Logger.Warnf("BulkCreateArticles :: %d articles are already stored, nothing stored", resp.Failed)
+			middleware.ModelResponse(w, r, http.StatusConflict, resp)
+			return
+		}
+		if err != nil {
+			a.Logger.Errorf("BulkCreateArticles :: Error storing articles atomically : %v", err)
+			apiError.ApiError(w, r, http.StatusInternalServerError, "Internal server error storing articles")
+			return
+		}
+		setBulkResults(resp, articleIndexes, ids, duplicateIDs)
+	} else {
+		for start := 0; start < len(articles); start += bulkBatchSize {
+			end := start + bulkBatchSize
+			if end > len(articles) {
+				end = len(articles)
+			}
+
+			var ids, duplicateIDs []int
+			err := a.DBClient.WithTx(r.Context(), func(tx database.DBClient) error {
+				var err error
+				ids, duplicateIDs, err = createArticlesWithoutDuplicates(tx, articles[start:end])
+				return err
+			})
+			if err != nil {
+				a.Logger.Errorf("BulkCreateArticles :: Error storing articles %d to %d : %v", start, end, err)
+				for _, i := range articleIndexes[start:end] {
+					resp.Results[i].Error = "Internal server error storing article"
+				}
+				continue
+			}
+			setBulkResults(resp, articleIndexes[start:end], ids, duplicateIDs)
+		}
+	}
+
+	countBulkResults(resp)
+	a.Logger.Infof("BulkCreateArticles :: Created %d articles, %d failed", resp.Created, resp.Failed)
+
+	status := http.StatusCreated
+	if resp.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	if resp.Created == 0 {
+		status = http.StatusUnprocessableEntity
+	}
+	middleware.ModelResponse(w, r, status, resp)
+	return
+}
+
+//createArticlesAtomically stores the articles in batches inside one transaction, so either every article is stored or none are.
+//The new ids are returned in the same order as the articles. If any article is identical to one already stored nothing is
+//stored, errBulkDuplicates is returned and duplicateIDs has the id each article duplicates, 0 for the others
+func (a *ArticleService) createArticlesAtomically(ctx context.Context, articles []*models.Article) (ids, duplicateIDs []int, err error) {
+	err = a.DBClient.WithTx(ctx, func(tx database.DBClient) error {
+		//the transaction may be retried, so the ids of an earlier attempt are dropped
+		ids = make([]int, 0, len(articles))
+		duplicateIDs = make([]int, 0, len(articles))
+		duplicates := false
+		for start := 0; start < len(articles); start += bulkBatchSize {
+			end := start + bulkBatchSize
+			if end > len(articles) {
+				end = len(articles)
+			}
+
+			batchIDs, batchDuplicateIDs, err := createArticlesWithoutDuplicates(tx, articles[start:end])
+			if err != nil {
+				return err
+			}
+			for _, id := range batchDuplicateIDs {
+				duplicates = duplicates || id != 0
+			}
+			ids = append(ids, batchIDs...)
+			duplicateIDs = append(duplicateIDs, batchDuplicateIDs...)
+		}
+		if duplicates {
+			return errBulkDuplicates
+		}
+		return nil
+	})
+	return ids, duplicateIDs, err
+}
+
+//createArticlesWithoutDuplicates stores the articles in tx unless an identical article is already stored for their day,
+//the same check a single create makes. It returns the new id of each article stored and the id of the article each of
+//the others is identical to, 0 where there is none
+func createArticlesWithoutDuplicates(tx database.DBClient, articles []*models.Article) (ids, duplicateIDs []int, err error) {
+	ids = make([]int, len(articles))
+	duplicateIDs = make([]int, len(articles))
+	toStore := []*models.Article{}
+	toStoreIndexes := []int{}
+	for i, article := range articles {
+		duplicateIDs[i], err = tx.GetArticleIDByContentHash(article.ContentHash, article.Day)
+		if err != nil {
+			return nil, nil, err
+		}
+		if duplicateIDs[i] == 0 {
+			toStore = append(toStore, article)
+			toStoreIndexes = append(toStoreIndexes, i)
+		}
+	}
+	if len(toStore) == 0 {
+		return ids, duplicateIDs, nil
+	}
+
+	storedIDs, err := tx.CreateArticleRows(toStore)
+	if err != nil {
+		return nil, nil, err
+	}
+	for j, id := range storedIDs {
+		ids[toStoreIndexes[j]] = id
+	}
+	return ids, duplicateIDs, nil
+}
+
+//setBulkResults records the id each article was stored with, or the article it duplicates, on the result of its item.
+//ids can be nil when nothing was stored
+func setBulkResults(resp *models.BulkImportResp, articleIndexes, ids, duplicateIDs []int) {
+	for j, i := range articleIndexes {
+		if duplicateIDs[j] != 0 {
+			resp.Results[i].Error = "An identical article already exists for this date"
+			resp.Results[i].DuplicateID = strconv.Itoa(duplicateIDs[j])
+			continue
+		}
+		if ids != nil {
+			resp.Results[i].ID = strconv.Itoa(ids[j])
+		}
+	}
+}
+
+func countBulkResults(resp *models.BulkImportResp) {
+	resp.Created, resp.Failed = 0, 0
+	for _, result := range resp.Results {
+		if result.Error != "" {
+			resp.Failed++
+		} else if result.ID != "" {
+			resp.Created++
+		}
+	}
+}
+
+//decodeJSONArrayItems streams the elements of a JSON array. A malformed array or anything after it fails the whole request,
+//an element that is valid JSON but not an article only fails that item
+func decodeJSONArrayItems(body io.Reader, strict bool) ([]bulkItem, error) {
+	decoder := json.NewDecoder(body)
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return nil, errors.New("request body is not a JSON array")
+	}
+
+	items := []bulkItem{}
+	for decoder.More() {
+		var raw json.RawMessage
+		err = decoder.Decode(&raw)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, decodeBulkItem(raw, strict))
+	}
+
+	_, err = decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	_, err = decoder.Token()
+	if err == io.EOF {
+		return items, nil
+	}
+	if err != nil {
+		if _, ok := err.(*json.SyntaxError); !ok {
+			return nil, err
+		}
+	}
+	return nil, middleware.ErrTrailingData
+}
+
+//decodeNDJSONItems reads one article per line. Blank lines are skipped and a malformed line only fails that item
+func decodeNDJSONItems(body io.Reader, strict bool) ([]bulkItem, error) {
+	reader := bufio.NewReader(body)
+	items := []bulkItem{}
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			items = append(items, decodeBulkItem(line, strict))
+		}
+		if err == io.EOF {
+			return items, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func decodeBulkItem(raw []byte, strict bool) bulkItem {
+	req := &models.CreateArticleReq{}
+	err := middleware.DecodeJSON(bytes.NewReader(raw), req, strict)
+	if err != nil {
+		return bulkItem{decodeErr: err}
+	}
+	return bulkItem{req: req}
+}
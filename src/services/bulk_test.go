@@ -0,0 +1,240 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/bmordt/article-api/src/middleware"
+	"github.com/bmordt/article-api/src/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBulkCreateArticles(t *testing.T) {
+	validItem := `{"title":"title","date":"2016-09-22","body":"body","tags":["health"]}`
+	//identical items are duplicates, so imports of several valid items need them to differ
+	validItems := func(count int) string {
+		items := []string{}
+		for i := 0; i < count; i++ {
+			items = append(items, `{"title":"title `+strconv.Itoa(i)+`","date":"2016-09-22","body":"body","tags":["health"]}`)
+		}
+		return strings.Join(items, ",")
+	}
+	invalidItem := `{"title":"title","date":"22-09-2016","body":"body","tags":["health"]}`
+
+	t.Run("Given a JSON array of valid articles, they are all created", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		body := "[" + validItems(2) + "]"
+		w := httptest.NewRecorder()
+
+		a.BulkCreateArticles(w, httptest.NewRequest("POST", "/articles/bulk", strings.NewReader(body)))
+
+		assert.Equal(t, 201, w.Result().StatusCode)
+		actualResp := &models.BulkImportResp{}
+		err := json.Unmarshal(w.Body.Bytes(), actualResp)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, actualResp.Created)
+		assert.Equal(t, 0, actualResp.Failed)
+		assert.Equal(t, "1", actualResp.Results[0].ID)
+		assert.Equal(t, "2", actualResp.Results[1].ID)
+		assert.Equal(t, 1, len(dbMock.CreateArticleRowsCalls()))
+	})
+	t.Run("Given an NDJSON stream with an invalid item, the valid items are created and the invalid one reported", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		body := validItem + "\n" + invalidItem + "\n\n" + "not json\n" + strings.Replace(validItem, `"title"`, `"other title"`, 1)
+		req := httptest.NewRequest("POST", "/articles/bulk", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		w := httptest.NewRecorder()
+
+		a.BulkCreateArticles(w, req)
+
+		assert.Equal(t, 207, w.Result().StatusCode)
+		actualResp := &models.BulkImportResp{}
+		err := json.Unmarshal(w.Body.Bytes(), actualResp)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, actualResp.Created)
+		assert.Equal(t, 2, actualResp.Failed)
+		assert.Equal(t, 4, len(actualResp.Results))
+		assert.Equal(t, "1", actualResp.Results[0].ID)
+		assert.Equal(t, "Request date is not expected format \"2006-01-02\" or RFC 3339", actualResp.Results[1].Error)
+		assert.Equal(t, "Error decoding article", actualResp.Results[2].Error)
+		assert.Equal(t, 3, actualResp.Results[3].Index)
+		assert.Equal(t, "2", actualResp.Results[3].ID)
+	})
+	t.Run("Given atomic mode and an invalid item, nothing is created and 422 returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		body := "[" + validItem + "," + invalidItem + "]"
+		w := httptest.NewRecorder()
+
+		a.BulkCreateArticles(w, httptest.NewRequest("POST", "/articles/bulk?atomic=true", strings.NewReader(body)))
+
+		assert.Equal(t, 422, w.Result().StatusCode)
+		assert.Equal(t, 0, len(dbMock.CreateArticleRowsCalls()))
+	})
+	t.Run("Given atomic mode and an error storing, 500 is returned", func(t *testing.T) {
+		dbMock := newDbClientMock(true, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		body := "[" + validItems(2) + "]"
+		w := httptest.NewRecorder()
+
+		a.BulkCreateArticles(w, httptest.NewRequest("POST", "/articles/bulk?atomic=true", strings.NewReader(body)))
+
+		assert.Equal(t, 500, w.Result().StatusCode)
+		assert.Equal(t, 1, len(dbMock.CreateArticleRowsCalls()))
+	})
+	t.Run("Given more articles than a batch, they are stored one batch per transaction", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		defer func(size int) { bulkBatchSize = size }(bulkBatchSize)
+		bulkBatchSize = 2
+
+		body := "[" + validItems(5) + "]"
+		w := httptest.NewRecorder()
+
+		a.BulkCreateArticles(w, httptest.NewRequest("POST", "/articles/bulk", strings.NewReader(body)))
+
+		assert.Equal(t, 201, w.Result().StatusCode)
+		assert.Equal(t, 3, len(dbMock.WithTxCalls()))
+		assert.Equal(t, 3, len(dbMock.CreateArticleRowsCalls()))
+		assert.Equal(t, 1, len(dbMock.CreateArticleRowsCalls()[2].Articles))
+	})
+	t.Run("Given atomic mode and more articles than a batch, every batch is stored in one transaction", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		defer func(size int) { bulkBatchSize = size }(bulkBatchSize)
+		bulkBatchSize = 2
+
+		body := "[" + validItems(5) + "]"
+		w := httptest.NewRecorder()
+
+		a.BulkCreateArticles(w, httptest.NewRequest("POST", "/articles/bulk?atomic=true", strings.NewReader(body)))
+
+		assert.Equal(t, 201, w.Result().StatusCode)
+		assert.Equal(t, 1, len(dbMock.WithTxCalls()))
+		assert.Equal(t, 3, len(dbMock.CreateArticleRowsCalls()))
+	})
+	t.Run("Given an item identical to a stored article, it is reported with that article's ID and the rest are created", func(t *testing.T) {
+		stored := &models.Article{Title: "title 1", Body: "body"}
+		fingerprintArticle(stored)
+		dbMock := newDbClientMock(false, false, false)
+		dbMock.GetArticleIDByContentHashFunc = func(contentHash, day string) (int, error) {
+			if contentHash == stored.ContentHash {
+				return 42, nil
+			}
+			return 0, nil
+		}
+
+		a := NewArticleService(dbMock, testLogger)
+
+		body := "[" + validItems(2) + "]"
+		w := httptest.NewRecorder()
+
+		a.BulkCreateArticles(w, httptest.NewRequest("POST", "/articles/bulk", strings.NewReader(body)))
+
+		assert.Equal(t, 207, w.Result().StatusCode)
+		actualResp := &models.BulkImportResp{}
+		err := json.Unmarshal(w.Body.Bytes(), actualResp)
+		assert.NoError(t, err)
+		assert.Equal(t, "1", actualResp.Results[0].ID)
+		assert.Equal(t, "", actualResp.Results[1].ID)
+		assert.Equal(t, "42", actualResp.Results[1].DuplicateID)
+		assert.Equal(t, "An identical article already exists for this date", actualResp.Results[1].Error)
+		assert.Equal(t, 1, len(dbMock.CreateArticleRowsCalls()[0].Articles))
+	})
+	t.Run("Given atomic mode and an item identical to a stored article, nothing is stored and 409 returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+		dbMock.GetArticleIDByContentHashFunc = func(contentHash, day string) (int, error) {
+			return 42, nil
+		}
+
+		a := NewArticleService(dbMock, testLogger)
+
+		body := "[" + validItems(2) + "]"
+		w := httptest.NewRecorder()
+
+		a.BulkCreateArticles(w, httptest.NewRequest("POST", "/articles/bulk?atomic=true", strings.NewReader(body)))
+
+		assert.Equal(t, 409, w.Result().StatusCode)
+		actualResp := &models.BulkImportResp{}
+		err := json.Unmarshal(w.Body.Bytes(), actualResp)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, actualResp.Failed)
+		assert.Equal(t, "42", actualResp.Results[0].DuplicateID)
+		assert.Equal(t, 0, len(dbMock.CreateArticleRowsCalls()))
+	})
+	t.Run("Given two identical items, the second is reported and only the first created", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		body := "[" + validItem + "," + validItem + "]"
+		w := httptest.NewRecorder()
+
+		a.BulkCreateArticles(w, httptest.NewRequest("POST", "/articles/bulk", strings.NewReader(body)))
+
+		assert.Equal(t, 207, w.Result().StatusCode)
+		actualResp := &models.BulkImportResp{}
+		err := json.Unmarshal(w.Body.Bytes(), actualResp)
+		assert.NoError(t, err)
+		assert.Equal(t, "1", actualResp.Results[0].ID)
+		assert.Equal(t, "Article is identical to item 0 of this import", actualResp.Results[1].Error)
+		assert.Equal(t, 1, len(dbMock.CreateArticleRowsCalls()[0].Articles))
+	})
+	t.Run("Given a body that is not a JSON array, 400 is returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		w := httptest.NewRecorder()
+
+		a.BulkCreateArticles(w, httptest.NewRequest("POST", "/articles/bulk", strings.NewReader(validItem)))
+
+		assert.Equal(t, 400, w.Result().StatusCode)
+	})
+	t.Run("Given data after the JSON array, 400 is returned and nothing created", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		w := httptest.NewRecorder()
+
+		a.BulkCreateArticles(w, httptest.NewRequest("POST", "/articles/bulk", strings.NewReader("["+validItem+"]"+validItem)))
+
+		assert.Equal(t, 400, w.Result().StatusCode)
+		assert.Equal(t, 0, len(dbMock.CreateArticleRowsCalls()))
+	})
+	t.Run("Given strict decoding and an item with an unknown field, only that item fails", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		unknownFieldItem := `{"title":"title","date":"2016-09-22","body":"body","titel":"typo"}`
+		w := httptest.NewRecorder()
+		middleware.StrictDecoding(true)(http.HandlerFunc(a.BulkCreateArticles)).ServeHTTP(w,
+			httptest.NewRequest("POST", "/articles/bulk", strings.NewReader("["+validItem+","+unknownFieldItem+"]")))
+
+		assert.Equal(t, 207, w.Result().StatusCode)
+		resp := models.BulkImportResp{}
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		assert.Equal(t, `Article has an unknown field "titel"`, resp.Results[1].Error)
+	})
+}
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"net/http"
+
+	"github.com/bmordt/article-api/src/database"
+	"github.com/bmordt/article-api/src/middleware"
+	"github.com/bmordt/article-api/src/models"
+)
+
+//cacheStatsReporter is satisfied by a DBClient with a read cache in front of it
+type cacheStatsReporter interface {
+	Stats() database.CacheStats
+}
+
+//GetCacheStats returns the read cache's hit and miss counters, or that it is disabled
+func (a *ArticleService) GetCacheStats(w http.ResponseWriter, r *http.Request) {
+	a.Logger.Infof("Inside GetCacheStats function")
+
+	resp := &models.CacheStatsResp{}
+	if cache, ok := a.DBClient.(cacheStatsReporter); ok {
+		stats := cache.Stats()
+		resp = &models.CacheStatsResp{
+			Enabled:    true,
+			Hits:       stats.Hits,
+			Misses:     stats.Misses,
+			Evictions:  stats.Evictions,
+			Entries:    stats.Entries,
+			Size:       stats.Size,
+			TTLSeconds: stats.TTL.Seconds(),
+		}
+		if lookups := stats.Hits + stats.Misses; lookups > 0 {
+			resp.HitRatio = float64(stats.Hits) / float64(lookups)
+		}
+	}
+
+	a.Logger.Infof("GetCacheStats :: Successfully got cache stats: %+v", resp)
+	middleware.ModelResponse(w, r, 200, resp)
+	return
+}
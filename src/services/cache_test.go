@@ -0,0 +1,50 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bmordt/article-api/src/database"
+	"github.com/bmordt/article-api/src/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetCacheStats(t *testing.T) {
+	t.Run("Given a cached DB client, its hit and miss counters are returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+		cachedClient := database.NewCachingDBClient(dbMock, 10, time.Minute, testLogger)
+		cachedClient.GetArticleRowByID(1)
+		cachedClient.GetArticleRowByID(1)
+
+		a := NewArticleService(cachedClient, testLogger)
+
+		w := httptest.NewRecorder()
+		a.GetCacheStats(w, httptest.NewRequest("GET", "/admin/cache", nil))
+
+		assert.Equal(t, 200, w.Result().StatusCode)
+		resp := models.CacheStatsResp{}
+		json.NewDecoder(w.Body).Decode(&resp)
+		assert.True(t, resp.Enabled)
+		assert.Equal(t, uint64(1), resp.Hits)
+		assert.Equal(t, uint64(1), resp.Misses)
+		assert.Equal(t, 0.5, resp.HitRatio)
+		assert.Equal(t, 10, resp.Size)
+		assert.Equal(t, 60.0, resp.TTLSeconds)
+	})
+	t.Run("Given the cache is turned off, it is reported as disabled", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		w := httptest.NewRecorder()
+		a.GetCacheStats(w, httptest.NewRequest("GET", "/admin/cache", nil))
+
+		assert.Equal(t, 200, w.Result().StatusCode)
+		resp := models.CacheStatsResp{}
+		json.NewDecoder(w.Body).Decode(&resp)
+		assert.False(t, resp.Enabled)
+	})
+}
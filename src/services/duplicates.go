@@ -0,0 +1,117 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash/fnv"
+	"math/bits"
+	"sort"
+	"strings"
+
+	"github.com/bmordt/article-api/src/database"
+	"github.com/bmordt/article-api/src/models"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	//shingleSize is how many words each shingle a simhash is built from holds
+	shingleSize = 3
+
+	//nearDuplicateDistance is the most simhash bits two articles can differ by and still be near duplicates. Editing a
+	//word or two of a short article moves its simhash up to about 10 bits, unrelated articles are usually 20 or more apart
+	nearDuplicateDistance = 10
+
+	//nearDuplicateDays is how many days either side of an article's day are searched for near duplicates
+	nearDuplicateDays = 7
+
+	//maxNearDuplicateWarnings is the most near duplicates warned about, the most similar first
+	maxNearDuplicateWarnings = 5
+)
+
+//fingerprintArticle stores the content hash and simhash of the title and body with the article, so editors submitting the
+//same story twice can be caught
+func fingerprintArticle(article *models.Article) {
+	text := normalizeArticleText(article.Title, article.Body)
+
+	sum := sha256.Sum256([]byte(text))
+	article.ContentHash = hex.EncodeToString(sum[:])
+	article.Simhash = int64(simhash(strings.Fields(text)))
+}
+
+//normalizeArticleText joins the title and body in a form that doesn't change with case, unicode representation or
+//whitespace, so they don't make the same story look different
+func normalizeArticleText(title, body string) string {
+	text := cases.Fold().String(norm.NFKC.String(title + "\n" + body))
+	return strings.Join(strings.Fields(text), " ")
+}
+
+//simhash works out the 64 bit simhash of the words' shingles. Texts sharing most of their shingles get hashes differing
+//in only a few bits
+func simhash(words []string) uint64 {
+	if len(words) == 0 {
+		return 0
+	}
+
+	var weights [64]int
+	for start := 0; start == 0 || start+shingleSize <= len(words); start++ {
+		end := start + shingleSize
+		if end > len(words) {
+			end = len(words)
+		}
+		hash := fnv.New64a()
+		hash.Write([]byte(strings.Join(words[start:end], " ")))
+		sum := hash.Sum64()
+		for bit := range weights {
+			if sum&(1<<uint(bit)) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	var result uint64
+	for bit, weight := range weights {
+		if weight > 0 {
+			result |= 1 << uint(bit)
+		}
+	}
+	return result
+}
+
+//nearDuplicateWarnings warns about the articles published within nearDuplicateDays of the article whose simhash is
+//within nearDuplicateDistance bits of its own, the most similar first
+func (a *ArticleService) nearDuplicateWarnings(client database.DBClient, article *models.Article) ([]models.ArticleWarning, error) {
+	fromDay := article.Date.In(a.location()).AddDate(0, 0, -nearDuplicateDays).Format(models.DayFormat)
+	toDay := article.Date.In(a.location()).AddDate(0, 0, nearDuplicateDays).Format(models.DayFormat)
+	fingerprints, err := client.GetArticleFingerprintRows(fromDay, toDay)
+	if err != nil {
+		return nil, err
+	}
+
+	warnings := []models.ArticleWarning{}
+	for _, fingerprint := range *fingerprints {
+		if fingerprint.ID == article.ID {
+			continue
+		}
+		distance := bits.OnesCount64(uint64(fingerprint.Simhash ^ article.Simhash))
+		if distance > nearDuplicateDistance {
+			continue
+		}
+		warnings = append(warnings, models.ArticleWarning{
+			Code:       models.WarningNearDuplicate,
+			Message:    "Article is nearly the same as \"" + fingerprint.Title + "\" published " + fingerprint.Day,
+			ArticleID:  fingerprint.ID,
+			Similarity: 1 - float64(distance)/64,
+		})
+	}
+	sort.SliceStable(warnings, func(i, j int) bool {
+		return warnings[i].Similarity > warnings[j].Similarity
+	})
+	if len(warnings) > maxNearDuplicateWarnings {
+		warnings = warnings[:maxNearDuplicateWarnings]
+	}
+	return warnings, nil
+}
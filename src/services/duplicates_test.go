@@ -0,0 +1,129 @@
+package services
+
+import (
+	"encoding/json"
+	"math/bits"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bmordt/article-api/src/database"
+	"github.com/bmordt/article-api/src/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var duplicateTestBody = "Researchers at the university followed two thousand volunteers for a decade and found that those who " +
+	"walked for half an hour every day slept better, reported less stress and visited their doctor less often than those " +
+	"who did not. The team says the habit matters more than the pace, and that even a slow stroll after dinner counts."
+
+func TestFingerprintArticle(t *testing.T) {
+	t.Run("Given the same story with different case, spacing and unicode forms, the content hash is the same", func(t *testing.T) {
+		article := &models.Article{Title: "Café walks", Body: "Walking  every day\nhelps"}
+		sameStory := &models.Article{Title: "CAFÉ WALKS", Body: "walking every day helps "}
+		fingerprintArticle(article)
+		fingerprintArticle(sameStory)
+
+		assert.NotEmpty(t, article.ContentHash)
+		assert.Equal(t, article.ContentHash, sameStory.ContentHash)
+		assert.Equal(t, article.Simhash, sameStory.Simhash)
+	})
+	t.Run("Given a story with a word changed, the content hash differs but the simhash is close", func(t *testing.T) {
+		article := &models.Article{Title: "Daily walks", Body: duplicateTestBody}
+		edited := &models.Article{Title: "Daily walks", Body: strings.Replace(duplicateTestBody, "decade", "dozen years", 1)}
+		fingerprintArticle(article)
+		fingerprintArticle(edited)
+
+		assert.NotEqual(t, article.ContentHash, edited.ContentHash)
+		assert.LessOrEqual(t, bits.OnesCount64(uint64(article.Simhash^edited.Simhash)), nearDuplicateDistance)
+	})
+	t.Run("Given different stories, the simhashes are far apart", func(t *testing.T) {
+		article := &models.Article{Title: "Daily walks", Body: duplicateTestBody}
+		other := &models.Article{Title: "Markets", Body: "Shares fell sharply on Monday as investors worried that rising interest rates would slow growth across the region."}
+		fingerprintArticle(article)
+		fingerprintArticle(other)
+
+		assert.Greater(t, bits.OnesCount64(uint64(article.Simhash^other.Simhash)), nearDuplicateDistance)
+	})
+}
+
+func TestCreateArticleDuplicates(t *testing.T) {
+	testReq := models.CreateArticleReq{
+		Title: "Daily walks",
+		Date:  "2016-09-22",
+		Body:  duplicateTestBody,
+		Tags:  []string{"health"},
+	}
+
+	t.Run("Given an identical article for the date, 409 is returned with its ID", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+		dbMock.GetArticleIDByContentHashFunc = func(contentHash, day string) (int, error) {
+			return 7, nil
+		}
+		a := NewArticleService(dbMock, testLogger)
+
+		w := httptest.NewRecorder()
+		a.CreateArticle(w, httptest.NewRequest("POST", "/articles", getBody(testReq)))
+
+		assert.Equal(t, 409, w.Result().StatusCode)
+		body := map[string]string{}
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&body))
+		assert.Equal(t, "7", body["ID"])
+		assert.Equal(t, "2016-09-22", dbMock.GetArticleIDByContentHashCalls()[0].Day)
+		assert.Equal(t, 0, len(dbMock.CreateArticleRowCalls()))
+	})
+	t.Run("Given the same article on another date, it is created", func(t *testing.T) {
+		a := NewArticleService(database.NewMemoryDBClient(testLogger), testLogger)
+		a.CreateArticle(httptest.NewRecorder(), httptest.NewRequest("POST", "/articles", getBody(testReq)))
+
+		otherDay := testReq
+		otherDay.Date = "2016-09-23"
+		w := httptest.NewRecorder()
+		a.CreateArticle(w, httptest.NewRequest("POST", "/articles", getBody(otherDay)))
+
+		assert.Equal(t, 201, w.Result().StatusCode)
+	})
+	t.Run("Given near duplicate checks, an edited copy is created with a warning", func(t *testing.T) {
+		a := NewArticleService(database.NewMemoryDBClient(testLogger), testLogger)
+		first := httptest.NewRecorder()
+		a.CreateArticle(first, httptest.NewRequest("POST", "/articles", getBody(testReq)))
+		created := models.ArticleResp{}
+		assert.NoError(t, json.NewDecoder(first.Body).Decode(&created))
+
+		edited := testReq
+		edited.Date = "2016-09-24"
+		edited.Body = strings.Replace(duplicateTestBody, "decade", "dozen years", 1)
+		w := httptest.NewRecorder()
+		a.CreateArticle(w, httptest.NewRequest("POST", "/articles?check_near_duplicates=true", getBody(edited)))
+
+		assert.Equal(t, 201, w.Result().StatusCode)
+		resp := models.ArticleResp{}
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		if assert.Equal(t, 1, len(resp.Warnings)) {
+			assert.Equal(t, models.WarningNearDuplicate, resp.Warnings[0].Code)
+			assert.Equal(t, created.ID, resp.Warnings[0].ArticleID)
+			assert.GreaterOrEqual(t, resp.Warnings[0].Similarity, 1-float64(nearDuplicateDistance)/64)
+			assert.Less(t, resp.Warnings[0].Similarity, 1.0)
+		}
+	})
+	t.Run("Given no near duplicate checks, no warnings are looked for", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+		a := NewArticleService(dbMock, testLogger)
+
+		w := httptest.NewRecorder()
+		a.CreateArticle(w, httptest.NewRequest("POST", "/articles", getBody(testReq)))
+
+		assert.Equal(t, 201, w.Result().StatusCode)
+		assert.Equal(t, 0, len(dbMock.GetArticleFingerprintRowsCalls()))
+	})
+	t.Run("Given an invalid check_near_duplicates, 400 is returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+		a := NewArticleService(dbMock, testLogger)
+
+		w := httptest.NewRecorder()
+		a.CreateArticle(w, httptest.NewRequest("POST", "/articles?check_near_duplicates=maybe", getBody(testReq)))
+
+		assert.Equal(t, 400, w.Result().StatusCode)
+		assert.Equal(t, 0, len(dbMock.CreateArticleRowCalls()))
+	})
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bmordt/article-api/src/middleware"
+	"github.com/bmordt/article-api/src/models"
+)
+
+var (
+	csvContentType = "text/csv"
+
+	//exportFlushEvery is how many articles are written between flushes to the client
+	exportFlushEvery = 100
+
+	csvExportHeader = []string{"id", "title", "date", "body", "tags", "status", "published_at"}
+)
+
+//articleWriter writes a single exported article to the response
+type articleWriter func(article *models.Article) error
+
+//ExportArticles streams every article matching the tag, from and to query parameters as NDJSON or CSV, chosen by the Accept header.
+//Articles are written as they are read from the DB so the export never holds more than a page of articles in memory
+func (a *ArticleService) ExportArticles(w http.ResponseWriter, r *http.Request) {
+	a.Logger.Infof("Inside ExportArticles function")
+
+	contentType := negotiateExportType(r.Header.Get("Accept"))
+	if contentType == "" {
+		a.Logger.Warnf("ExportArticles :: unsupported Accept header %s", r.Header.Get("Accept"))
+		apiError.ApiError(w, r, http.StatusNotAcceptable, fmt.Sprintf("Export is only available as \"%s\" or \"%s\"", ndjsonContentType, csvContentType))
+		return
+	}
+
+	filter := models.ArticleFilter{
+		Tag:           r.URL.Query().Get("tag"),
+		PublishedOnly: !middleware.IsAuthenticated(r),
+	}
+	for param, target := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		value := r.URL.Query().Get(param)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(expectedDateFormatString, value)
+		if err != nil {
+			a.Logger.Warnf("ExportArticles :: %s is not a valid date %s", param, value)
+			apiError.ApiError(w, r, http.StatusBadRequest, fmt.Sprintf("Query parameter %s is not in expected format \"%s\"", param, expectedDateFormatString))
+			return
+		}
+		*target = &parsed
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+
+	var write articleWriter
+	var flushWriter func()
+	if contentType == csvContentType {
+		csvWriter := csv.NewWriter(w)
+		csvWriter.Write(csvExportHeader)
+		write = func(article *models.Article) error {
+			return csvWriter.Write(articleCSVRecord(a.articleResponse(r, article)))
+		}
+		flushWriter = csvWriter.Flush
+	} else {
+		encoder := json.NewEncoder(w)
+		write = func(article *models.Article) error {
+			return encoder.Encode(a.articleResponse(r, article))
+		}
+		flushWriter = func() {}
+	}
+
+	flusher, _ := w.(http.Flusher)
+	count := 0
+	err := a.reader(r).ExportArticleRows(filter, func(article *models.Article) error {
+		err := write(article)
+		if err != nil {
+			return err
+		}
+		count++
+		if count%exportFlushEvery == 0 {
+			flushWriter()
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		return nil
+	})
+	flushWriter()
+	if err != nil {
+		//the status has already been sent, so all that can be done is stop the stream short
+		a.Logger.Errorf("ExportArticles :: Error exporting articles after %d rows : %v", count, err)
+		return
+	}
+
+	a.Logger.Infof("ExportArticles :: Successfully exported %d articles as %s", count, contentType)
+	return
+}
+
+//exportMediaTypes maps every media type the export understands in Accept to the format it is written in
+var exportMediaTypes = map[string]string{
+	ndjsonContentType: ndjsonContentType,
+	"application/*":   ndjsonContentType,
+	"*/*":             ndjsonContentType,
+	csvContentType:    csvContentType,
+	"text/*":          csvContentType,
+}
+
+//negotiateExportType picks the export format from an Accept header, honouring q values and defaulting to NDJSON.
+//An empty result means none of the accepted types can be produced
+func negotiateExportType(accept string) string {
+	return middleware.NegotiateContentType(accept, exportMediaTypes)
+}
+
+func articleCSVRecord(article *models.ArticleResp) []string {
+	return []string{
+		article.ID,
+		article.Title,
+		article.Date,
+		article.Body,
+		strings.Join(article.Tags, ","),
+		article.Status,
+		article.PublishedAt,
+	}
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bmordt/article-api/src/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportArticles(t *testing.T) {
+	t.Run("Given no Accept header, articles are streamed as NDJSON", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		w := httptest.NewRecorder()
+
+		a.ExportArticles(w, httptest.NewRequest("GET", "/articles/export?tag=TestTag1&from=2022-01-01", nil))
+
+		assert.Equal(t, 200, w.Result().StatusCode)
+		assert.Equal(t, "application/x-ndjson", w.Result().Header.Get("Content-Type"))
+
+		lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+		assert.Equal(t, 3, len(lines))
+		article := &models.ArticleResp{}
+		err := json.Unmarshal([]byte(lines[2]), article)
+		assert.NoError(t, err)
+		assert.Equal(t, "3", article.ID)
+		assert.Equal(t, "2022-01-03", article.Date)
+
+		t.Run("The filter is passed to the DB", func(t *testing.T) {
+			filter := dbMock.ExportArticleRowsCalls()[0].Filter
+			assert.Equal(t, "TestTag1", filter.Tag)
+			assert.Equal(t, time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC), *filter.From)
+			assert.Nil(t, filter.To)
+			assert.True(t, filter.PublishedOnly)
+		})
+	})
+	t.Run("Given Accept text/csv, articles are streamed as CSV with a header row", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		req := httptest.NewRequest("GET", "/articles/export", nil)
+		req.Header.Set("Accept", "text/csv")
+		w := httptest.NewRecorder()
+
+		a.ExportArticles(w, req)
+
+		assert.Equal(t, 200, w.Result().StatusCode)
+		assert.Equal(t, "text/csv", w.Result().Header.Get("Content-Type"))
+
+		records, err := csv.NewReader(w.Body).ReadAll()
+		assert.NoError(t, err)
+		assert.Equal(t, 4, len(records))
+		assert.Equal(t, csvExportHeader, records[0])
+		assert.Equal(t, []string{"1", "title, with comma", "2022-01-01", "body", "TestTag1,TestTag2", "published", ""}, records[1])
+	})
+	t.Run("Given CSV refused with q=0, articles are streamed as NDJSON", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		req := httptest.NewRequest("GET", "/articles/export", nil)
+		req.Header.Set("Accept", "text/csv;q=0, application/x-ndjson")
+		w := httptest.NewRecorder()
+
+		a.ExportArticles(w, req)
+
+		assert.Equal(t, 200, w.Result().StatusCode)
+		assert.Equal(t, "application/x-ndjson", w.Result().Header.Get("Content-Type"))
+	})
+	t.Run("Given an Accept header that can't be produced, 406 is returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		req := httptest.NewRequest("GET", "/articles/export", nil)
+		req.Header.Set("Accept", "application/pdf")
+		w := httptest.NewRecorder()
+
+		a.ExportArticles(w, req)
+
+		assert.Equal(t, 406, w.Result().StatusCode)
+		assert.Equal(t, 0, len(dbMock.ExportArticleRowsCalls()))
+	})
+	t.Run("Given an invalid date filter, 400 is returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		w := httptest.NewRecorder()
+
+		a.ExportArticles(w, httptest.NewRequest("GET", "/articles/export?to=yesterday", nil))
+
+		assert.Equal(t, 400, w.Result().StatusCode)
+	})
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bmordt/article-api/src/models"
+
+	"github.com/gorilla/mux"
+)
+
+var (
+	//feedArticleLimit is how many of the most recent articles a feed carries
+	feedArticleLimit = 20
+
+	feedAuthor = "Articles API"
+)
+
+//feed is what the RSS and Atom documents are built from
+type feed struct {
+	title    string
+	selfPath string
+	articles []models.Article
+	updated  time.Time
+}
+
+//GetRSSFeed responds with an RSS 2.0 feed of the most recent published articles, for one tag when the path has a tagName
+func (a *ArticleService) GetRSSFeed(w http.ResponseWriter, r *http.Request) {
+	a.Logger.Infof("Inside GetRSSFeed function")
+
+	f, ok := a.loadFeed(w, r, "GetRSSFeed", "feed.rss")
+	if !ok {
+		return
+	}
+
+	baseURL := a.baseURL(r)
+	doc := models.RSSFeed{
+		Version: "2.0",
+		Channel: models.RSSChannel{
+			Title:         f.title,
+			Link:          baseURL + f.selfPath,
+			Description:   f.title,
+			LastBuildDate: f.updated.Format(time.RFC1123Z),
+		},
+	}
+	for _, article := range f.articles {
+		link := articleLink(baseURL, article.ID)
+		doc.Channel.Items = append(doc.Channel.Items, models.RSSItem{
+			Title:       article.Title,
+			Link:        link,
+			GUID:        models.RSSGUID{IsPermaLink: true, Value: link},
+			PubDate:     articlePublishedTime(&article).Format(time.RFC1123Z),
+			Description: article.Excerpt,
+			Categories:  article.Tags,
+		})
+	}
+
+	a.writeFeed(w, r, "GetRSSFeed", "application/rss+xml; charset=utf-8", doc, f.updated)
+}
+
+//GetAtomFeed responds with an Atom 1.0 feed of the most recent published articles, for one tag when the path has a tagName
+func (a *ArticleService) GetAtomFeed(w http.ResponseWriter, r *http.Request) {
+	a.Logger.Infof("Inside GetAtomFeed function")
+
+	f, ok := a.loadFeed(w, r, "GetAtomFeed", "feed.atom")
+	if !ok {
+		return
+	}
+
+	baseURL := a.baseURL(r)
+	doc := models.AtomFeed{
+		ID:      baseURL + f.selfPath,
+		Title:   f.title,
+		Updated: f.updated.Format(time.RFC3339),
+		Author:  models.AtomAuthor{Name: feedAuthor},
+		Links:   []models.AtomLink{{Href: baseURL + f.selfPath, Rel: "self"}},
+	}
+	for _, article := range f.articles {
+		link := articleLink(baseURL, article.ID)
+		published := articlePublishedTime(&article).Format(time.RFC3339)
+		entry := models.AtomEntry{
+			ID:        link,
+			Title:     article.Title,
+			Updated:   published,
+			Published: published,
+			Links:     []models.AtomLink{{Href: link, Rel: "alternate"}},
+			Summary:   article.Excerpt,
+		}
+		for _, tag := range article.Tags {
+			entry.Categories = append(entry.Categories, models.AtomCategory{Term: tag})
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+
+	a.writeFeed(w, r, "GetAtomFeed", "application/atom+xml; charset=utf-8", doc, f.updated)
+}
+
+//loadFeed reads the optional tagName path parameter and fetches the feed's articles, writing the error response on failure
+func (a *ArticleService) loadFeed(w http.ResponseWriter, r *http.Request, funcName, fileName string) (*feed, bool) {
+	tagName := mux.Vars(r)["tagName"]
+
+	articles, err := a.reader(r).GetRecentArticleRows(tagName, feedArticleLimit)
+	if err != nil {
+		a.Logger.Errorf("%s :: Error getting recent articles for tag %s from DB : %v", funcName, tagName, err)
+		apiError.ApiError(w, r, http.StatusInternalServerError, "Internal server error getting articles")
+		return nil, false
+	}
+
+	f := &feed{
+		title:    "Latest articles",
+		selfPath: "/" + fileName,
+		articles: *articles,
+		//an empty feed still needs a stable updated time so it can be cached
+		updated: time.Unix(0, 0).UTC(),
+	}
+	if tagName != "" {
+		f.title = "Latest articles tagged " + tagName
+		f.selfPath = "/tags/" + tagName + "/" + fileName
+	}
+	for i := range f.articles {
+		if published := articlePublishedTime(&f.articles[i]); published.After(f.updated) {
+			f.updated = published
+		}
+	}
+	return f, true
+}
+
+//writeFeed encodes the feed and serves it with an ETag and Last-Modified so readers can poll with conditional requests
+func (a *ArticleService) writeFeed(w http.ResponseWriter, r *http.Request, funcName, contentType string, doc interface{}, updated time.Time) {
+	buf := &bytes.Buffer{}
+	buf.WriteString(xml.Header)
+	err := xml.NewEncoder(buf).Encode(doc)
+	if err != nil {
+		a.Logger.Errorf("%s :: Error encoding feed : %v", funcName, err)
+		apiError.ApiError(w, r, http.StatusInternalServerError, "Internal server error building feed")
+		return
+	}
+
+	sum := sha1.Sum(buf.Bytes())
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+	w.Header().Set("Cache-Control", "public, max-age=300")
+
+	//ServeContent answers If-None-Match and If-Modified-Since with a 304
+	http.ServeContent(w, r, "", updated, bytes.NewReader(buf.Bytes()))
+}
+
+//baseURL is the public address links in feeds point at, taken from the request when it isn't configured
+func (a *ArticleService) baseURL(r *http.Request) string {
+	if a.PublicURL != "" {
+		return strings.TrimSuffix(a.PublicURL, "/")
+	}
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+func articleLink(baseURL, id string) string {
+	return baseURL + "/articles/" + id
+}
+
+//articlePublishedTime is when the article went live, falling back to its date for articles published before the workflow existed
+func articlePublishedTime(article *models.Article) time.Time {
+	if article.PublishedAt != nil {
+		return article.PublishedAt.UTC()
+	}
+	return article.Date.UTC()
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"encoding/xml"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bmordt/article-api/src/models"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRSSFeed(t *testing.T) {
+	t.Run("Given a tag, an RSS feed of its recent articles is returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+		a.PublicURL = "https://api.example.com/"
+
+		testIncomingReq := mux.SetURLVars(httptest.NewRequest("GET", "/tags/TestTag1/feed.rss", nil), map[string]string{"tagName": "TestTag1"})
+		w := httptest.NewRecorder()
+
+		a.GetRSSFeed(w, testIncomingReq)
+
+		resp := w.Result()
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, "application/rss+xml; charset=utf-8", resp.Header.Get("Content-Type"))
+		assert.Equal(t, "Sun, 02 Jan 2022 03:04:05 GMT", resp.Header.Get("Last-Modified"))
+		assert.NotEmpty(t, resp.Header.Get("ETag"))
+
+		doc := &models.RSSFeed{}
+		err := xml.Unmarshal(w.Body.Bytes(), doc)
+		assert.NoError(t, err)
+		assert.Equal(t, "2.0", doc.Version)
+		assert.Equal(t, "https://api.example.com/tags/TestTag1/feed.rss", doc.Channel.Link)
+		assert.Equal(t, 2, len(doc.Channel.Items))
+		assert.Equal(t, "https://api.example.com/articles/2", doc.Channel.Items[0].Link)
+		assert.Equal(t, "Sun, 02 Jan 2022 03:04:05 +0000", doc.Channel.Items[0].PubDate)
+		assert.Equal(t, "Sat, 01 Jan 2022 00:00:00 +0000", doc.Channel.Items[1].PubDate)
+		assert.Equal(t, "a body that is long…", doc.Channel.Items[0].Description)
+
+		t.Run("The feed was built from the tag's recent articles", func(t *testing.T) {
+			assert.Equal(t, "TestTag1", dbMock.GetRecentArticleRowsCalls()[0].Tag)
+			assert.Equal(t, feedArticleLimit, dbMock.GetRecentArticleRowsCalls()[0].Limit)
+		})
+	})
+	t.Run("Given the ETag of the current feed, 304 is returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		w := httptest.NewRecorder()
+		a.GetRSSFeed(w, httptest.NewRequest("GET", "/feed.rss", nil))
+		etag := w.Result().Header.Get("ETag")
+
+		testIncomingReq := httptest.NewRequest("GET", "/feed.rss", nil)
+		testIncomingReq.Header.Set("If-None-Match", etag)
+		w = httptest.NewRecorder()
+
+		a.GetRSSFeed(w, testIncomingReq)
+
+		assert.Equal(t, 304, w.Result().StatusCode)
+		assert.Equal(t, 0, w.Body.Len())
+	})
+	t.Run("Given an If-Modified-Since after the newest article, 304 is returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		testIncomingReq := httptest.NewRequest("GET", "/feed.rss", nil)
+		testIncomingReq.Header.Set("If-Modified-Since", "Mon, 03 Jan 2022 00:00:00 GMT")
+		w := httptest.NewRecorder()
+
+		a.GetRSSFeed(w, testIncomingReq)
+
+		assert.Equal(t, 304, w.Result().StatusCode)
+	})
+	t.Run("Given an error getting articles, 500 is returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, true, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		w := httptest.NewRecorder()
+
+		a.GetRSSFeed(w, httptest.NewRequest("GET", "/feed.rss", nil))
+
+		assert.Equal(t, 500, w.Result().StatusCode)
+	})
+}
+
+func TestGetAtomFeed(t *testing.T) {
+	t.Run("Without a tag, a site wide Atom feed is returned with links built from the request", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		w := httptest.NewRecorder()
+
+		a.GetAtomFeed(w, httptest.NewRequest("GET", "http://localhost:8080/feed.atom", nil))
+
+		assert.Equal(t, 200, w.Result().StatusCode)
+		assert.Equal(t, "application/atom+xml; charset=utf-8", w.Result().Header.Get("Content-Type"))
+
+		doc := &models.AtomFeed{}
+		err := xml.Unmarshal(w.Body.Bytes(), doc)
+		assert.NoError(t, err)
+		assert.Equal(t, "http://localhost:8080/feed.atom", doc.ID)
+		assert.Equal(t, "2022-01-02T03:04:05Z", doc.Updated)
+		assert.Equal(t, 2, len(doc.Entries))
+		assert.Equal(t, "http://localhost:8080/articles/1", doc.Entries[1].ID)
+		assert.Equal(t, 2, len(doc.Entries[1].Categories))
+		assert.Equal(t, "", dbMock.GetRecentArticleRowsCalls()[0].Tag)
+	})
+}
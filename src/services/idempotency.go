@@ -0,0 +1,147 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bmordt/article-api/src/middleware"
+	"github.com/bmordt/article-api/src/models"
+)
+
+const (
+	//idempotencyKeyHeader carries the key a client sends to make retrying a create safe
+	idempotencyKeyHeader = "Idempotency-Key"
+	//idempotentReplayedHeader is set on a stored response sent again for a retry
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	//maxIdempotencyKeyLength is the longest key accepted
+	maxIdempotencyKeyLength = 255
+	//defaultIdempotencyTTL is how long a key is kept when IdempotencyTTL is not set
+	defaultIdempotencyTTL = 24 * time.Hour
+)
+
+//idempotencyTTL returns how long an idempotency key and its response are kept
+func (a *ArticleService) idempotencyTTL() time.Duration {
+	if a.IdempotencyTTL <= 0 {
+		return defaultIdempotencyTTL
+	}
+	return a.IdempotencyTTL
+}
+
+//Idempotent makes retrying the handler safe for clients sending an Idempotency-Key header. The first response to a key is
+//stored with it for IdempotencyTTL and retries with the same key and request get that response back rather than running
+//the handler again. A key reused for a different request is a 422, and a retry while the first request is still being
+//handled is a 409. Server errors aren't stored so the retry runs the handler again. Requests without the header are
+//handled as they are
+func (a *ArticleService) Idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			a.Logger.Warnf("Idempotent :: key is longer than %d characters", maxIdempotencyKeyLength)
+			apiError.ApiError(w, r, http.StatusBadRequest, "Idempotency-Key header must be at most "+strconv.Itoa(maxIdempotencyKeyLength)+" characters")
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			a.decodeError(w, r, "Idempotent", err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		requestHash := idempotencyRequestHash(r, body)
+
+		now := time.Now().UTC()
+		stored, err := a.DBClient.ReserveIdempotencyKey(key, requestHash, now, now.Add(a.idempotencyTTL()))
+		if err != nil {
+			a.Logger.Errorf("Idempotent :: Error reserving key %s : %v", key, err)
+			apiError.ApiError(w, r, http.StatusInternalServerError, "Internal server error checking Idempotency-Key")
+			return
+		}
+		if stored != nil {
+			a.replayIdempotentResponse(w, r, key, requestHash, stored)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+		next(recorder, r)
+
+		if recorder.statusCode >= http.StatusInternalServerError {
+			err = a.DBClient.ReleaseIdempotencyKey(key)
+			if err != nil {
+				a.Logger.Errorf("Idempotent :: Error releasing key %s after a %d : %v", key, recorder.statusCode, err)
+			}
+			return
+		}
+		err = a.DBClient.SaveIdempotentResponse(key, &models.IdempotentResponse{
+			StatusCode:  recorder.statusCode,
+			ContentType: recorder.Header().Get("Content-Type"),
+			Body:        recorder.body.Bytes(),
+		})
+		if err != nil {
+			//the key stays reserved until it expires, retries get a 409 rather than creating again
+			a.Logger.Errorf("Idempotent :: Error saving the response of key %s : %v", key, err)
+		}
+	}
+}
+
+//replayIdempotentResponse answers a retry with what is stored for its key
+func (a *ArticleService) replayIdempotentResponse(w http.ResponseWriter, r *http.Request, key, requestHash string, stored *models.IdempotentResponse) {
+	switch {
+	case stored.RequestHash != requestHash:
+		a.Logger.Warnf("Idempotent :: key %s was used for a different request", key)
+		apiError.ApiError(w, r, http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request")
+	case stored.StatusCode == 0:
+		a.Logger.Warnf("Idempotent :: key %s is still being handled", key)
+		apiError.ApiError(w, r, http.StatusConflict, "A request with this Idempotency-Key is still being handled")
+	default:
+		a.Logger.Infof("Idempotent :: replaying the %d response of key %s", stored.StatusCode, key)
+		if stored.ContentType != "" {
+			w.Header().Set("Content-Type", stored.ContentType)
+		}
+		w.Header().Set(idempotentReplayedHeader, "true")
+		w.WriteHeader(stored.StatusCode)
+		w.Write(stored.Body)
+	}
+}
+
+//idempotencyRequestHash identifies the request a key is used with by everything that changes how it is handled
+func idempotencyRequestHash(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	for _, part := range []string{r.Method, r.URL.Path, r.URL.RawQuery, r.Header.Get("Content-Type"), strconv.FormatBool(middleware.IsAuthenticated(r))} {
+		io.WriteString(hash, part)
+		hash.Write([]byte{0})
+	}
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+//responseRecorder passes a response through to the client while keeping its status code and body
+type responseRecorder struct {
+	http.ResponseWriter
+
+	statusCode  int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(statusCode int) {
+	if !rec.wroteHeader {
+		rec.statusCode = statusCode
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
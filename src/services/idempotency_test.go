@@ -0,0 +1,121 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bmordt/article-api/src/database"
+	"github.com/bmordt/article-api/src/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//newIdempotentDbClientMock returns the db mock with its idempotency keys kept in memory
+func newIdempotentDbClientMock(createErr bool) *database.DBClientMock {
+	dbMock := newDbClientMock(createErr, false, false)
+	keys := database.NewMemoryDBClient(testLogger)
+	dbMock.ReserveIdempotencyKeyFunc = keys.ReserveIdempotencyKey
+	dbMock.SaveIdempotentResponseFunc = keys.SaveIdempotentResponse
+	dbMock.ReleaseIdempotencyKeyFunc = keys.ReleaseIdempotencyKey
+	return dbMock
+}
+
+func newIdempotentCreateRequest(key string, testReq models.CreateArticleReq) *http.Request {
+	r := httptest.NewRequest("POST", "/articles", getBody(testReq))
+	r.Header.Set(idempotencyKeyHeader, key)
+	return r
+}
+
+func TestIdempotent(t *testing.T) {
+	testReq := models.CreateArticleReq{
+		Title: "title",
+		Date:  "2016-09-22",
+		Body:  "body",
+		Tags:  []string{"health"},
+	}
+
+	t.Run("Given a retry with the same key and body, the stored response is returned without creating again", func(t *testing.T) {
+		dbMock := newIdempotentDbClientMock(false)
+		a := NewArticleService(dbMock, testLogger)
+		create := a.Idempotent(a.CreateArticle)
+
+		first := httptest.NewRecorder()
+		create(first, newIdempotentCreateRequest("key", testReq))
+		retry := httptest.NewRecorder()
+		create(retry, newIdempotentCreateRequest("key", testReq))
+
+		assert.Equal(t, 201, first.Result().StatusCode)
+		assert.Equal(t, 201, retry.Result().StatusCode)
+		assert.Equal(t, first.Body.String(), retry.Body.String())
+		assert.Equal(t, first.Result().Header.Get("Content-Type"), retry.Result().Header.Get("Content-Type"))
+		assert.Equal(t, "true", retry.Result().Header.Get(idempotentReplayedHeader))
+		assert.Equal(t, 1, len(dbMock.CreateArticleRowCalls()))
+	})
+	t.Run("Given the same key with a different body, 422 is returned", func(t *testing.T) {
+		dbMock := newIdempotentDbClientMock(false)
+		a := NewArticleService(dbMock, testLogger)
+		create := a.Idempotent(a.CreateArticle)
+
+		create(httptest.NewRecorder(), newIdempotentCreateRequest("key", testReq))
+		changed := testReq
+		changed.Title = "another title"
+		w := httptest.NewRecorder()
+		create(w, newIdempotentCreateRequest("key", changed))
+
+		assert.Equal(t, 422, w.Result().StatusCode)
+		assert.Equal(t, 1, len(dbMock.CreateArticleRowCalls()))
+	})
+	t.Run("Given a retry while the first request is still being handled, 409 is returned", func(t *testing.T) {
+		dbMock := newIdempotentDbClientMock(false)
+		a := NewArticleService(dbMock, testLogger)
+
+		var retry *httptest.ResponseRecorder
+		create := a.Idempotent(func(w http.ResponseWriter, r *http.Request) {
+			retry = httptest.NewRecorder()
+			a.Idempotent(a.CreateArticle)(retry, newIdempotentCreateRequest("key", testReq))
+			a.CreateArticle(w, r)
+		})
+		create(httptest.NewRecorder(), newIdempotentCreateRequest("key", testReq))
+
+		assert.Equal(t, 409, retry.Result().StatusCode)
+		assert.Equal(t, 1, len(dbMock.CreateArticleRowCalls()))
+	})
+	t.Run("Given a server error, the key is released so a retry creates the article", func(t *testing.T) {
+		dbMock := newIdempotentDbClientMock(true)
+		a := NewArticleService(dbMock, testLogger)
+		create := a.Idempotent(a.CreateArticle)
+
+		w := httptest.NewRecorder()
+		create(w, newIdempotentCreateRequest("key", testReq))
+		assert.Equal(t, 500, w.Result().StatusCode)
+
+		w = httptest.NewRecorder()
+		create(w, newIdempotentCreateRequest("key", testReq))
+		assert.Equal(t, 500, w.Result().StatusCode)
+		assert.Equal(t, 2, len(dbMock.CreateArticleRowCalls()))
+		assert.Equal(t, 2, len(dbMock.ReleaseIdempotencyKeyCalls()))
+	})
+	t.Run("Given no key, every request creates an article", func(t *testing.T) {
+		dbMock := newIdempotentDbClientMock(false)
+		a := NewArticleService(dbMock, testLogger)
+		create := a.Idempotent(a.CreateArticle)
+
+		create(httptest.NewRecorder(), httptest.NewRequest("POST", "/articles", getBody(testReq)))
+		create(httptest.NewRecorder(), httptest.NewRequest("POST", "/articles", getBody(testReq)))
+
+		assert.Equal(t, 2, len(dbMock.CreateArticleRowCalls()))
+		assert.Equal(t, 0, len(dbMock.ReserveIdempotencyKeyCalls()))
+	})
+	t.Run("Given a key that is too long, 400 is returned", func(t *testing.T) {
+		dbMock := newIdempotentDbClientMock(false)
+		a := NewArticleService(dbMock, testLogger)
+
+		w := httptest.NewRecorder()
+		a.Idempotent(a.CreateArticle)(w, newIdempotentCreateRequest(strings.Repeat("k", maxIdempotencyKeyLength+1), testReq))
+
+		assert.Equal(t, 400, w.Result().StatusCode)
+		assert.Equal(t, 0, len(dbMock.CreateArticleRowCalls()))
+	})
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+var (
+	//safeURLSchemes are the only schemes a rendered link or image may use. Relative urls are always allowed
+	safeURLSchemes = map[string]bool{"http": true, "https": true, "mailto": true}
+
+	//linkRel is added to every rendered link so user content can't pass on page rank or get a handle on our window
+	linkRel = []byte("nofollow noopener noreferrer")
+
+	//markdownRenderer renders article bodies. Raw HTML in the markdown is dropped because the unsafe option is never turned on
+	markdownRenderer = goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		goldmark.WithParserOptions(
+			parser.WithASTTransformers(util.Prioritized(safeLinkTransformer{}, 1000)),
+		),
+	)
+)
+
+//renderMarkdownHTML renders a markdown body to HTML that is safe to embed in a page: no raw HTML, no script urls
+//and links marked nofollow
+func renderMarkdownHTML(body string) (string, error) {
+	var buf bytes.Buffer
+	err := markdownRenderer.Convert([]byte(body), &buf)
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+//renderMarkdownText strips the markup from a markdown body leaving the readable text, one line per block.
+//It is what excerpts and searches work from
+func renderMarkdownText(body string) string {
+	source := []byte(body)
+	doc := markdownRenderer.Parser().Parse(text.NewReader(source))
+
+	var buf strings.Builder
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			if n.Type() == ast.TypeBlock && buf.Len() > 0 && !strings.HasSuffix(buf.String(), "\n") {
+				buf.WriteString("\n")
+			}
+			return ast.WalkContinue, nil
+		}
+
+		switch node := n.(type) {
+		case *ast.Text:
+			buf.Write(node.Segment.Value(source))
+			if node.SoftLineBreak() || node.HardLineBreak() {
+				buf.WriteString(" ")
+			}
+		case *ast.String:
+			buf.Write(node.Value)
+		case *ast.AutoLink:
+			buf.Write(node.Label(source))
+		case *ast.CodeBlock, *ast.FencedCodeBlock:
+			lines := n.Lines()
+			for i := 0; i < lines.Len(); i++ {
+				line := lines.At(i)
+				buf.Write(line.Value(source))
+			}
+			return ast.WalkSkipChildren, nil
+		case *ast.HTMLBlock, *ast.RawHTML:
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+
+	return strings.TrimSpace(buf.String())
+}
+
+//safeLinkTransformer unwraps links and images whose url has a scheme that isn't allowed, keeping their text,
+//and adds rel attributes to the links that are left
+type safeLinkTransformer struct{}
+
+func (safeLinkTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+	unsafe := []ast.Node{}
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node := n.(type) {
+		case *ast.Link:
+			if !isSafeURL(node.Destination) {
+				unsafe = append(unsafe, n)
+				return ast.WalkContinue, nil
+			}
+			node.SetAttributeString("rel", linkRel)
+		case *ast.AutoLink:
+			if !isSafeURL(node.URL(source)) {
+				unsafe = append(unsafe, n)
+				return ast.WalkContinue, nil
+			}
+			node.SetAttributeString("rel", linkRel)
+		case *ast.Image:
+			if !isSafeURL(node.Destination) {
+				unsafe = append(unsafe, n)
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for _, n := range unsafe {
+		parent := n.Parent()
+		if autoLink, ok := n.(*ast.AutoLink); ok {
+			parent.InsertBefore(parent, n, ast.NewString(autoLink.Label(source)))
+		}
+		for child := n.FirstChild(); child != nil; child = n.FirstChild() {
+			n.RemoveChild(n, child)
+			parent.InsertBefore(parent, n, child)
+		}
+		parent.RemoveChild(parent, n)
+	}
+}
+
+//isSafeURL reports whether a url is relative or uses an allowed scheme. Whitespace and control characters are ignored
+//the way browsers ignore them, so "java\tscript:" is still caught
+func isSafeURL(url []byte) bool {
+	cleaned := strings.ToLower(strings.Map(func(r rune) rune {
+		if r <= ' ' {
+			return -1
+		}
+		return r
+	}, string(url)))
+
+	end := strings.IndexAny(cleaned, ":/?#")
+	if end == -1 || cleaned[end] != ':' {
+		return true
+	}
+	return safeURLSchemes[cleaned[:end]]
+}
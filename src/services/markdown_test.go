@@ -0,0 +1,52 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderMarkdownHTML(t *testing.T) {
+	t.Run("Markdown is rendered to HTML", func(t *testing.T) {
+		html, err := renderMarkdownHTML("# Potato chips\n\nThey are **great**")
+		assert.NoError(t, err)
+		assert.Equal(t, "<h1>Potato chips</h1>\n<p>They are <strong>great</strong></p>\n", html)
+	})
+	t.Run("Raw HTML and scripts are dropped", func(t *testing.T) {
+		html, err := renderMarkdownHTML("<script>alert(1)</script>\n\nhello <img src=x onerror=alert(1)>")
+		assert.NoError(t, err)
+		assert.NotContains(t, html, "<script")
+		assert.NotContains(t, html, "onerror")
+		assert.Contains(t, html, "hello")
+	})
+	t.Run("Links get safe rel attributes", func(t *testing.T) {
+		html, err := renderMarkdownHTML("[site](https://example.com) and https://example.org")
+		assert.NoError(t, err)
+		assert.Equal(t, "<p><a href=\"https://example.com\" rel=\"nofollow noopener noreferrer\">site</a> and <a href=\"https://example.org\" rel=\"nofollow noopener noreferrer\">https://example.org</a></p>\n", html)
+	})
+	t.Run("Links with unsafe schemes are reduced to their text", func(t *testing.T) {
+		html, err := renderMarkdownHTML("[click](JavaScript:alert(1)) <vbscript:msgbox> ![img](data:text/html;base64,xx)")
+		assert.NoError(t, err)
+		assert.Equal(t, "<p>click vbscript:msgbox img</p>\n", html)
+	})
+}
+
+func TestRenderMarkdownText(t *testing.T) {
+	t.Run("Markup is stripped leaving a line per block", func(t *testing.T) {
+		body := "# Title\n\nSome *emphasis* and a [link](https://example.com)\nover two lines\n\n- one\n- two\n\n```\ncode\n```\n\n<div>raw</div>"
+		assert.Equal(t, "Title\nSome emphasis and a link over two lines\none\ntwo\ncode", renderMarkdownText(body))
+	})
+	t.Run("Plain text is returned unchanged", func(t *testing.T) {
+		assert.Equal(t, "some text, potentially containing simple markup", renderMarkdownText("some text, potentially containing simple markup"))
+	})
+}
+
+func TestIsSafeURL(t *testing.T) {
+	assert.True(t, isSafeURL([]byte("https://example.com")))
+	assert.True(t, isSafeURL([]byte("/articles/1")))
+	assert.True(t, isSafeURL([]byte("mailto:someone@example.com")))
+	assert.True(t, isSafeURL([]byte("page?a=b:c")))
+	assert.False(t, isSafeURL([]byte("javascript:alert(1)")))
+	assert.False(t, isSafeURL([]byte(" Java\tScript:alert(1)")))
+	assert.False(t, isSafeURL([]byte("data:text/html,hi")))
+}
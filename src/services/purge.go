@@ -0,0 +1,33 @@
+package services
+
+import (
+	"time"
+)
+
+//PurgeDeletedArticles permanently removes soft deleted articles once they are older than the retention period.
+//It runs once immediately and then on every interval tick until stop is closed, so it is meant to be run in its own goroutine
+func (a *ArticleService) PurgeDeletedArticles(retention, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		a.purgeOnce(retention)
+
+		select {
+		case <-stop:
+			a.Logger.Infof("PurgeDeletedArticles :: stopping purge")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *ArticleService) purgeOnce(retention time.Duration) {
+	deletedBefore := time.Now().Add(-retention)
+	purged, err := a.DBClient.PurgeDeletedArticleRows(deletedBefore)
+	if err != nil {
+		a.Logger.Errorf("PurgeDeletedArticles :: Error purging articles deleted before %v : %v", deletedBefore, err)
+		return
+	}
+	a.Logger.Infof("PurgeDeletedArticles :: Purged %d articles deleted before %v", purged, deletedBefore)
+}
@@ -0,0 +1,34 @@
+package services
+
+import (
+	"time"
+)
+
+//PublishScheduledArticles publishes scheduled articles once their publish time has passed.
+//It checks once immediately and then on every interval tick until stop is closed, so it is meant to be run in its own goroutine
+func (a *ArticleService) PublishScheduledArticles(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		a.publishScheduledOnce()
+
+		select {
+		case <-stop:
+			a.Logger.Infof("PublishScheduledArticles :: stopping scheduler")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *ArticleService) publishScheduledOnce() {
+	published, err := a.DBClient.PublishScheduledArticleRows(time.Now().UTC())
+	if err != nil {
+		a.Logger.Errorf("PublishScheduledArticles :: Error publishing scheduled articles : %v", err)
+		return
+	}
+	if published > 0 {
+		a.Logger.Infof("PublishScheduledArticles :: Published %d scheduled articles", published)
+	}
+}
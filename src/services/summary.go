@@ -0,0 +1,44 @@
+package services
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/bmordt/article-api/src/models"
+)
+
+var (
+	//excerptLength is the most characters of the plain text body kept as an article's excerpt
+	excerptLength = 280
+
+	//wordsPerMinute is the reading speed reading times are estimated with
+	wordsPerMinute = 200
+)
+
+//summarizeArticle works out the excerpt, word count and reading time from the body. They are stored with the article
+//so listings can show them without reading the body back
+func summarizeArticle(article *models.Article) {
+	plainText := renderMarkdownText(article.Body)
+
+	article.Excerpt = excerpt(plainText, excerptLength)
+	article.WordCount = len(strings.Fields(plainText))
+	article.ReadingMinutes = (article.WordCount + wordsPerMinute - 1) / wordsPerMinute
+}
+
+//excerpt shortens text to at most maxLength characters, cutting at the last word boundary and adding an ellipsis
+func excerpt(text string, maxLength int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	if len(runes) <= maxLength {
+		return text
+	}
+
+	cut := maxLength
+	for cut > 0 && !unicode.IsSpace(runes[cut]) {
+		cut--
+	}
+	if cut == 0 {
+		cut = maxLength
+	}
+	return strings.TrimRightFunc(string(runes[:cut]), unicode.IsPunct) + "…"
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bmordt/article-api/src/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummarizeArticle(t *testing.T) {
+	t.Run("The excerpt, word count and reading time come from the body without its markup", func(t *testing.T) {
+		article := &models.Article{Body: "# Potato chips\n\nThey are **great** for [you](https://example.com)"}
+
+		summarizeArticle(article)
+
+		assert.Equal(t, "Potato chips They are great for you", article.Excerpt)
+		assert.Equal(t, 7, article.WordCount)
+		assert.Equal(t, 1, article.ReadingMinutes)
+	})
+	t.Run("Long bodies are cut at a word boundary and reading time rounds up", func(t *testing.T) {
+		article := &models.Article{Body: strings.Repeat("word ", 401)}
+
+		summarizeArticle(article)
+
+		assert.True(t, len(article.Excerpt) <= excerptLength+len("…"))
+		assert.True(t, strings.HasSuffix(article.Excerpt, "word…"))
+		assert.Equal(t, 401, article.WordCount)
+		assert.Equal(t, 3, article.ReadingMinutes)
+	})
+	t.Run("An empty body has no reading time", func(t *testing.T) {
+		article := &models.Article{}
+
+		summarizeArticle(article)
+
+		assert.Equal(t, "", article.Excerpt)
+		assert.Equal(t, 0, article.WordCount)
+		assert.Equal(t, 0, article.ReadingMinutes)
+	})
+}
+
+func TestExcerpt(t *testing.T) {
+	t.Run("Short text is returned whole with whitespace collapsed", func(t *testing.T) {
+		assert.Equal(t, "a short body", excerpt("a  short\nbody ", 20))
+	})
+	t.Run("Long text is cut at a word boundary", func(t *testing.T) {
+		assert.Equal(t, "a body that…", excerpt("a body that is long enough", 13))
+	})
+	t.Run("Trailing punctuation is dropped before the ellipsis", func(t *testing.T) {
+		assert.Equal(t, "first, second…", excerpt("first, second, third", 15))
+	})
+	t.Run("A single long word is cut at the limit", func(t *testing.T) {
+		assert.Equal(t, "abcde…", excerpt("abcdefghij", 5))
+	})
+}
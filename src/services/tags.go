@@ -0,0 +1,584 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bmordt/article-api/src/database"
+	"github.com/bmordt/article-api/src/middleware"
+	"github.com/bmordt/article-api/src/models"
+
+	"github.com/gorilla/mux"
+)
+
+var (
+	//defaultTagPageSize is how many tags a catalogue page has when no limit is asked for
+	defaultTagPageSize = 50
+
+	//maxTagPageSize is the largest catalogue page that can be asked for
+	maxTagPageSize = 200
+
+	//defaultTagSuggestLimit and maxTagSuggestLimit are how many tags are suggested for a prefix
+	defaultTagSuggestLimit = 10
+	maxTagSuggestLimit     = 50
+
+	//defaultTrendingDays and defaultRelatedDays are the windows trending and related tags are counted over by default
+	defaultTrendingDays = 7
+	defaultRelatedDays  = 30
+
+	//maxTagAnalyticsDays is the longest window trending and related tags can be counted over
+	maxTagAnalyticsDays = 365
+
+	//defaultTagAnalyticsLimit and maxTagAnalyticsLimit are how many trending or related tags are returned
+	defaultTagAnalyticsLimit = 10
+	maxTagAnalyticsLimit     = 100
+)
+
+//GetTags lists a page of the tags in use with their article counts. The sort query parameter orders them by
+//"usage" (the default) or "name" and the limit and offset query parameters page through them
+func (a *ArticleService) GetTags(w http.ResponseWriter, r *http.Request) {
+	a.Logger.Infof("Inside GetTags function")
+
+	sortBy := r.URL.Query().Get("sort")
+	if sortBy == "" {
+		sortBy = models.TagSortUsage
+	}
+	if sortBy != models.TagSortUsage && sortBy != models.TagSortName {
+		a.Logger.Warnf("GetTags :: unsupported sort %s", sortBy)
+		apiError.ApiError(w, r, http.StatusBadRequest, fmt.Sprintf("sort query parameter must be \"%s\" or \"%s\"", models.TagSortUsage, models.TagSortName))
+		return
+	}
+
+	limit, ok := a.intQueryParam(w, r, "GetTags", "limit", defaultTagPageSize, 1, maxTagPageSize)
+	if !ok {
+		return
+	}
+	offset, ok := a.intQueryParam(w, r, "GetTags", "offset", 0, 0, -1)
+	if !ok {
+		return
+	}
+
+	tags, total, err := a.reader(r).GetTagRows(sortBy, limit, offset, !middleware.IsAuthenticated(r))
+	if err != nil {
+		a.Logger.Errorf("GetTags :: Error getting tags from DB : %v", err)
+		apiError.ApiError(w, r, http.StatusInternalServerError, "Internal server error getting tags")
+		return
+	}
+
+	resp := &models.TagListResp{
+		Total:  total,
+		Limit:  limit,
+		Offset: offset,
+		Tags:   []models.TagResp{},
+	}
+	for i := range *tags {
+		resp.Tags = append(resp.Tags, *mapToTagResponse(&(*tags)[i]))
+	}
+
+	a.Logger.Infof("GetTags :: Successfully found %d of %d tags", len(resp.Tags), total)
+	middleware.ModelResponse(w, r, 200, resp)
+	return
+}
+
+//GetTag gets the tag named in the path parameter, in any of its spellings, with its article count
+func (a *ArticleService) GetTag(w http.ResponseWriter, r *http.Request) {
+	a.Logger.Infof("Inside GetTag function")
+
+	slug, ok := a.tagSlugFromPath(w, r, "GetTag")
+	if !ok {
+		return
+	}
+
+	tag, err := a.reader(r).GetTagRowBySlug(slug, !middleware.IsAuthenticated(r))
+	if err != nil {
+		a.Logger.Errorf("GetTag :: Error getting tag %s from DB : %v", slug, err)
+		apiError.ApiError(w, r, http.StatusInternalServerError, "Internal server error getting tag")
+		return
+	}
+	if tag == nil {
+		a.Logger.Warnf("GetTag :: tag %s not found", slug)
+		apiError.ApiError(w, r, http.StatusNotFound, "tag not found")
+		return
+	}
+
+	a.Logger.Infof("GetTag :: Successfully found tag: %+v", tag)
+	middleware.ModelResponse(w, r, 200, mapToTagResponse(tag))
+	return
+}
+
+//SuggestTags lists the tags starting with the prefix query parameter, in any of their spellings, by most used first.
+//It is meant to be called as a caller types so it only looks at tag slugs and aliases through their prefix indexes
+func (a *ArticleService) SuggestTags(w http.ResponseWriter, r *http.Request) {
+	a.Logger.Infof("Inside SuggestTags function")
+
+	prefix := models.TagSlug(r.URL.Query().Get("prefix"))
+	if prefix == "" {
+		a.Logger.Warnf("SuggestTags :: no prefix given")
+		apiError.ApiError(w, r, http.StatusBadRequest, "prefix query parameter is required")
+		return
+	}
+	limit, ok := a.intQueryParam(w, r, "SuggestTags", "limit", defaultTagSuggestLimit, 1, maxTagSuggestLimit)
+	if !ok {
+		return
+	}
+
+	tags, err := a.reader(r).GetTagSuggestionRows(prefix, limit, !middleware.IsAuthenticated(r))
+	if err != nil {
+		a.Logger.Errorf("SuggestTags :: Error getting tags starting with %s from DB : %v", prefix, err)
+		apiError.ApiError(w, r, http.StatusInternalServerError, "Internal server error getting tag suggestions")
+		return
+	}
+
+	resp := &models.TagSuggestionsResp{
+		Prefix: prefix,
+		Tags:   []models.TagSuggestionResp{},
+	}
+	for _, tag := range *tags {
+		resp.Tags = append(resp.Tags, models.TagSuggestionResp{
+			Slug:         tag.Slug,
+			Name:         tag.Name,
+			ArticleCount: tag.ArticleCount,
+		})
+	}
+
+	a.Logger.Infof("SuggestTags :: Successfully found %d tags starting with %s", len(resp.Tags), prefix)
+	middleware.ModelResponse(w, r, 200, resp)
+	return
+}
+
+//GetTrendingTags lists the tags used on more articles in the last days (default 7) than in the days before that,
+//by most gained first
+func (a *ArticleService) GetTrendingTags(w http.ResponseWriter, r *http.Request) {
+	a.Logger.Infof("Inside GetTrendingTags function")
+
+	days, ok := a.intQueryParam(w, r, "GetTrendingTags", "days", defaultTrendingDays, 1, maxTagAnalyticsDays)
+	if !ok {
+		return
+	}
+	limit, ok := a.intQueryParam(w, r, "GetTrendingTags", "limit", defaultTagAnalyticsLimit, 1, maxTagAnalyticsLimit)
+	if !ok {
+		return
+	}
+
+	tags, err := a.reader(r).GetTrendingTagRows(days, limit, !middleware.IsAuthenticated(r))
+	if err != nil {
+		a.Logger.Errorf("GetTrendingTags :: Error getting trending tags from DB : %v", err)
+		apiError.ApiError(w, r, http.StatusInternalServerError, "Internal server error getting trending tags")
+		return
+	}
+
+	resp := &models.TrendingTagsResp{
+		Days: days,
+		Tags: []models.TrendingTagResp{},
+	}
+	for _, tag := range *tags {
+		resp.Tags = append(resp.Tags, models.TrendingTagResp{
+			Slug:          tag.Slug,
+			Name:          tag.Name,
+			CurrentCount:  tag.CurrentCount,
+			PreviousCount: tag.PreviousCount,
+			Change:        tag.CurrentCount - tag.PreviousCount,
+		})
+	}
+
+	a.Logger.Infof("GetTrendingTags :: Successfully found %d trending tags", len(resp.Tags))
+	middleware.ModelResponse(w, r, 200, resp)
+	return
+}
+
+//GetRelatedTags lists the tags that appear on the same articles as the tag in the path parameter, over articles created
+//in the last days (default 30), with how many articles carry both and their Jaccard index
+func (a *ArticleService) GetRelatedTags(w http.ResponseWriter, r *http.Request) {
+	a.Logger.Infof("Inside GetRelatedTags function")
+
+	slug, ok := a.tagSlugFromPath(w, r, "GetRelatedTags")
+	if !ok {
+		return
+	}
+	days, ok := a.intQueryParam(w, r, "GetRelatedTags", "days", defaultRelatedDays, 1, maxTagAnalyticsDays)
+	if !ok {
+		return
+	}
+	limit, ok := a.intQueryParam(w, r, "GetRelatedTags", "limit", defaultTagAnalyticsLimit, 1, maxTagAnalyticsLimit)
+	if !ok {
+		return
+	}
+
+	publishedOnly := !middleware.IsAuthenticated(r)
+	tag, err := a.reader(r).GetTagRowBySlug(slug, publishedOnly)
+	if err != nil {
+		a.Logger.Errorf("GetRelatedTags :: Error getting tag %s from DB : %v", slug, err)
+		apiError.ApiError(w, r, http.StatusInternalServerError, "Internal server error getting tag")
+		return
+	}
+	if tag == nil {
+		a.Logger.Warnf("GetRelatedTags :: tag %s not found", slug)
+		apiError.ApiError(w, r, http.StatusNotFound, "tag not found")
+		return
+	}
+
+	related, err := a.reader(r).GetRelatedTagRows(slug, days, limit, publishedOnly)
+	if err != nil {
+		a.Logger.Errorf("GetRelatedTags :: Error getting tags related to %s from DB : %v", slug, err)
+		apiError.ApiError(w, r, http.StatusInternalServerError, "Internal server error getting related tags")
+		return
+	}
+
+	resp := &models.RelatedTagsResp{
+		Tag:     tag.Slug,
+		Days:    days,
+		Related: []models.RelatedTagResp{},
+	}
+	for _, relatedTag := range *related {
+		resp.Related = append(resp.Related, models.RelatedTagResp{
+			Slug:    relatedTag.Slug,
+			Name:    relatedTag.Name,
+			Count:   relatedTag.Count,
+			Jaccard: relatedTag.Jaccard,
+		})
+	}
+
+	a.Logger.Infof("GetRelatedTags :: Successfully found %d tags related to %s", len(resp.Related), slug)
+	middleware.ModelResponse(w, r, 200, resp)
+	return
+}
+
+//RenameTag renames the tag in the path parameter. Every article carrying it shows the new name
+func (a *ArticleService) RenameTag(w http.ResponseWriter, r *http.Request) {
+	a.Logger.Infof("Inside RenameTag function")
+
+	slug, ok := a.tagSlugFromPath(w, r, "RenameTag")
+	if !ok {
+		return
+	}
+
+	renameReq := &models.RenameTagReq{}
+	err := middleware.DecodeRequest(r, renameReq)
+	if err != nil {
+		a.decodeError(w, r, "RenameTag", err)
+		return
+	}
+	newTag := models.NewTag(renameReq.Name)
+	if newTag.Slug == "" {
+		a.Logger.Warnf("RenameTag :: new name for tag %s is empty", slug)
+		apiError.ApiError(w, r, http.StatusBadRequest, "Request name must not be empty")
+		return
+	}
+
+	tag, ok := a.resolveTag(w, r, "RenameTag", slug)
+	if !ok {
+		return
+	}
+	slug = tag.Slug
+
+	found, err := a.DBClient.RenameTagRow(slug, newTag.Name)
+	if err == database.ErrTagExists {
+		a.Logger.Warnf("RenameTag :: can not rename tag %s, tag %s already exists", slug, newTag.Slug)
+		apiError.ApiError(w, r, http.StatusConflict, fmt.Sprintf("tag \"%s\" already exists, merge the tags instead", newTag.Slug))
+		return
+	}
+	if err != nil {
+		a.Logger.Errorf("RenameTag :: Error renaming tag %s : %v", slug, err)
+		apiError.ApiError(w, r, http.StatusInternalServerError, "Internal server error renaming tag")
+		return
+	}
+	if !found {
+		a.Logger.Warnf("RenameTag :: tag %s not found", slug)
+		apiError.ApiError(w, r, http.StatusNotFound, "tag not found")
+		return
+	}
+
+	a.Logger.Infof("RenameTag :: Successfully renamed tag %s to %s", slug, newTag.Name)
+	a.tagResponse(w, r, "RenameTag", newTag.Slug)
+	return
+}
+
+//MergeTag moves every article carrying the tag in the path parameter onto the tag named in the request and removes
+//the merged tag
+func (a *ArticleService) MergeTag(w http.ResponseWriter, r *http.Request) {
+	a.Logger.Infof("Inside MergeTag function")
+
+	slug, ok := a.tagSlugFromPath(w, r, "MergeTag")
+	if !ok {
+		return
+	}
+
+	mergeReq := &models.MergeTagReq{}
+	err := middleware.DecodeRequest(r, mergeReq)
+	if err != nil {
+		a.decodeError(w, r, "MergeTag", err)
+		return
+	}
+	intoSlug := models.TagSlug(mergeReq.Into)
+	if intoSlug == "" {
+		a.Logger.Warnf("MergeTag :: tag to merge %s into is empty", slug)
+		apiError.ApiError(w, r, http.StatusBadRequest, "Request into must not be empty")
+		return
+	}
+
+	//either tag can be given by an alias
+	fromTag, ok := a.resolveTag(w, r, "MergeTag", slug)
+	if !ok {
+		return
+	}
+	intoTag, ok := a.resolveTag(w, r, "MergeTag", intoSlug)
+	if !ok {
+		return
+	}
+	slug, intoSlug = fromTag.Slug, intoTag.Slug
+	if intoSlug == slug {
+		a.Logger.Warnf("MergeTag :: can not merge tag %s into itself", slug)
+		apiError.ApiError(w, r, http.StatusBadRequest, "A tag can not be merged into itself")
+		return
+	}
+
+	found, err := a.DBClient.MergeTagRows(slug, intoSlug)
+	if err != nil {
+		a.Logger.Errorf("MergeTag :: Error merging tag %s into %s : %v", slug, intoSlug, err)
+		apiError.ApiError(w, r, http.StatusInternalServerError, "Internal server error merging tag")
+		return
+	}
+	if !found {
+		a.Logger.Warnf("MergeTag :: tag %s or %s not found", slug, intoSlug)
+		apiError.ApiError(w, r, http.StatusNotFound, "tag not found")
+		return
+	}
+
+	a.Logger.Infof("MergeTag :: Successfully merged tag %s into %s", slug, intoSlug)
+	a.tagResponse(w, r, "MergeTag", intoSlug)
+	return
+}
+
+//AddTagAlias makes the alias in the request another way of writing the tag in the path parameter. Articles written
+//with the alias are given the tag and asking for the alias gets the tag
+func (a *ArticleService) AddTagAlias(w http.ResponseWriter, r *http.Request) {
+	a.Logger.Infof("Inside AddTagAlias function")
+
+	slug, ok := a.tagSlugFromPath(w, r, "AddTagAlias")
+	if !ok {
+		return
+	}
+
+	aliasReq := &models.TagAliasReq{}
+	err := middleware.DecodeRequest(r, aliasReq)
+	if err != nil {
+		a.decodeError(w, r, "AddTagAlias", err)
+		return
+	}
+	alias := models.TagSlug(aliasReq.Alias)
+	if alias == "" {
+		a.Logger.Warnf("AddTagAlias :: alias for tag %s is empty", slug)
+		apiError.ApiError(w, r, http.StatusBadRequest, "Request alias must not be empty")
+		return
+	}
+
+	tag, ok := a.resolveTag(w, r, "AddTagAlias", slug)
+	if !ok {
+		return
+	}
+
+	found, err := a.DBClient.AddTagAliasRow(tag.Slug, alias)
+	if err == database.ErrTagExists {
+		a.Logger.Warnf("AddTagAlias :: can not add alias %s to tag %s, it is already a tag or alias", alias, tag.Slug)
+		apiError.ApiError(w, r, http.StatusConflict, fmt.Sprintf("\"%s\" is already a tag or alias, merge the tags instead", alias))
+		return
+	}
+	if err != nil {
+		a.Logger.Errorf("AddTagAlias :: Error adding alias %s to tag %s : %v", alias, tag.Slug, err)
+		apiError.ApiError(w, r, http.StatusInternalServerError, "Internal server error adding alias")
+		return
+	}
+	if !found {
+		a.Logger.Warnf("AddTagAlias :: tag %s not found", tag.Slug)
+		apiError.ApiError(w, r, http.StatusNotFound, "tag not found")
+		return
+	}
+
+	a.Logger.Infof("AddTagAlias :: Successfully added alias %s to tag %s", alias, tag.Slug)
+	a.tagResponse(w, r, "AddTagAlias", tag.Slug)
+	return
+}
+
+//RemoveTagAlias removes the alias path parameter from the tag in the tagName path parameter
+func (a *ArticleService) RemoveTagAlias(w http.ResponseWriter, r *http.Request) {
+	a.Logger.Infof("Inside RemoveTagAlias function")
+
+	slug, ok := a.tagSlugFromPath(w, r, "RemoveTagAlias")
+	if !ok {
+		return
+	}
+	alias := models.TagSlug(mux.Vars(r)["alias"])
+
+	tag, ok := a.resolveTag(w, r, "RemoveTagAlias", slug)
+	if !ok {
+		return
+	}
+
+	found, err := a.DBClient.RemoveTagAliasRow(tag.Slug, alias)
+	if err != nil {
+		a.Logger.Errorf("RemoveTagAlias :: Error removing alias %s from tag %s : %v", alias, tag.Slug, err)
+		apiError.ApiError(w, r, http.StatusInternalServerError, "Internal server error removing alias")
+		return
+	}
+	if !found {
+		a.Logger.Warnf("RemoveTagAlias :: tag %s has no alias %s", tag.Slug, alias)
+		apiError.ApiError(w, r, http.StatusNotFound, "alias not found")
+		return
+	}
+
+	a.Logger.Infof("RemoveTagAlias :: Successfully removed alias %s from tag %s", alias, tag.Slug)
+	w.WriteHeader(http.StatusNoContent)
+	return
+}
+
+//SetTagParent puts the tag in the path parameter below the parent tag in the request, or at the top level when the
+//parent is empty
+func (a *ArticleService) SetTagParent(w http.ResponseWriter, r *http.Request) {
+	a.Logger.Infof("Inside SetTagParent function")
+
+	slug, ok := a.tagSlugFromPath(w, r, "SetTagParent")
+	if !ok {
+		return
+	}
+
+	parentReq := &models.TagParentReq{}
+	err := middleware.DecodeRequest(r, parentReq)
+	if err != nil {
+		a.decodeError(w, r, "SetTagParent", err)
+		return
+	}
+
+	tag, ok := a.resolveTag(w, r, "SetTagParent", slug)
+	if !ok {
+		return
+	}
+	parentSlug := models.TagSlug(parentReq.Parent)
+	if parentSlug != "" {
+		parent, ok := a.resolveTag(w, r, "SetTagParent", parentSlug)
+		if !ok {
+			return
+		}
+		parentSlug = parent.Slug
+	}
+
+	found, err := a.DBClient.SetTagParentRow(tag.Slug, parentSlug)
+	if err == database.ErrTagCycle {
+		a.Logger.Warnf("SetTagParent :: tag %s can not be below %s", tag.Slug, parentSlug)
+		apiError.ApiError(w, r, http.StatusConflict, fmt.Sprintf("tag \"%s\" can not be below itself", tag.Slug))
+		return
+	}
+	if err != nil {
+		a.Logger.Errorf("SetTagParent :: Error setting parent of tag %s : %v", tag.Slug, err)
+		apiError.ApiError(w, r, http.StatusInternalServerError, "Internal server error setting tag parent")
+		return
+	}
+	if !found {
+		a.Logger.Warnf("SetTagParent :: tag %s or %s not found", tag.Slug, parentSlug)
+		apiError.ApiError(w, r, http.StatusNotFound, "tag not found")
+		return
+	}
+
+	a.Logger.Infof("SetTagParent :: Successfully set parent of tag %s to %s", tag.Slug, parentSlug)
+	a.tagResponse(w, r, "SetTagParent", tag.Slug)
+	return
+}
+
+//resolveTag gets the tag with the slug or alias for an admin route, writing a 404 if there is no such tag
+func (a *ArticleService) resolveTag(w http.ResponseWriter, r *http.Request, funcName, slug string) (*models.Tag, bool) {
+	tag, err := a.DBClient.Primary().GetTagRowBySlug(slug, false)
+	if err != nil {
+		a.Logger.Errorf("%s :: Error getting tag %s from DB : %v", funcName, slug, err)
+		apiError.ApiError(w, r, http.StatusInternalServerError, "Internal server error getting tag")
+		return nil, false
+	}
+	if tag == nil {
+		a.Logger.Warnf("%s :: tag %s not found", funcName, slug)
+		apiError.ApiError(w, r, http.StatusNotFound, "tag not found")
+		return nil, false
+	}
+	return tag, true
+}
+
+//tagResponse responds with the tag with the slug after it has been changed by an admin route
+func (a *ArticleService) tagResponse(w http.ResponseWriter, r *http.Request, funcName, slug string) {
+	tag, err := a.DBClient.Primary().GetTagRowBySlug(slug, false)
+	if err != nil || tag == nil {
+		a.Logger.Errorf("%s :: Error getting tag %s from DB : %v", funcName, slug, err)
+		apiError.ApiError(w, r, http.StatusInternalServerError, "Internal server error getting tag")
+		return
+	}
+	middleware.ModelResponse(w, r, 200, mapToTagResponse(tag))
+}
+
+//tagSlugFromPath reads the tagName path parameter as a slug, writing a 400 if it is missing and a 404 if it can't be a tag
+func (a *ArticleService) tagSlugFromPath(w http.ResponseWriter, r *http.Request, funcName string) (string, bool) {
+	tagName, ok := mux.Vars(r)["tagName"]
+	if !ok {
+		a.Logger.Warnf("%s :: tagName is not present in the url path %s", funcName, r.URL.Path)
+		apiError.ApiError(w, r, http.StatusBadRequest, "tagName path parameter is not provided")
+		return "", false
+	}
+	slug := models.TagSlug(tagName)
+	if slug == "" {
+		a.Logger.Warnf("%s :: tagName %s is blank", funcName, tagName)
+		apiError.ApiError(w, r, http.StatusNotFound, "tag not found")
+		return "", false
+	}
+	return slug, true
+}
+
+//intQueryParam reads an optional integer query parameter, writing a 400 if it is not a whole number from min to max.
+//A max below zero means there is no upper limit
+func (a *ArticleService) intQueryParam(w http.ResponseWriter, r *http.Request, funcName, param string, defaultValue, min, max int) (int, bool) {
+	value := r.URL.Query().Get(param)
+	if value == "" {
+		return defaultValue, true
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < min || (max >= 0 && parsed > max) {
+		a.Logger.Warnf("%s :: %s is not valid %s", funcName, param, value)
+		if max < 0 {
+			apiError.ApiError(w, r, http.StatusBadRequest, fmt.Sprintf("Query parameter %s must be a whole number of at least %d", param, min))
+		} else {
+			apiError.ApiError(w, r, http.StatusBadRequest, fmt.Sprintf("Query parameter %s must be a whole number from %d to %d", param, min, max))
+		}
+		return 0, false
+	}
+	return parsed, true
+}
+
+func mapToTagResponse(tag *models.Tag) *models.TagResp {
+	resp := &models.TagResp{
+		Slug:         tag.Slug,
+		Name:         tag.Name,
+		Parent:       tag.Parent,
+		Aliases:      tag.Aliases,
+		ArticleCount: tag.ArticleCount,
+		CreatedAt:    tag.CreatedAt.Format(time.RFC3339),
+	}
+	if resp.Aliases == nil {
+		resp.Aliases = []string{}
+	}
+	if tag.LastUsedAt != nil {
+		resp.LastUsedAt = tag.LastUsedAt.Format(time.RFC3339)
+	}
+	return resp
+}
+
+//normalizeTags trims the tags written on an article and drops blanks and repeats, treating tags with the same slug
+//e.g. "Health" and "health " as the same tag. The first spelling of each tag is kept
+func normalizeTags(tags []string) []string {
+	normalized := []string{}
+	seen := map[string]bool{}
+	for _, name := range tags {
+		tag := models.NewTag(name)
+		if tag.Slug == "" || seen[tag.Slug] {
+			continue
+		}
+		seen[tag.Slug] = true
+		normalized = append(normalized, tag.Name)
+	}
+	return normalized
+}
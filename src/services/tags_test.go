@@ -0,0 +1,474 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bmordt/article-api/src/models"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	t.Run("Tags differing only in case and whitespace are the same tag and the first spelling is kept", func(t *testing.T) {
+		assert.Equal(t, []string{"Health", "fitness"}, normalizeTags([]string{"Health", "health ", " HEALTH", "fitness"}))
+	})
+	t.Run("Blank tags are dropped and inner whitespace is collapsed", func(t *testing.T) {
+		assert.Equal(t, []string{"potato chips"}, normalizeTags([]string{"", "   ", " potato \t chips "}))
+	})
+	t.Run("No tags gives an empty list", func(t *testing.T) {
+		assert.Equal(t, []string{}, normalizeTags(nil))
+	})
+}
+
+func TestTagSlug(t *testing.T) {
+	t.Run("Slugs are case folded with spaces replaced by hyphens", func(t *testing.T) {
+		assert.Equal(t, "potato-chips", models.TagSlug(" Potato  Chips "))
+		assert.Equal(t, models.TagSlug("potato-chips"), models.TagSlug("Potato Chips"))
+	})
+	t.Run("Unicode compatibility forms and case variants fold to the same slug", func(t *testing.T) {
+		assert.Equal(t, models.TagSlug("strasse"), models.TagSlug("STRAẞE"))
+		assert.Equal(t, models.TagSlug("café"), models.TagSlug("CAFÉ"))
+		assert.Equal(t, "fi", models.TagSlug("ﬁ"))
+	})
+}
+
+func TestGetTags(t *testing.T) {
+	t.Run("Given no query parameters, the first page of tags is returned by usage", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		w := httptest.NewRecorder()
+		a.GetTags(w, httptest.NewRequest("GET", "/tags", nil))
+
+		assert.Equal(t, 200, w.Result().StatusCode)
+		call := dbMock.GetTagRowsCalls()[0]
+		assert.Equal(t, models.TagSortUsage, call.SortBy)
+		assert.Equal(t, defaultTagPageSize, call.Limit)
+		assert.Equal(t, 0, call.Offset)
+		assert.True(t, call.PublishedOnly)
+
+		resp := models.TagListResp{}
+		json.NewDecoder(w.Body).Decode(&resp)
+		assert.Equal(t, 5, resp.Total)
+		assert.Equal(t, 2, len(resp.Tags))
+		assert.Equal(t, "health", resp.Tags[0].Slug)
+		assert.Equal(t, "Health", resp.Tags[0].Name)
+		assert.Equal(t, 3, resp.Tags[0].ArticleCount)
+		assert.Equal(t, "2022-01-02T03:04:05Z", resp.Tags[0].LastUsedAt)
+	})
+	t.Run("Given sort, limit and offset from an authenticated caller, they are passed to the DB", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		w := httptest.NewRecorder()
+		a.GetTags(w, authenticatedRequest(httptest.NewRequest("GET", "/tags?sort=name&limit=2&offset=4", nil)))
+
+		assert.Equal(t, 200, w.Result().StatusCode)
+		call := dbMock.GetTagRowsCalls()[0]
+		assert.Equal(t, models.TagSortName, call.SortBy)
+		assert.Equal(t, 2, call.Limit)
+		assert.Equal(t, 4, call.Offset)
+		assert.False(t, call.PublishedOnly)
+	})
+	t.Run("Given an invalid sort, limit or offset, 400 is returned", func(t *testing.T) {
+		for _, query := range []string{"sort=popular", "limit=0", "limit=1000", "limit=ten", "offset=-1"} {
+			dbMock := newDbClientMock(false, false, false)
+
+			a := NewArticleService(dbMock, testLogger)
+
+			w := httptest.NewRecorder()
+			a.GetTags(w, httptest.NewRequest("GET", "/tags?"+query, nil))
+
+			assert.Equal(t, 400, w.Result().StatusCode, query)
+			assert.Equal(t, 0, len(dbMock.GetTagRowsCalls()), query)
+		}
+	})
+	t.Run("Given a DB error, 500 is returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, true, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		w := httptest.NewRecorder()
+		a.GetTags(w, httptest.NewRequest("GET", "/tags", nil))
+
+		assert.Equal(t, 500, w.Result().StatusCode)
+	})
+}
+
+func TestGetTag(t *testing.T) {
+	t.Run("Given a tag in any spelling, it is looked up by its slug", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		testIncomingReq := mux.SetURLVars(httptest.NewRequest("GET", "/tags/Health", nil), map[string]string{"tagName": "Health"})
+		w := httptest.NewRecorder()
+		a.GetTag(w, testIncomingReq)
+
+		assert.Equal(t, 200, w.Result().StatusCode)
+		assert.Equal(t, "health", dbMock.GetTagRowBySlugCalls()[0].Slug)
+
+		resp := models.TagResp{}
+		json.NewDecoder(w.Body).Decode(&resp)
+		assert.Equal(t, 2, resp.ArticleCount)
+	})
+	t.Run("Given a tag that doesn't exist, 404 is returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		testIncomingReq := mux.SetURLVars(httptest.NewRequest("GET", "/tags/missing", nil), map[string]string{"tagName": missingTestTag})
+		w := httptest.NewRecorder()
+		a.GetTag(w, testIncomingReq)
+
+		assert.Equal(t, 404, w.Result().StatusCode)
+	})
+}
+
+func TestSuggestTags(t *testing.T) {
+	t.Run("Given a prefix, matching tags are suggested with their article counts", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		w := httptest.NewRecorder()
+		a.SuggestTags(w, httptest.NewRequest("GET", "/tags/suggest?prefix=He&limit=5", nil))
+
+		assert.Equal(t, 200, w.Result().StatusCode)
+		call := dbMock.GetTagSuggestionRowsCalls()[0]
+		assert.Equal(t, "he", call.Prefix)
+		assert.Equal(t, 5, call.Limit)
+		assert.True(t, call.PublishedOnly)
+
+		resp := models.TagSuggestionsResp{}
+		json.NewDecoder(w.Body).Decode(&resp)
+		assert.Equal(t, "he", resp.Prefix)
+		assert.Equal(t, 1, len(resp.Tags))
+		assert.Equal(t, "health", resp.Tags[0].Slug)
+		assert.Equal(t, 3, resp.Tags[0].ArticleCount)
+	})
+	t.Run("Given no prefix, 400 is returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		w := httptest.NewRecorder()
+		a.SuggestTags(w, httptest.NewRequest("GET", "/tags/suggest?prefix=%20", nil))
+
+		assert.Equal(t, 400, w.Result().StatusCode)
+		assert.Equal(t, 0, len(dbMock.GetTagSuggestionRowsCalls()))
+	})
+	t.Run("Given a DB error, 500 is returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, true, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		w := httptest.NewRecorder()
+		a.SuggestTags(w, httptest.NewRequest("GET", "/tags/suggest?prefix=he", nil))
+
+		assert.Equal(t, 500, w.Result().StatusCode)
+	})
+}
+
+func TestGetTrendingTags(t *testing.T) {
+	t.Run("Given no query parameters, tags trending over the default window are returned with their change", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		w := httptest.NewRecorder()
+		a.GetTrendingTags(w, httptest.NewRequest("GET", "/tags/trending", nil))
+
+		assert.Equal(t, 200, w.Result().StatusCode)
+		call := dbMock.GetTrendingTagRowsCalls()[0]
+		assert.Equal(t, defaultTrendingDays, call.Days)
+		assert.Equal(t, defaultTagAnalyticsLimit, call.Limit)
+		assert.True(t, call.PublishedOnly)
+
+		resp := models.TrendingTagsResp{}
+		json.NewDecoder(w.Body).Decode(&resp)
+		assert.Equal(t, defaultTrendingDays, resp.Days)
+		assert.Equal(t, 1, len(resp.Tags))
+		assert.Equal(t, 4, resp.Tags[0].Change)
+	})
+	t.Run("Given a window that is too long, 400 is returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		w := httptest.NewRecorder()
+		a.GetTrendingTags(w, httptest.NewRequest("GET", "/tags/trending?days=1000", nil))
+
+		assert.Equal(t, 400, w.Result().StatusCode)
+		assert.Equal(t, 0, len(dbMock.GetTrendingTagRowsCalls()))
+	})
+}
+
+func TestGetRelatedTags(t *testing.T) {
+	t.Run("Given a tag and window, its related tags are returned with their scores", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		testIncomingReq := mux.SetURLVars(httptest.NewRequest("GET", "/tags/Health/related?days=90", nil), map[string]string{"tagName": "Health"})
+		w := httptest.NewRecorder()
+		a.GetRelatedTags(w, testIncomingReq)
+
+		assert.Equal(t, 200, w.Result().StatusCode)
+		call := dbMock.GetRelatedTagRowsCalls()[0]
+		assert.Equal(t, "health", call.Slug)
+		assert.Equal(t, 90, call.Days)
+
+		resp := models.RelatedTagsResp{}
+		json.NewDecoder(w.Body).Decode(&resp)
+		assert.Equal(t, "health", resp.Tag)
+		assert.Equal(t, 1, len(resp.Related))
+		assert.Equal(t, 2, resp.Related[0].Count)
+		assert.Equal(t, 0.5, resp.Related[0].Jaccard)
+	})
+	t.Run("Given a tag that doesn't exist, 404 is returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		testIncomingReq := mux.SetURLVars(httptest.NewRequest("GET", "/tags/missing/related", nil), map[string]string{"tagName": missingTestTag})
+		w := httptest.NewRecorder()
+		a.GetRelatedTags(w, testIncomingReq)
+
+		assert.Equal(t, 404, w.Result().StatusCode)
+		assert.Equal(t, 0, len(dbMock.GetRelatedTagRowsCalls()))
+	})
+}
+
+func TestRenameTag(t *testing.T) {
+	t.Run("Given a new name, the tag is renamed and returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		body := getBody(models.RenameTagReq{Name: " Wellbeing "})
+		testIncomingReq := mux.SetURLVars(httptest.NewRequest("POST", "/admin/tags/health/rename", body), map[string]string{"tagName": "health"})
+		w := httptest.NewRecorder()
+		a.RenameTag(w, testIncomingReq)
+
+		assert.Equal(t, 200, w.Result().StatusCode)
+		call := dbMock.RenameTagRowCalls()[0]
+		assert.Equal(t, "health", call.Slug)
+		assert.Equal(t, "Wellbeing", call.Name)
+		assert.Equal(t, "wellbeing", dbMock.GetTagRowBySlugCalls()[1].Slug)
+	})
+	t.Run("Given a name belonging to another tag, 409 is returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		body := getBody(models.RenameTagReq{Name: "Taken"})
+		testIncomingReq := mux.SetURLVars(httptest.NewRequest("POST", "/admin/tags/health/rename", body), map[string]string{"tagName": "health"})
+		w := httptest.NewRecorder()
+		a.RenameTag(w, testIncomingReq)
+
+		assert.Equal(t, 409, w.Result().StatusCode)
+	})
+	t.Run("Given a blank name, 400 is returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		body := getBody(models.RenameTagReq{Name: "  "})
+		testIncomingReq := mux.SetURLVars(httptest.NewRequest("POST", "/admin/tags/health/rename", body), map[string]string{"tagName": "health"})
+		w := httptest.NewRecorder()
+		a.RenameTag(w, testIncomingReq)
+
+		assert.Equal(t, 400, w.Result().StatusCode)
+		assert.Equal(t, 0, len(dbMock.RenameTagRowCalls()))
+	})
+	t.Run("Given a tag that doesn't exist, 404 is returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		body := getBody(models.RenameTagReq{Name: "Wellbeing"})
+		testIncomingReq := mux.SetURLVars(httptest.NewRequest("POST", "/admin/tags/missing/rename", body), map[string]string{"tagName": missingTestTag})
+		w := httptest.NewRecorder()
+		a.RenameTag(w, testIncomingReq)
+
+		assert.Equal(t, 404, w.Result().StatusCode)
+	})
+}
+
+func TestMergeTag(t *testing.T) {
+	t.Run("Given another tag, the tag is merged into it and the merged tag is returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		body := getBody(models.MergeTagReq{Into: "Health"})
+		testIncomingReq := mux.SetURLVars(httptest.NewRequest("POST", "/admin/tags/wellness/merge", body), map[string]string{"tagName": "wellness"})
+		w := httptest.NewRecorder()
+		a.MergeTag(w, testIncomingReq)
+
+		assert.Equal(t, 200, w.Result().StatusCode)
+		call := dbMock.MergeTagRowsCalls()[0]
+		assert.Equal(t, "wellness", call.FromSlug)
+		assert.Equal(t, "health", call.IntoSlug)
+	})
+	t.Run("Given the same tag to merge into, 400 is returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		body := getBody(models.MergeTagReq{Into: "HEALTH"})
+		testIncomingReq := mux.SetURLVars(httptest.NewRequest("POST", "/admin/tags/health/merge", body), map[string]string{"tagName": "health"})
+		w := httptest.NewRecorder()
+		a.MergeTag(w, testIncomingReq)
+
+		assert.Equal(t, 400, w.Result().StatusCode)
+		assert.Equal(t, 0, len(dbMock.MergeTagRowsCalls()))
+	})
+	t.Run("Given a tag that doesn't exist, 404 is returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		body := getBody(models.MergeTagReq{Into: missingTestTag})
+		testIncomingReq := mux.SetURLVars(httptest.NewRequest("POST", "/admin/tags/health/merge", body), map[string]string{"tagName": "health"})
+		w := httptest.NewRecorder()
+		a.MergeTag(w, testIncomingReq)
+
+		assert.Equal(t, 404, w.Result().StatusCode)
+	})
+}
+
+func TestAddTagAlias(t *testing.T) {
+	t.Run("Given an alias, it is added to the tag as a slug", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		body := getBody(models.TagAliasReq{Alias: "AI"})
+		testIncomingReq := mux.SetURLVars(httptest.NewRequest("POST", "/admin/tags/artificial-intelligence/aliases", body), map[string]string{"tagName": "artificial-intelligence"})
+		w := httptest.NewRecorder()
+		a.AddTagAlias(w, testIncomingReq)
+
+		assert.Equal(t, 200, w.Result().StatusCode)
+		call := dbMock.AddTagAliasRowCalls()[0]
+		assert.Equal(t, "artificial-intelligence", call.Slug)
+		assert.Equal(t, "ai", call.Alias)
+
+		resp := models.TagResp{}
+		json.NewDecoder(w.Body).Decode(&resp)
+		assert.Equal(t, []string{}, resp.Aliases)
+	})
+	t.Run("Given an alias that is already a tag or alias, 409 is returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		body := getBody(models.TagAliasReq{Alias: "Taken"})
+		testIncomingReq := mux.SetURLVars(httptest.NewRequest("POST", "/admin/tags/health/aliases", body), map[string]string{"tagName": "health"})
+		w := httptest.NewRecorder()
+		a.AddTagAlias(w, testIncomingReq)
+
+		assert.Equal(t, 409, w.Result().StatusCode)
+	})
+	t.Run("Given a tag that doesn't exist, 404 is returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		body := getBody(models.TagAliasReq{Alias: "ai"})
+		testIncomingReq := mux.SetURLVars(httptest.NewRequest("POST", "/admin/tags/missing/aliases", body), map[string]string{"tagName": missingTestTag})
+		w := httptest.NewRecorder()
+		a.AddTagAlias(w, testIncomingReq)
+
+		assert.Equal(t, 404, w.Result().StatusCode)
+		assert.Equal(t, 0, len(dbMock.AddTagAliasRowCalls()))
+	})
+}
+
+func TestRemoveTagAlias(t *testing.T) {
+	t.Run("Given an alias of the tag, it is removed", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		testIncomingReq := mux.SetURLVars(httptest.NewRequest("DELETE", "/admin/tags/artificial-intelligence/aliases/ai", nil), map[string]string{"tagName": "artificial-intelligence", "alias": "ai"})
+		w := httptest.NewRecorder()
+		a.RemoveTagAlias(w, testIncomingReq)
+
+		assert.Equal(t, 204, w.Result().StatusCode)
+		assert.Equal(t, "ai", dbMock.RemoveTagAliasRowCalls()[0].Alias)
+	})
+	t.Run("Given an alias the tag doesn't have, 404 is returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		testIncomingReq := mux.SetURLVars(httptest.NewRequest("DELETE", "/admin/tags/health/aliases/missing", nil), map[string]string{"tagName": "health", "alias": missingTestTag})
+		w := httptest.NewRecorder()
+		a.RemoveTagAlias(w, testIncomingReq)
+
+		assert.Equal(t, 404, w.Result().StatusCode)
+	})
+}
+
+func TestSetTagParent(t *testing.T) {
+	t.Run("Given a parent, the tag is put below it", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		body := getBody(models.TagParentReq{Parent: "Science"})
+		testIncomingReq := mux.SetURLVars(httptest.NewRequest("PUT", "/admin/tags/physics/parent", body), map[string]string{"tagName": "physics"})
+		w := httptest.NewRecorder()
+		a.SetTagParent(w, testIncomingReq)
+
+		assert.Equal(t, 200, w.Result().StatusCode)
+		call := dbMock.SetTagParentRowCalls()[0]
+		assert.Equal(t, "physics", call.Slug)
+		assert.Equal(t, "science", call.ParentSlug)
+	})
+	t.Run("Given an empty parent, the tag is moved to the top level", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		body := getBody(models.TagParentReq{})
+		testIncomingReq := mux.SetURLVars(httptest.NewRequest("PUT", "/admin/tags/physics/parent", body), map[string]string{"tagName": "physics"})
+		w := httptest.NewRecorder()
+		a.SetTagParent(w, testIncomingReq)
+
+		assert.Equal(t, 200, w.Result().StatusCode)
+		assert.Equal(t, "", dbMock.SetTagParentRowCalls()[0].ParentSlug)
+	})
+	t.Run("Given a parent that would put the tag below itself, 409 is returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		body := getBody(models.TagParentReq{Parent: "physics"})
+		testIncomingReq := mux.SetURLVars(httptest.NewRequest("PUT", "/admin/tags/physics/parent", body), map[string]string{"tagName": "physics"})
+		w := httptest.NewRecorder()
+		a.SetTagParent(w, testIncomingReq)
+
+		assert.Equal(t, 409, w.Result().StatusCode)
+	})
+	t.Run("Given a parent that doesn't exist, 404 is returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		body := getBody(models.TagParentReq{Parent: missingTestTag})
+		testIncomingReq := mux.SetURLVars(httptest.NewRequest("PUT", "/admin/tags/physics/parent", body), map[string]string{"tagName": "physics"})
+		w := httptest.NewRecorder()
+		a.SetTagParent(w, testIncomingReq)
+
+		assert.Equal(t, 404, w.Result().StatusCode)
+		assert.Equal(t, 0, len(dbMock.SetTagParentRowCalls()))
+	})
+}
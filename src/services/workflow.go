@@ -0,0 +1,128 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/bmordt/article-api/src/middleware"
+	"github.com/bmordt/article-api/src/models"
+)
+
+//allowedTransitions maps each publication status to the statuses an article can move to from it
+var allowedTransitions = map[string][]string{
+	models.StatusDraft:     {models.StatusInReview, models.StatusScheduled, models.StatusPublished, models.StatusArchived},
+	models.StatusInReview:  {models.StatusDraft, models.StatusScheduled, models.StatusPublished},
+	models.StatusScheduled: {models.StatusDraft, models.StatusPublished},
+	models.StatusPublished: {models.StatusDraft, models.StatusArchived},
+	models.StatusArchived:  {models.StatusDraft},
+}
+
+//canTransition reports whether an article in status from may move to status to
+func canTransition(from, to string) bool {
+	for _, allowed := range allowedTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+//SubmitArticle sends a draft article for review
+func (a *ArticleService) SubmitArticle(w http.ResponseWriter, r *http.Request) {
+	a.Logger.Infof("Inside SubmitArticle function")
+	a.transitionArticle(w, r, "SubmitArticle", models.StatusInReview, nil)
+}
+
+//PublishArticle publishes an article straight away, or schedules it when the body has a publish_at in the future
+func (a *ArticleService) PublishArticle(w http.ResponseWriter, r *http.Request) {
+	a.Logger.Infof("Inside PublishArticle function")
+
+	publishReq := &models.PublishArticleReq{}
+	if r.Body != nil {
+		err := middleware.DecodeRequest(r, publishReq)
+		if err != nil && err != io.EOF {
+			a.decodeError(w, r, "PublishArticle", err)
+			return
+		}
+	}
+
+	if publishReq.PublishAt == "" {
+		a.transitionArticle(w, r, "PublishArticle", models.StatusPublished, nil)
+		return
+	}
+
+	publishAt, err := time.Parse(time.RFC3339, publishReq.PublishAt)
+	if err != nil {
+		a.Logger.Errorf("PublishArticle :: Error parsing publish_at: %v", err)
+		apiError.ApiError(w, r, http.StatusBadRequest, fmt.Sprintf("publish_at is not expected format \"%s\"", time.RFC3339))
+		return
+	}
+	if !publishAt.After(time.Now()) {
+		a.transitionArticle(w, r, "PublishArticle", models.StatusPublished, nil)
+		return
+	}
+	publishAt = publishAt.UTC()
+	a.transitionArticle(w, r, "PublishArticle", models.StatusScheduled, &publishAt)
+}
+
+//ArchiveArticle takes a published article out of circulation
+func (a *ArticleService) ArchiveArticle(w http.ResponseWriter, r *http.Request) {
+	a.Logger.Infof("Inside ArchiveArticle function")
+	a.transitionArticle(w, r, "ArchiveArticle", models.StatusArchived, nil)
+}
+
+//UnpublishArticle returns an article to draft, cancelling any schedule
+func (a *ArticleService) UnpublishArticle(w http.ResponseWriter, r *http.Request) {
+	a.Logger.Infof("Inside UnpublishArticle function")
+	a.transitionArticle(w, r, "UnpublishArticle", models.StatusDraft, nil)
+}
+
+//transitionArticle moves the article in the id path parameter to toStatus if the workflow allows it
+func (a *ArticleService) transitionArticle(w http.ResponseWriter, r *http.Request, funcName, toStatus string, publishAt *time.Time) {
+	idInt, ok := a.articleIDFromPath(w, r, funcName)
+	if !ok {
+		return
+	}
+
+	article, err := a.DBClient.Primary().GetArticleRowByID(idInt)
+	if err != nil {
+		a.Logger.Errorf("%s :: Error getting article %d from DB : %v", funcName, idInt, err)
+		apiError.ApiError(w, r, http.StatusInternalServerError, "Internal server error getting article")
+		return
+	}
+	if article == nil {
+		a.Logger.Warnf("%s :: article %d not found", funcName, idInt)
+		apiError.ApiError(w, r, http.StatusNotFound, "article not found")
+		return
+	}
+
+	if !canTransition(article.Status, toStatus) {
+		a.Logger.Warnf("%s :: article %d can not move from %s to %s", funcName, idInt, article.Status, toStatus)
+		apiError.ApiError(w, r, http.StatusConflict, fmt.Sprintf("article can not move from \"%s\" to \"%s\"", article.Status, toStatus))
+		return
+	}
+
+	updated, err := a.DBClient.UpdateArticleStatus(idInt, article.Status, toStatus, publishAt)
+	if err != nil {
+		a.Logger.Errorf("%s :: Error updating article %d status : %v", funcName, idInt, err)
+		apiError.ApiError(w, r, http.StatusInternalServerError, "Internal server error updating article")
+		return
+	}
+	if !updated {
+		a.Logger.Warnf("%s :: article %d status changed while moving it to %s", funcName, idInt, toStatus)
+		apiError.ApiError(w, r, http.StatusConflict, "article status changed, retry the request")
+		return
+	}
+
+	article, err = a.DBClient.Primary().GetArticleRowByID(idInt)
+	if err != nil || article == nil {
+		a.Logger.Errorf("%s :: Error getting updated article %d from DB : %v", funcName, idInt, err)
+		apiError.ApiError(w, r, http.StatusInternalServerError, "Internal server error getting article")
+		return
+	}
+
+	a.Logger.Infof("%s :: Successfully moved article %d to %s", funcName, idInt, toStatus)
+	middleware.ModelResponse(w, r, 200, a.articleResponse(r, article))
+}
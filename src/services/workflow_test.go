@@ -0,0 +1,143 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bmordt/article-api/src/models"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanTransition(t *testing.T) {
+	t.Run("Allowed transitions are accepted", func(t *testing.T) {
+		assert.True(t, canTransition(models.StatusDraft, models.StatusInReview))
+		assert.True(t, canTransition(models.StatusInReview, models.StatusPublished))
+		assert.True(t, canTransition(models.StatusScheduled, models.StatusPublished))
+		assert.True(t, canTransition(models.StatusPublished, models.StatusArchived))
+	})
+	t.Run("Disallowed transitions are rejected", func(t *testing.T) {
+		assert.False(t, canTransition(models.StatusArchived, models.StatusPublished))
+		assert.False(t, canTransition(models.StatusPublished, models.StatusInReview))
+		assert.False(t, canTransition(models.StatusPublished, models.StatusPublished))
+		assert.False(t, canTransition("unknown", models.StatusDraft))
+	})
+}
+
+func TestPublishArticle(t *testing.T) {
+	t.Run("Given a draft and no publish_at, the article is published straight away", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		testIncomingReq := mux.SetURLVars(httptest.NewRequest("POST", "/articles/7/publish", nil), map[string]string{"id": strconv.Itoa(draftTestID)})
+		w := httptest.NewRecorder()
+
+		a.PublishArticle(w, testIncomingReq)
+
+		assert.Equal(t, 200, w.Result().StatusCode)
+		assert.Equal(t, 1, len(dbMock.UpdateArticleStatusCalls()))
+		call := dbMock.UpdateArticleStatusCalls()[0]
+		assert.Equal(t, draftTestID, call.ID)
+		assert.Equal(t, models.StatusDraft, call.FromStatus)
+		assert.Equal(t, models.StatusPublished, call.ToStatus)
+		assert.Nil(t, call.PublishAt)
+	})
+	t.Run("Given a draft and a future publish_at, the article is scheduled", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		publishAt := time.Now().Add(48 * time.Hour).UTC().Truncate(time.Second)
+		body := getBody(models.PublishArticleReq{PublishAt: publishAt.Format(time.RFC3339)})
+		testIncomingReq := mux.SetURLVars(httptest.NewRequest("POST", "/articles/7/publish", body), map[string]string{"id": strconv.Itoa(draftTestID)})
+		w := httptest.NewRecorder()
+
+		a.PublishArticle(w, testIncomingReq)
+
+		assert.Equal(t, 200, w.Result().StatusCode)
+		call := dbMock.UpdateArticleStatusCalls()[0]
+		assert.Equal(t, models.StatusScheduled, call.ToStatus)
+		assert.Equal(t, publishAt, *call.PublishAt)
+	})
+	t.Run("Given an invalid publish_at, 400 is returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		body := getBody(models.PublishArticleReq{PublishAt: "tomorrow"})
+		testIncomingReq := mux.SetURLVars(httptest.NewRequest("POST", "/articles/7/publish", body), map[string]string{"id": strconv.Itoa(draftTestID)})
+		w := httptest.NewRecorder()
+
+		a.PublishArticle(w, testIncomingReq)
+
+		assert.Equal(t, 400, w.Result().StatusCode)
+		assert.Equal(t, 0, len(dbMock.UpdateArticleStatusCalls()))
+	})
+	t.Run("Given an article that is already published, 409 is returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		testIncomingReq := mux.SetURLVars(httptest.NewRequest("POST", "/articles/1/publish", strings.NewReader("")), map[string]string{"id": "1"})
+		w := httptest.NewRecorder()
+
+		a.PublishArticle(w, testIncomingReq)
+
+		assert.Equal(t, 409, w.Result().StatusCode)
+
+		actualResp := make(map[string]string)
+		err := json.Unmarshal(w.Body.Bytes(), &actualResp)
+		assert.NoError(t, err)
+		assert.Equal(t, "article can not move from \"published\" to \"published\"", actualResp["Message"])
+		assert.Equal(t, 0, len(dbMock.UpdateArticleStatusCalls()))
+	})
+	t.Run("Given an article that does not exist, 404 is returned", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		testIncomingReq := mux.SetURLVars(httptest.NewRequest("POST", "/articles/404/publish", nil), map[string]string{"id": strconv.Itoa(missingTestID)})
+		w := httptest.NewRecorder()
+
+		a.PublishArticle(w, testIncomingReq)
+
+		assert.Equal(t, 404, w.Result().StatusCode)
+	})
+}
+
+func TestArchiveArticle(t *testing.T) {
+	t.Run("Given a published article, it is archived", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		testIncomingReq := mux.SetURLVars(httptest.NewRequest("POST", "/articles/1/archive", nil), map[string]string{"id": "1"})
+		w := httptest.NewRecorder()
+
+		a.ArchiveArticle(w, testIncomingReq)
+
+		assert.Equal(t, 200, w.Result().StatusCode)
+		assert.Equal(t, models.StatusArchived, dbMock.UpdateArticleStatusCalls()[0].ToStatus)
+	})
+}
+
+func TestPublishScheduledArticles(t *testing.T) {
+	t.Run("Scheduled articles are published straight away and the scheduler stops when told to", func(t *testing.T) {
+		dbMock := newDbClientMock(false, false, false)
+
+		a := NewArticleService(dbMock, testLogger)
+
+		stop := make(chan struct{})
+		close(stop)
+		a.PublishScheduledArticles(time.Minute, stop)
+
+		assert.Equal(t, 1, len(dbMock.PublishScheduledArticleRowsCalls()))
+		assert.WithinDuration(t, time.Now(), dbMock.PublishScheduledArticleRowsCalls()[0].Now, time.Minute)
+	})
+}